@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/fx"
+	"groove/pkgs/db"
+	"groove/pkgs/ent"
+	Session "groove/pkgs/ent/session"
+	User "groove/pkgs/ent/user"
+	"groove/pkgs/env"
+	"groove/pkgs/secret"
+	. "groove/pkgs/util"
+	"os"
+	"strconv"
+	"time"
+)
+
+// deps are the dependencies available to commands.
+type deps struct {
+	fx.In
+	Client   *ent.Client
+	Migrator *db.Migrator
+}
+
+// command is an admin task run with `./main <command> [args...]` instead of starting the server.
+type command func(ctx context.Context, deps deps, args []string) error
+
+var commands = map[string]command{
+	"migrate":   migrate,
+	"reencrypt": reencrypt,
+	"role":      role,
+}
+
+// runCommand starts the dependencies needed by commands (environment, keyring, database),
+// runs the command, and returns the process exit code.
+func runCommand(name string, args []string) int {
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", name)
+		return 2
+	}
+
+	var d deps
+	app := fx.New(
+		fx.NopLogger,
+		fx.Provide(
+			db.ProvideDB,
+			db.ProvideClient,
+			db.ProvideMigrator,
+			env.ProvideEnvVars,
+		),
+		fx.Invoke(secret.InvokeKeyring),
+		fx.Invoke(func(injected deps) { d = injected }),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	if err := app.Start(ctx); err != nil {
+		LogError("runCommand", "starting "+name, err)
+		return 1
+	}
+	defer func() { _ = app.Stop(context.Background()) }()
+
+	if err := cmd(ctx, d, args); err != nil {
+		LogError("runCommand", "running "+name, err)
+		return 1
+	}
+	return 0
+}
+
+// migrate manages the versioned database migrations.
+// usage: migrate up | migrate down [n] | migrate status | migrate baseline <version>
+func migrate(ctx context.Context, d deps, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: migrate up | down [n] | status | baseline <version>")
+	}
+
+	var done []db.Migration
+	var err error
+	switch args[0] {
+	case "up":
+		done, err = d.Migrator.Up(ctx)
+	case "down":
+		n := 1
+		if len(args) > 1 {
+			if n, err = strconv.Atoi(args[1]); err != nil || n < 1 {
+				return errors.New("down expects a positive number of migrations")
+			}
+		}
+		done, err = d.Migrator.Down(ctx, n)
+	case "baseline":
+		if len(args) < 2 {
+			return errors.New("baseline expects the version the database is at")
+		}
+		done, err = d.Migrator.Baseline(ctx, args[1])
+	case "status":
+		statuses, err := d.Migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = "applied " + status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%s_%s\t%s\n", status.Version, status.Name, applied)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate subcommand %q", args[0])
+	}
+
+	for _, migration := range done {
+		fmt.Printf(
+			"%s [SUCCESS] Migrate %s %s_%s\n",
+			time.Now().Format("15:04:05"),
+			args[0], migration.Version, migration.Name,
+		)
+	}
+	return err
+}
+
+// reencrypt re-encrypts stored Spotify tokens and TOTP secrets under the primary key of TOKEN_KEYS;
+// once it succeeds, the previous keys can be removed.
+func reencrypt(ctx context.Context, d deps, _ []string) error {
+	affected, err := db.ReencryptLinks(ctx, d.Client)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf(
+		"%s [SUCCESS] Links Re-encrypted (affected: %d)\n",
+		time.Now().Format("15:04:05"),
+		affected,
+	)
+
+	affected, err = db.ReencryptTOTPSecrets(ctx, d.Client)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf(
+		"%s [SUCCESS] TOTP Secrets Re-encrypted (affected: %d)\n",
+		time.Now().Format("15:04:05"),
+		affected,
+	)
+	return nil
+}
+
+// role sets the role of a user, i.e. to make the first admin, who can then manage other users through the admin API.
+// the sessions of the user are ended, so they log in again under the new role.
+// usage: role <username> user|admin
+func role(ctx context.Context, d deps, args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: role <username> user|admin")
+	}
+
+	r := User.Role(args[1])
+	if err := User.RoleValidator(r); err != nil {
+		return fmt.Errorf("unknown role %q", args[1])
+	}
+
+	user, err := d.Client.User.
+		Query().
+		Where(User.UsernameEQ(args[0])).
+		First(ctx)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return fmt.Errorf("unknown user %q", args[0])
+		}
+		return err
+	}
+
+	tx, err := d.Client.Tx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err = tx.User.UpdateOneID(user.ID).SetRole(r).Exec(ctx); err != nil {
+		return err
+	}
+
+	// the user logs in again, so no session (nor its Csrf token) outlives a change of privileges.
+	ended, err := tx.Session.
+		Delete().
+		Where(Session.UserIDEQ(user.ID)).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	fmt.Printf(
+		"%s [SUCCESS] Role of %s set to %s (sessions ended: %d)\n",
+		time.Now().Format("15:04:05"),
+		args[0], r, ended,
+	)
+	return nil
+}
@@ -10,8 +10,11 @@ require (
 	github.com/google/uuid v1.4.0
 	github.com/joho/godotenv v1.5.1
 	github.com/lib/pq v1.10.9
+	github.com/mattn/go-sqlite3 v1.14.16
 	go.uber.org/fx v1.20.1
 	golang.org/x/crypto v0.14.0
+	golang.org/x/sync v0.1.0
+	golang.org/x/time v0.3.0
 )
 
 require (
@@ -1,20 +1,38 @@
 package main
 
 import (
-	_ "github.com/lib/pq"
 	"go.uber.org/fx"
+	"groove/pkgs/cache"
 	"groove/pkgs/db"
 	"groove/pkgs/env"
+	"groove/pkgs/mail"
+	"groove/pkgs/secret"
+	"groove/pkgs/spotify"
 	"groove/server"
+	"os"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1], os.Args[2:]))
+	}
+
 	fx.New(
 		fx.Provide(
+			db.ProvideDB,
 			db.ProvideClient,
+			db.ProvideMigrator,
+			db.ProvideRefresher,
+			db.ProvideThrottles,
 			env.ProvideEnvVars,
+			spotify.ProvideClient,
+			spotify.ProvideAppToken,
+			cache.ProvideCache,
+			mail.ProvideSender,
 		),
 		fx.Invoke(
+			secret.InvokeKeyring,
+			db.InvokeMigrationCheck,
 			server.InvokeServer,
 			db.InvokeScheduler,
 		),
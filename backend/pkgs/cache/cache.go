@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"context"
+	"groove/pkgs/ent"
+	"groove/pkgs/env"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Entry is a cached value along with the time it stops being valid.
+type Entry struct {
+	Value      []byte
+	Expiration time.Time
+}
+
+// Store is a single tier of the cache.
+// stores are best-effort: failures are logged by the store and reported as misses.
+type Store interface {
+	Get(ctx context.Context, key string) (Entry, bool)
+	Set(ctx context.Context, key string, entry Entry)
+}
+
+// Cache looks values up tier by tier (fastest first), backfilling the faster tiers on a hit.
+type Cache struct {
+	tiers []Store
+}
+
+func New(tiers ...Store) *Cache {
+	return &Cache{tiers: tiers}
+}
+
+func ProvideCache(client *ent.Client, env *env.Env) *Cache {
+	tiers := []Store{NewLRU(4096)}
+	if env.CacheDB {
+		tiers = append(tiers, NewEnt(client))
+	}
+	return New(tiers...)
+}
+
+// Get returns the value cached under key.
+func (c *Cache) Get(ctx context.Context, key string) ([]byte, bool) {
+	for i, tier := range c.tiers {
+		entry, ok := tier.Get(ctx, key)
+		if !ok {
+			continue
+		}
+		for _, faster := range c.tiers[:i] {
+			faster.Set(ctx, key, entry)
+		}
+		return entry.Value, true
+	}
+	return nil, false
+}
+
+// Set caches value under key in every tier for ttl.
+func (c *Cache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) {
+	entry := Entry{Value: value, Expiration: time.Now().Add(ttl)}
+	for _, tier := range c.tiers {
+		tier.Set(ctx, key, entry)
+	}
+}
+
+// Key normalizes a Spotify endpoint and market into a cache key, so equivalent requests share an entry.
+// i.e. Key("artists/abc/", "us") == Key("/artists/abc", "US") == "/artists/abc?market=US"
+func Key(endpoint, market string) string {
+	endpoint = "/" + strings.Trim(endpoint, "/")
+	return endpoint + "?" + url.Values{"market": {strings.ToUpper(market)}}.Encode()
+}
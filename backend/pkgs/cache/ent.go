@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"context"
+	"groove/pkgs/ent"
+	CacheEntry "groove/pkgs/ent/cacheentry"
+	. "groove/pkgs/util"
+	"time"
+)
+
+// Ent is a Store persisted in the database through the CacheEntry entity.
+// expired entries are treated as misses and deleted by the scheduler.
+type Ent struct {
+	client *ent.Client
+}
+
+func NewEnt(client *ent.Client) *Ent {
+	return &Ent{client: client}
+}
+
+func (e *Ent) Get(ctx context.Context, key string) (Entry, bool) {
+	cached, err := e.client.CacheEntry.
+		Query().
+		Where(
+			CacheEntry.KeyEQ(key),
+			CacheEntry.ExpirationGT(time.Now()),
+		).
+		First(ctx)
+	if err != nil {
+		if !ent.IsNotFound(err) {
+			LogError("Cache-Ent-Get", "querying cache entry", err)
+		}
+		return Entry{}, false
+	}
+
+	return Entry{Value: cached.Value, Expiration: cached.Expiration}, true
+}
+
+func (e *Ent) Set(ctx context.Context, key string, entry Entry) {
+	// replace any previous (likely expired) entry under the same key.
+	_, err := e.client.CacheEntry.
+		Delete().
+		Where(CacheEntry.KeyEQ(key)).
+		Exec(ctx)
+	if err != nil {
+		LogError("Cache-Ent-Set", "deleting cache entry", err)
+		return
+	}
+
+	_, err = e.client.CacheEntry.Create().
+		SetKey(key).
+		SetValue(entry.Value).
+		SetExpiration(entry.Expiration).
+		Save(ctx)
+	if err != nil && !ent.IsConstraintError(err) {
+		// a constraint error means a concurrent request already cached the same key.
+		LogError("Cache-Ent-Set", "creating cache entry", err)
+	}
+}
@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// LRU is an in-memory Store holding at most capacity entries, evicting the least recently used first.
+type LRU struct {
+	mu       sync.Mutex
+	capacity int
+	items    map[string]*list.Element
+	order    *list.List // front is the most recently used.
+}
+
+type lruItem struct {
+	key   string
+	entry Entry
+}
+
+func NewLRU(capacity int) *LRU {
+	return &LRU{
+		capacity: capacity,
+		items:    map[string]*list.Element{},
+		order:    list.New(),
+	}
+}
+
+func (l *LRU) Get(_ context.Context, key string) (Entry, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	element, ok := l.items[key]
+	if !ok {
+		return Entry{}, false
+	}
+
+	item := element.Value.(*lruItem)
+	if item.entry.Expiration.Before(time.Now()) {
+		l.order.Remove(element)
+		delete(l.items, key)
+		return Entry{}, false
+	}
+
+	l.order.MoveToFront(element)
+	return item.entry, true
+}
+
+func (l *LRU) Set(_ context.Context, key string, entry Entry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if element, ok := l.items[key]; ok {
+		element.Value.(*lruItem).entry = entry
+		l.order.MoveToFront(element)
+		return
+	}
+
+	l.items[key] = l.order.PushFront(&lruItem{key: key, entry: entry})
+	if l.order.Len() > l.capacity {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.items, oldest.Value.(*lruItem).key)
+	}
+}
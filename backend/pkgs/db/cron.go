@@ -2,28 +2,51 @@ package db
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"go.uber.org/fx"
 	"groove/pkgs/ent"
+	AccessToken "groove/pkgs/ent/accesstoken"
+	CacheEntry "groove/pkgs/ent/cacheentry"
+	DataExport "groove/pkgs/ent/dataexport"
+	EmailVerification "groove/pkgs/ent/emailverification"
+	LoginChallenge "groove/pkgs/ent/loginchallenge"
 	OAuthState "groove/pkgs/ent/oauthstate"
+	PasswordReset "groove/pkgs/ent/passwordreset"
+	PlaylistImport "groove/pkgs/ent/playlistimport"
 	Session "groove/pkgs/ent/session"
+	SpotifyLink "groove/pkgs/ent/spotifylink"
+	Throttle "groove/pkgs/ent/throttle"
+	User "groove/pkgs/ent/user"
+	"groove/pkgs/env"
+	"groove/pkgs/mail"
+	"groove/pkgs/spotify"
 	. "groove/pkgs/util"
+	"strconv"
 	"time"
 )
 
 type Scheduler struct {
-	stop    chan struct{}
-	done    chan struct{}
-	tickers []*time.Ticker
-	client  *ent.Client
+	stop      chan struct{}
+	done      chan struct{}
+	tickers   []*time.Ticker
+	client    *ent.Client
+	refresher *Refresher
+	spotify   *spotify.Client
+	mail      mail.Sender
+	env       *env.Env
 }
 
-func InvokeScheduler(lc fx.Lifecycle, client *ent.Client) {
+func InvokeScheduler(lc fx.Lifecycle, client *ent.Client, refresher *Refresher, spotify *spotify.Client, mail mail.Sender, env *env.Env) {
 	scheduler := &Scheduler{
-		stop:    make(chan struct{}),
-		done:    make(chan struct{}),
-		tickers: []*time.Ticker{},
-		client:  client,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+		tickers:   []*time.Ticker{},
+		client:    client,
+		refresher: refresher,
+		spotify:   spotify,
+		mail:      mail,
+		env:       env,
 	}
 	lc.Append(fx.Hook{
 		OnStart: func(context.Context) error {
@@ -56,13 +79,28 @@ func (s *Scheduler) Start() {
 	go func() {
 		defer close(s.done)
 
+		ticker10s := s.ticker(10 * time.Second)
+		ticker5m := s.ticker(5 * time.Minute)
 		ticker24h := s.ticker(24 * time.Hour)
 
 		for {
 			select {
+			case <-ticker10s.C:
+				go s.RunTask(s.RunExports)
+				go s.RunTask(s.RunImports)
+			case <-ticker5m.C:
+				go s.RunTask(s.RefreshLinks)
 			case <-ticker24h.C:
 				go s.RunTask(s.CleanSession)
 				go s.RunTask(s.CleanOAuthStore)
+				go s.RunTask(s.CleanCache)
+				go s.RunTask(s.CleanPasswordResets)
+				go s.RunTask(s.CleanEmailVerifications)
+				go s.RunTask(s.CleanLoginChallenges)
+				go s.RunTask(s.CleanThrottles)
+				go s.RunTask(s.CleanDataExports)
+				go s.RunTask(s.CleanAccessTokens)
+				go s.RunTask(s.CleanPlaylistImports)
 			case <-s.stop:
 				return
 			}
@@ -116,3 +154,204 @@ func (s *Scheduler) CleanOAuthStore() {
 		)
 	}
 }
+
+// CleanCache deletes expired catalog cache entries every 24 hours.
+// Required as expired entries are only skipped on read, meaning the database still stores them.
+func (s *Scheduler) CleanCache() {
+	affected, err := s.client.CacheEntry.
+		Delete().
+		Where(CacheEntry.ExpirationLT(time.Now())).
+		Exec(context.Background())
+	if err != nil {
+		LogError("CleanCache[CRON]", "Worker", err)
+	} else {
+		fmt.Printf(
+			"%s [SUCCESS] Cache Cleared (affected: %d)\n",
+			time.Now().Format("15:04:05"),
+			affected,
+		)
+	}
+}
+
+// CleanPasswordResets deletes expired password resets every 24 hours.
+// Required as most reset links are never used, meaning the database still stores them.
+func (s *Scheduler) CleanPasswordResets() {
+	affected, err := s.client.PasswordReset.
+		Delete().
+		Where(PasswordReset.ExpirationLT(time.Now())).
+		Exec(context.Background())
+	if err != nil {
+		LogError("CleanPasswordResets[CRON]", "Worker", err)
+	} else {
+		fmt.Printf(
+			"%s [SUCCESS] Password Resets Cleared (affected: %d)\n",
+			time.Now().Format("15:04:05"),
+			affected,
+		)
+	}
+}
+
+// CleanEmailVerifications deletes expired email verifications every 24 hours.
+// Required as verification links expire without being used, meaning the database still stores them.
+func (s *Scheduler) CleanEmailVerifications() {
+	affected, err := s.client.EmailVerification.
+		Delete().
+		Where(EmailVerification.ExpirationLT(time.Now())).
+		Exec(context.Background())
+	if err != nil {
+		LogError("CleanEmailVerifications[CRON]", "Worker", err)
+	} else {
+		fmt.Printf(
+			"%s [SUCCESS] Email Verifications Cleared (affected: %d)\n",
+			time.Now().Format("15:04:05"),
+			affected,
+		)
+	}
+}
+
+// CleanLoginChallenges deletes expired login challenges every 24 hours.
+// Required as challenges expire when a login is abandoned after the password, meaning the database still stores them.
+func (s *Scheduler) CleanLoginChallenges() {
+	affected, err := s.client.LoginChallenge.
+		Delete().
+		Where(LoginChallenge.ExpirationLT(time.Now())).
+		Exec(context.Background())
+	if err != nil {
+		LogError("CleanLoginChallenges[CRON]", "Worker", err)
+	} else {
+		fmt.Printf(
+			"%s [SUCCESS] Login Challenges Cleared (affected: %d)\n",
+			time.Now().Format("15:04:05"),
+			affected,
+		)
+	}
+}
+
+// CleanThrottles deletes throttles quiet for longer than any window every 24 hours.
+// Required as their attempts are forgotten by then, but the database still stores them.
+func (s *Scheduler) CleanThrottles() {
+	now := time.Now()
+	affected, err := s.client.Throttle.
+		Delete().
+		Where(
+			Throttle.LastAttemptLT(now.Add(-ThrottleWindow)),
+			Throttle.Or(
+				Throttle.LockedUntilIsNil(),
+				Throttle.LockedUntilLT(now),
+			),
+		).
+		Exec(context.Background())
+	if err != nil {
+		LogError("CleanThrottles[CRON]", "Worker", err)
+	} else {
+		fmt.Printf(
+			"%s [SUCCESS] Throttles Cleared (affected: %d)\n",
+			time.Now().Format("15:04:05"),
+			affected,
+		)
+	}
+}
+
+// CleanDataExports deletes expired data exports every 24 hours, along with exports that never became ready
+// (failed, or left running by a restart) so their users can ask for another one.
+func (s *Scheduler) CleanDataExports() {
+	now := time.Now()
+	affected, err := s.client.DataExport.
+		Delete().
+		Where(
+			DataExport.Or(
+				DataExport.ExpirationLT(now),
+				DataExport.And(
+					DataExport.ExpirationIsNil(),
+					DataExport.CreatedAtLT(now.Add(-ExportTTL)),
+				),
+			),
+		).
+		Exec(context.Background())
+	if err != nil {
+		LogError("CleanDataExports[CRON]", "Worker", err)
+	} else {
+		fmt.Printf(
+			"%s [SUCCESS] DataExports Cleared (affected: %d)\n",
+			time.Now().Format("15:04:05"),
+			affected,
+		)
+	}
+}
+
+// CleanAccessTokens deletes expired personal access tokens every 24 hours.
+// Required as expired tokens are only rejected on use, meaning the database still stores them.
+func (s *Scheduler) CleanAccessTokens() {
+	affected, err := s.client.AccessToken.
+		Delete().
+		Where(AccessToken.ExpirationLT(time.Now())).
+		Exec(context.Background())
+	if err != nil {
+		LogError("CleanAccessTokens[CRON]", "Worker", err)
+	} else {
+		fmt.Printf(
+			"%s [SUCCESS] AccessTokens Cleared (affected: %d)\n",
+			time.Now().Format("15:04:05"),
+			affected,
+		)
+	}
+}
+
+// RefreshLinks refreshes access tokens of SpotifyLinks expiring within the next 10 minutes, every 5 minutes.
+// Only links of users with an active session are refreshed; others are refreshed lazily when they come back.
+// This keeps users from waiting on a refresh during a request.
+func (s *Scheduler) RefreshLinks() {
+	ctx := context.Background()
+	now := time.Now()
+	before := now.Add(10 * time.Minute)
+
+	links, err := s.client.SpotifyLink.
+		Query().
+		Where(
+			SpotifyLink.RevokedEQ(false),
+			SpotifyLink.AccessTokenExpirationLT(before),
+			SpotifyLink.HasUserWith(User.HasSessionWith(Session.ExpirationGT(now))),
+		).
+		IDs(ctx)
+	if err != nil {
+		LogError("RefreshLinks[CRON]", "Worker", err)
+		return
+	} else if len(links) == 0 {
+		return
+	}
+
+	var refreshed int
+	for _, id := range links {
+		if _, err = s.refresher.Refresh(ctx, id, before); err != nil {
+			if !errors.Is(err, ErrLinkRevoked) {
+				LogError("RefreshLinks[CRON]", "Refreshing link "+strconv.Itoa(id), err)
+			}
+			continue
+		}
+		refreshed++
+	}
+
+	fmt.Printf(
+		"%s [SUCCESS] Links Refreshed (affected: %d)\n",
+		time.Now().Format("15:04:05"),
+		refreshed,
+	)
+}
+
+// CleanPlaylistImports deletes playlist imports not updated for a day, every 24 hours.
+// Required as imports are kept after they are done (or left unconfirmed), meaning the database still stores them.
+func (s *Scheduler) CleanPlaylistImports() {
+	affected, err := s.client.PlaylistImport.
+		Delete().
+		Where(PlaylistImport.UpdatedAtLT(time.Now().Add(-ImportTTL))).
+		Exec(context.Background())
+	if err != nil {
+		LogError("CleanPlaylistImports[CRON]", "Worker", err)
+	} else {
+		fmt.Printf(
+			"%s [SUCCESS] Playlist Imports Cleared (affected: %d)\n",
+			time.Now().Format("15:04:05"),
+			affected,
+		)
+	}
+}
@@ -2,32 +2,68 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"entgo.io/ent/dialect"
+	entsql "entgo.io/ent/dialect/sql"
 	"fmt"
 	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
 	"go.uber.org/fx"
 	"groove/pkgs/ent"
 	"groove/pkgs/env"
 	. "groove/pkgs/util"
 )
 
-func ProvideClient(lc fx.Lifecycle, shutdowner fx.Shutdowner, env *env.Env) *ent.Client {
-	client, err := ent.Open("postgres", env.PgURI)
+// ProvideDB opens the database selected by DB_DRIVER.
+func ProvideDB(lc fx.Lifecycle, shutdowner fx.Shutdowner, env *env.Env) *sql.DB {
+	source := env.PgURI
+	if env.DBDriver == dialect.SQLite {
+		source = env.SQLitePath
+	}
+
+	db, err := Open(env.DBDriver, source)
 	if err != nil {
-		LogError("ProvideClient", "failed connecting to postgresql", err)
+		LogError("ProvideDB", "failed connecting to "+env.DBDriver, err)
 		_ = shutdowner.Shutdown()
+		return nil
 	}
 
 	lc.Append(fx.Hook{
-		OnStart: func(ctx context.Context) error {
-			if err = client.Schema.Create(ctx); err != nil {
-				return fmt.Errorf("failed creating schema resources: %w", err)
-			}
-			return nil
-		},
 		OnStop: func(context.Context) error {
-			return client.Close()
+			return db.Close()
 		},
 	})
 
-	return client
+	return db
+}
+
+// Open opens a database of the given dialect (postgres or sqlite3).
+// for postgres, source is a connection uri; for sqlite3, the path of the database file (or ":memory:").
+func Open(driver, source string) (*sql.DB, error) {
+	switch driver {
+	case dialect.Postgres:
+		return sql.Open(driver, source)
+	case dialect.SQLite:
+		// foreign keys are off by default in SQLite, but the cascades of the schema rely on them.
+		db, err := sql.Open(driver, fmt.Sprintf("file:%s?_fk=1&_busy_timeout=5000", source))
+		if err != nil {
+			return nil, err
+		}
+		// SQLite serializes writes anyway; a single connection avoids SQLITE_BUSY errors
+		// and keeps ":memory:" databases alive (every connection would get its own).
+		db.SetMaxOpenConns(1)
+		return db, nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", driver)
+	}
+}
+
+// ProvideClient provides the ent client. the schema itself is managed by versioned migrations (see migrate.go).
+func ProvideClient(db *sql.DB, env *env.Env) *ent.Client {
+	return NewClient(db, env.DBDriver)
+}
+
+// NewClient creates an ent client over an opened database of the given dialect.
+func NewClient(db *sql.DB, driver string) *ent.Client {
+	return ent.NewClient(ent.Driver(entsql.OpenDB(driver, db)))
 }
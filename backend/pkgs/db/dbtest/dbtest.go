@@ -0,0 +1,39 @@
+// Package dbtest opens throwaway databases for tests: in-memory SQLite databases with every migration applied,
+// the same way `./main migrate up` sets up a single file database.
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"entgo.io/ent/dialect"
+	"groove/pkgs/db"
+	"groove/pkgs/ent"
+	"testing"
+)
+
+// Open opens an in-memory SQLite database and applies every migration, failing the test if either fails.
+// the database is closed once the test ends.
+func Open(t testing.TB) *sql.DB {
+	t.Helper()
+
+	sqlDB, err := db.Open(dialect.SQLite, ":memory:")
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	t.Cleanup(func() { _ = sqlDB.Close() })
+
+	migrator, err := db.NewMigrator(sqlDB, dialect.SQLite)
+	if err != nil {
+		t.Fatalf("loading migrations: %v", err)
+	}
+	if _, err = migrator.Up(context.Background()); err != nil {
+		t.Fatalf("applying migrations: %v", err)
+	}
+	return sqlDB
+}
+
+// NewClient returns an ent client over a database opened by Open.
+func NewClient(t testing.TB) *ent.Client {
+	t.Helper()
+	return db.NewClient(Open(t), dialect.SQLite)
+}
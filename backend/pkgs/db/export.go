@@ -0,0 +1,312 @@
+package db
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"groove/pkgs/ent"
+	AccessToken "groove/pkgs/ent/accesstoken"
+	DataExport "groove/pkgs/ent/dataexport"
+	PlaylistImport "groove/pkgs/ent/playlistimport"
+	"groove/pkgs/ent/schema"
+	Session "groove/pkgs/ent/session"
+	User "groove/pkgs/ent/user"
+	"groove/pkgs/mail"
+	"groove/pkgs/secret"
+	. "groove/pkgs/util"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ExportTTL is how long a data export can be downloaded for once it is ready.
+const ExportTTL = 24 * time.Hour
+
+// exportReadme describes the files of a data export.
+const exportReadme = `This is everything Groove holds about your account.
+
+account.json       your profile, and the state of your email verification and two-factor authentication.
+sessions.json      the devices you are logged in on.
+spotify_link.json  the state of the link to your Spotify account.
+tokens.json        your personal access tokens: their names, scopes, and when they were created, last used and expire.
+imports.json       the playlist files you imported: their entries, the Spotify tracks they matched, and their status.
+
+Your playlists and library are stored by Spotify. Groove keeps no history or notes of its own; the playlist files
+you import are kept until a day after their import last changed.
+Secrets (your password, Spotify tokens, personal access tokens, two-factor secret and recovery codes) are only kept
+hashed or encrypted, and are not included.
+`
+
+// exportAccount is the account.json file of a data export.
+type exportAccount struct {
+	ID            int       `json:"id"`
+	Username      string    `json:"username"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	HasPassword   bool      `json:"has_password"`
+	SpotifyID     *string   `json:"spotify_id"`
+	TwoFactor     bool      `json:"two_factor"`
+	RecoveryCodes int       `json:"recovery_codes_remaining"`
+	PendingEmail  *string   `json:"pending_email"`
+	ExportedAt    time.Time `json:"exported_at"`
+}
+
+// exportSession is an entry of the sessions.json file of a data export.
+type exportSession struct {
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeen   time.Time `json:"last_seen"`
+	Expiration time.Time `json:"expiration"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+}
+
+// exportSpotifyLink is the spotify_link.json file of a data export.
+type exportSpotifyLink struct {
+	Linked                bool       `json:"linked"`
+	Revoked               bool       `json:"revoked"`
+	AccessTokenExpiration *time.Time `json:"access_token_expiration"`
+}
+
+// exportAccessToken is an entry of the tokens.json file of a data export; the token itself is never included.
+type exportAccessToken struct {
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsed   *time.Time `json:"last_used"`
+	Expiration *time.Time `json:"expiration"`
+}
+
+// exportImport is an entry of the imports.json file of a data export.
+type exportImport struct {
+	Name        string               `json:"name"`
+	Description string               `json:"description"`
+	Public      bool                 `json:"public"`
+	Format      string               `json:"format"`
+	Status      string               `json:"status"`
+	PlaylistID  *string              `json:"playlist_id"`
+	Error       *string              `json:"error"`
+	CreatedAt   time.Time            `json:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at"`
+	Entries     []schema.ImportEntry `json:"entries"`
+}
+
+// BuildExport builds the data export of a user: a zip of JSON files, described by its README.txt.
+func BuildExport(ctx context.Context, client *ent.Client, userID int) ([]byte, error) {
+	user, err := client.User.
+		Query().
+		Where(User.IDEQ(userID)).
+		WithSpotifyLink().
+		WithEmailVerification().
+		WithSession(func(q *ent.SessionQuery) {
+			q.Where(Session.ExpirationGT(time.Now())).Order(ent.Asc(Session.FieldCreatedAt))
+		}).
+		WithAccessToken(func(q *ent.AccessTokenQuery) {
+			q.Order(ent.Asc(AccessToken.FieldCreatedAt))
+		}).
+		WithPlaylistImport(func(q *ent.PlaylistImportQuery) {
+			q.Order(ent.Asc(PlaylistImport.FieldCreatedAt))
+		}).
+		First(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	recoveryCodes, err := user.QueryRecoveryCode().Count(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	account := exportAccount{
+		ID:            user.ID,
+		Username:      user.Username,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+		HasPassword:   user.Password != "",
+		SpotifyID:     user.SpotifyID,
+		TwoFactor:     user.TotpEnabled,
+		RecoveryCodes: recoveryCodes,
+		ExportedAt:    time.Now(),
+	}
+	if verification := user.Edges.EmailVerification; verification != nil && verification.Email != user.Email {
+		account.PendingEmail = &verification.Email
+	}
+
+	sessions := make([]exportSession, 0, len(user.Edges.Session))
+	for _, session := range user.Edges.Session {
+		sessions = append(sessions, exportSession{
+			CreatedAt:  session.CreatedAt,
+			LastSeen:   session.LastSeen,
+			Expiration: session.Expiration,
+			IP:         session.IP,
+			UserAgent:  session.UserAgent,
+		})
+	}
+
+	tokens := make([]exportAccessToken, 0, len(user.Edges.AccessToken))
+	for _, token := range user.Edges.AccessToken {
+		tokens = append(tokens, exportAccessToken{
+			Name:       token.Name,
+			Scopes:     token.Scopes,
+			CreatedAt:  token.CreatedAt,
+			LastUsed:   token.LastUsed,
+			Expiration: token.Expiration,
+		})
+	}
+
+	imports := make([]exportImport, 0, len(user.Edges.PlaylistImport))
+	for _, imp := range user.Edges.PlaylistImport {
+		imports = append(imports, exportImport{
+			Name:        imp.Name,
+			Description: imp.Description,
+			Public:      imp.Public,
+			Format:      imp.Format,
+			Status:      imp.Status.String(),
+			PlaylistID:  imp.PlaylistID,
+			Error:       imp.Error,
+			CreatedAt:   imp.CreatedAt,
+			UpdatedAt:   imp.UpdatedAt,
+			Entries:     imp.Entries,
+		})
+	}
+
+	var link exportSpotifyLink
+	if l := user.Edges.SpotifyLink; l != nil {
+		link = exportSpotifyLink{Linked: true, Revoked: l.Revoked, AccessTokenExpiration: &l.AccessTokenExpiration}
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	files := []struct {
+		name    string
+		content any
+	}{
+		{"account.json", account},
+		{"sessions.json", sessions},
+		{"spotify_link.json", link},
+		{"tokens.json", tokens},
+		{"imports.json", imports},
+	}
+
+	w, err := archive.Create("README.txt")
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write([]byte(exportReadme)); err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		w, err = archive.Create(file.name)
+		if err != nil {
+			return nil, err
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err = encoder.Encode(file.content); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// RunExports builds pending data exports every 10 seconds, then emails their download link.
+// Each export is claimed before it is built, so a slow run doesn't build it again on the next tick.
+func (s *Scheduler) RunExports() {
+	ctx := context.Background()
+
+	pending, err := s.client.DataExport.
+		Query().
+		Where(DataExport.StatusEQ(DataExport.StatusPending)).
+		Order(ent.Asc(DataExport.FieldCreatedAt)).
+		All(ctx)
+	if err != nil {
+		LogError("RunExports[CRON]", "Worker", err)
+		return
+	} else if len(pending) == 0 {
+		return
+	}
+
+	var built int
+	for _, export := range pending {
+		claimed, err := s.client.DataExport.
+			Update().
+			Where(
+				DataExport.IDEQ(export.ID),
+				DataExport.StatusEQ(DataExport.StatusPending),
+			).
+			SetStatus(DataExport.StatusRunning).
+			Save(ctx)
+		if err != nil {
+			LogError("RunExports[CRON]", "Claiming export "+strconv.Itoa(export.ID), err)
+			continue
+		} else if claimed == 0 {
+			continue
+		}
+
+		if err = s.runExport(ctx, export); err != nil {
+			LogError("RunExports[CRON]", "Building export "+strconv.Itoa(export.ID), err)
+			err = s.client.DataExport.UpdateOneID(export.ID).SetStatus(DataExport.StatusFailed).Exec(ctx)
+			if err != nil {
+				LogError("RunExports[CRON]", "Failing export "+strconv.Itoa(export.ID), err)
+			}
+			continue
+		}
+		built++
+	}
+
+	fmt.Printf(
+		"%s [SUCCESS] Exports Built (affected: %d)\n",
+		time.Now().Format("15:04:05"),
+		built,
+	)
+}
+
+// runExport builds a claimed export, stores it, and emails its download link to the user.
+func (s *Scheduler) runExport(ctx context.Context, export *ent.DataExport) error {
+	bundle, err := BuildExport(ctx, s.client, export.UserID)
+	if err != nil {
+		return err
+	}
+
+	token, err := secret.NewToken()
+	if err != nil {
+		return err
+	}
+
+	expiration := time.Now().Add(ExportTTL)
+	err = s.client.DataExport.
+		UpdateOneID(export.ID).
+		SetStatus(DataExport.StatusReady).
+		SetBundle(bundle).
+		SetTokenHash(secret.HashToken(token)).
+		SetExpiration(expiration).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.client.User.Get(ctx, export.UserID)
+	if err != nil {
+		return err
+	}
+
+	msg := mail.Message{
+		To:      user.Email,
+		Subject: "Your Groove data export is ready",
+		Body: "Hi " + user.Username + ",\n\n" +
+			"The export of your Groove data you asked for is ready.\n" +
+			"Follow this link within 24 hours to download it:\n\n" +
+			s.env.BackendURL + "/api/account/export/download?" + url.Values{"token": {token}}.Encode() + "\n\n" +
+			"If you didn't ask for this, change your password; someone else may be logged in to your account.\n",
+	}
+	// the export stays ready even if the email fails; the user can ask for another one.
+	if err = s.mail.Send(ctx, msg); err != nil {
+		LogError("RunExports[CRON]", "Sending export email", err)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package db_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"groove/pkgs/db"
+	"groove/pkgs/db/dbtest"
+	"groove/pkgs/ent/schema"
+	"groove/pkgs/playlistfile"
+	"groove/pkgs/secret"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// exportFiles returns the files of a data export by name.
+func exportFiles(t *testing.T, bundle []byte) map[string]string {
+	t.Helper()
+
+	archive, err := zip.NewReader(bytes.NewReader(bundle), int64(len(bundle)))
+	if err != nil {
+		t.Fatalf("reading export: %v", err)
+	}
+	files := map[string]string{}
+	for _, file := range archive.File {
+		r, err := file.Open()
+		if err != nil {
+			t.Fatalf("opening %s: %v", file.Name, err)
+		}
+		content, _ := io.ReadAll(r)
+		_ = r.Close()
+		files[file.Name] = string(content)
+	}
+	return files
+}
+
+func TestBuildExportTokens(t *testing.T) {
+	client := dbtest.NewClient(t)
+	ctx := context.Background()
+
+	user := client.User.Create().SetUsername("export1").SetEmail("export1@groove.test").SetPassword("").SaveX(ctx)
+	token, err := secret.NewToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := secret.HashToken(token)
+	client.AccessToken.Create().
+		SetUserID(user.ID).
+		SetName("backup script").
+		SetTokenHash(hash).
+		SetScopes([]string{db.ScopePlaylistsRead}).
+		SetExpiration(time.Now().Add(time.Hour)).
+		ExecX(ctx)
+
+	bundle, err := db.BuildExport(ctx, client, user.ID)
+	if err != nil {
+		t.Fatalf("building export: %v", err)
+	}
+	files := exportFiles(t, bundle)
+
+	var tokens []map[string]any
+	if err = json.Unmarshal([]byte(files["tokens.json"]), &tokens); err != nil {
+		t.Fatalf("reading tokens.json: %v", err)
+	}
+	if len(tokens) != 1 || tokens[0]["name"] != "backup script" || tokens[0]["expiration"] == nil {
+		t.Errorf("tokens.json = %v, want the backup script token", tokens)
+	}
+	for name, content := range files {
+		if strings.Contains(content, hash) || strings.Contains(content, token) {
+			t.Errorf("%s contains the token", name)
+		}
+	}
+	if !strings.Contains(files["README.txt"], "tokens.json") {
+		t.Error("README.txt doesn't describe tokens.json")
+	}
+}
+
+func TestBuildExportImports(t *testing.T) {
+	client := dbtest.NewClient(t)
+	ctx := context.Background()
+
+	user := client.User.Create().SetUsername("export2").SetEmail("export2@groove.test").SetPassword("").SaveX(ctx)
+	client.PlaylistImport.Create().
+		SetUserID(user.ID).
+		SetFormat("csv").
+		SetName("Road Trip").
+		SetEntries([]schema.ImportEntry{
+			{
+				Entry: playlistfile.Entry{Title: "Neon Avenue", Artists: []string{"Nova Lights"}},
+				Match: &schema.ImportMatch{URI: "spotify:track:7NeonAvenue0000000001", Confidence: 1, Method: "search"},
+			},
+		}).
+		ExecX(ctx)
+
+	bundle, err := db.BuildExport(ctx, client, user.ID)
+	if err != nil {
+		t.Fatalf("building export: %v", err)
+	}
+	files := exportFiles(t, bundle)
+
+	var imports []struct {
+		Name    string               `json:"name"`
+		Status  string               `json:"status"`
+		Entries []schema.ImportEntry `json:"entries"`
+	}
+	if err = json.Unmarshal([]byte(files["imports.json"]), &imports); err != nil {
+		t.Fatalf("reading imports.json: %v", err)
+	}
+	if len(imports) != 1 || imports[0].Name != "Road Trip" || imports[0].Status != "pending" {
+		t.Fatalf("imports.json = %+v, want the Road Trip import", imports)
+	}
+	if entries := imports[0].Entries; len(entries) != 1 || entries[0].Title != "Neon Avenue" || entries[0].Match == nil {
+		t.Errorf("imports.json entries = %+v, want Neon Avenue with its match", entries)
+	}
+	if !strings.Contains(files["README.txt"], "imports.json") {
+		t.Error("README.txt doesn't describe imports.json")
+	}
+}
@@ -0,0 +1,506 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"groove/pkgs/ent"
+	PlaylistImport "groove/pkgs/ent/playlistimport"
+	"groove/pkgs/ent/schema"
+	SpotifyLink "groove/pkgs/ent/spotifylink"
+	User "groove/pkgs/ent/user"
+	"groove/pkgs/playlistfile"
+	"groove/pkgs/secret"
+	"groove/pkgs/spotify"
+	. "groove/pkgs/util"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	// ImportTTL is how long a playlist import is kept after it was last updated.
+	ImportTTL = 24 * time.Hour
+	// MinMatchConfidence is the confidence under which a track found by search is not a match.
+	MinMatchConfidence = 0.5
+	// importLease is how long a run holds the claim of an import without making progress; every batch renews it.
+	// past it, the import is claimed again, i.e. after the server restarted in the middle of it.
+	importLease = 5 * time.Minute
+	// importBatch is the number of entries matched between two saves of the progress of an import.
+	importBatch = 20
+	// searchCandidates is the number of tracks of a search that are scored against an entry.
+	searchCandidates = 5
+)
+
+// errImportUnlinked is returned when the user of an import unlinked their Spotify account while it ran.
+var errImportUnlinked = errors.New("spotify link removed")
+
+// ImportURIs returns the uris of the matched entries of an import, in order.
+func ImportURIs(entries []schema.ImportEntry) []string {
+	var uris []string
+	for _, entry := range entries {
+		if entry.Match != nil {
+			uris = append(uris, entry.Match.URI)
+		}
+	}
+	return uris
+}
+
+// RunImports runs the matching of pending playlist imports, and the creation of confirmed ones, every 10 seconds.
+// Each import is claimed with a token before it runs, so a slow run doesn't run it again on the next tick; the claim
+// is renewed with every batch, and an import whose claim expired (i.e. the server restarted) is claimed again and
+// resumes where it stopped. A run that lost its claim stops at its next save.
+// Imports stopped by Spotify being rate limited or unavailable are released to resume on a later tick, and imports
+// of disabled accounts wait until the account is enabled again.
+func (s *Scheduler) RunImports() {
+	ctx := context.Background()
+
+	imports, err := s.client.PlaylistImport.
+		Query().
+		Where(
+			PlaylistImport.Or(
+				PlaylistImport.StatusIn(PlaylistImport.StatusPending, PlaylistImport.StatusConfirmed),
+				PlaylistImport.And(
+					PlaylistImport.StatusIn(PlaylistImport.StatusMatching, PlaylistImport.StatusCreating),
+					PlaylistImport.Or(
+						PlaylistImport.ClaimExpirationIsNil(),
+						PlaylistImport.ClaimExpirationLT(time.Now()),
+					),
+				),
+			),
+			PlaylistImport.HasUserWith(User.DisabledAtIsNil()),
+		).
+		Order(ent.Asc(PlaylistImport.FieldCreatedAt)).
+		All(ctx)
+	if err != nil {
+		LogError("RunImports[CRON]", "Worker", err)
+		return
+	} else if len(imports) == 0 {
+		return
+	}
+
+	var ran int
+	for _, imp := range imports {
+		status := PlaylistImport.StatusMatching
+		if imp.Status == PlaylistImport.StatusConfirmed || imp.Status == PlaylistImport.StatusCreating {
+			status = PlaylistImport.StatusCreating
+		}
+
+		claim, err := secret.NewToken()
+		if err != nil {
+			LogError("RunImports[CRON]", "Claiming import "+strconv.Itoa(imp.ID), err)
+			continue
+		}
+
+		// only one of concurrent runs replaces the claim it found.
+		current := PlaylistImport.ClaimIsNil()
+		if imp.Claim != nil {
+			current = PlaylistImport.ClaimEQ(*imp.Claim)
+		}
+		claimed, err := s.client.PlaylistImport.
+			Update().
+			Where(
+				PlaylistImport.IDEQ(imp.ID),
+				PlaylistImport.StatusEQ(imp.Status),
+				current,
+			).
+			SetStatus(status).
+			SetClaim(claim).
+			SetClaimExpiration(time.Now().Add(importLease)).
+			Save(ctx)
+		if err != nil {
+			LogError("RunImports[CRON]", "Claiming import "+strconv.Itoa(imp.ID), err)
+			continue
+		} else if claimed == 0 {
+			continue
+		}
+		imp.Claim = &claim
+
+		if status == PlaylistImport.StatusMatching {
+			err = s.matchImport(ctx, imp)
+		} else {
+			err = s.createImport(ctx, imp)
+		}
+		if err != nil {
+			if ent.IsNotFound(err) {
+				// deleted by the user while it ran, or claimed by another run after this one stalled.
+				continue
+			}
+			if transient(err) {
+				// the limits of Spotify are per app, so the other imports would be stopped too.
+				s.releaseImport(ctx, imp, status, err)
+				break
+			}
+			s.failImport(ctx, imp, status, err)
+			continue
+		}
+		ran++
+	}
+
+	fmt.Printf(
+		"%s [SUCCESS] Imports Run (affected: %d)\n",
+		time.Now().Format("15:04:05"),
+		ran,
+	)
+}
+
+// failImport marks an import as failed, with a reason the user can act upon.
+func (s *Scheduler) failImport(ctx context.Context, imp *ent.PlaylistImport, status PlaylistImport.Status, err error) {
+	reason := "error matching tracks, try again later"
+	if status == PlaylistImport.StatusCreating {
+		reason = "error creating the playlist, try again later"
+	}
+	if errors.Is(err, ErrLinkRevoked) || errors.Is(err, errImportUnlinked) {
+		reason = "spotify link revoked, relink your account"
+	} else {
+		LogError("RunImports[CRON]", "Running import "+strconv.Itoa(imp.ID), err)
+	}
+
+	err = s.saveImport(imp).
+		SetStatus(PlaylistImport.StatusFailed).
+		SetError(reason).
+		Exec(ctx)
+	if err != nil && !ent.IsNotFound(err) {
+		LogError("RunImports[CRON]", "Failing import "+strconv.Itoa(imp.ID), err)
+	}
+}
+
+// transient reports whether an error of Spotify is worth retrying later: it is rate limited or unavailable.
+func transient(err error) bool {
+	status := spotify.StatusOf(err)
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+// releaseImport gives a claimed import back to the next tick, which resumes it from its saved progress.
+func (s *Scheduler) releaseImport(ctx context.Context, imp *ent.PlaylistImport, status PlaylistImport.Status, err error) {
+	LogError("RunImports[CRON]", "Pausing import "+strconv.Itoa(imp.ID), err)
+
+	released := PlaylistImport.StatusPending
+	if status == PlaylistImport.StatusCreating {
+		released = PlaylistImport.StatusConfirmed
+	}
+	err = s.saveImport(imp).
+		SetStatus(released).
+		Exec(ctx)
+	if err != nil && !ent.IsNotFound(err) {
+		LogError("RunImports[CRON]", "Releasing import "+strconv.Itoa(imp.ID), err)
+	}
+}
+
+// saveImport updates an import claimed by this run, renewing its claim; the update fails as not found
+// if the import was deleted, or claimed by another run since.
+func (s *Scheduler) saveImport(imp *ent.PlaylistImport) *ent.PlaylistImportUpdateOne {
+	return s.client.PlaylistImport.
+		UpdateOneID(imp.ID).
+		Where(PlaylistImport.ClaimEQ(*imp.Claim)).
+		SetClaimExpiration(time.Now().Add(importLease))
+}
+
+// importAccess returns an access token of the user of an import, refreshing it if it expires within a minute.
+func (s *Scheduler) importAccess(ctx context.Context, userID int) (string, error) {
+	link, err := s.client.SpotifyLink.
+		Query().
+		Where(SpotifyLink.UserIDEQ(userID)).
+		First(ctx)
+	if ent.IsNotFound(err) {
+		return "", errImportUnlinked
+	} else if err != nil {
+		return "", err
+	}
+
+	link, err = s.refresher.Refresh(ctx, link.ID, time.Now().Add(time.Minute))
+	if err != nil {
+		return "", err
+	}
+	return link.AccessToken, nil
+}
+
+// matchImport matches the entries of a claimed import to Spotify tracks, saving its progress every importBatch
+// entries; then leaves it for the user to review.
+func (s *Scheduler) matchImport(ctx context.Context, imp *ent.PlaylistImport) error {
+	entries := imp.Entries
+
+	var access string
+	for i := imp.Progress; i < len(entries); i++ {
+		if (i-imp.Progress)%importBatch == 0 {
+			if i > imp.Progress {
+				err := s.saveImport(imp).
+					SetEntries(entries).
+					SetProgress(i).
+					Exec(ctx)
+				if err != nil {
+					return err
+				}
+			}
+
+			var err error
+			if access, err = s.importAccess(ctx, imp.UserID); err != nil {
+				return err
+			}
+		}
+
+		match, err := s.matchEntry(ctx, access, entries[i].Entry)
+		if err != nil {
+			return err
+		}
+		entries[i].Match = match
+	}
+
+	return s.saveImport(imp).
+		SetEntries(entries).
+		SetProgress(len(entries)).
+		SetStatus(PlaylistImport.StatusReview).
+		Exec(ctx)
+}
+
+// matchEntry finds the Spotify track of an entry: by its Spotify uri or ISRC if it has one (an exact match),
+// otherwise by searching its title and artists and scoring the results; returns nil if no track matched.
+func (s *Scheduler) matchEntry(ctx context.Context, access string, entry playlistfile.Entry) (*schema.ImportMatch, error) {
+	if id, found := strings.CutPrefix(entry.URI, "spotify:track:"); found {
+		track, err := s.spotify.GetTrack(ctx, access, id, "")
+		if err == nil {
+			return trackMatch(track, 1, "uri"), nil
+		} else if !unmatched(err) {
+			return nil, err
+		}
+	}
+
+	if entry.ISRC != "" {
+		result, err := s.spotify.Search(ctx, access, spotify.SearchRequest{
+			Query: "isrc:" + entry.ISRC,
+			Types: "track",
+			Limit: 1,
+		})
+		if err != nil && !unmatched(err) {
+			return nil, err
+		} else if err == nil && result.Tracks != nil && len(result.Tracks.Items) > 0 {
+			return trackMatch(&result.Tracks.Items[0], 1, "isrc"), nil
+		}
+	}
+
+	// searched without what other services add to names (i.e. "(Remastered)"), which would match nothing.
+	title := strings.Join(matchWords(entry.Title), " ")
+	if title == "" {
+		return nil, nil
+	}
+
+	// the fielded query is precise, the free one forgiving of differences in spelling.
+	queries := []string{`track:"` + title + `"`, title}
+	var artist string
+	if len(entry.Artists) > 0 {
+		artist = strings.Join(matchWords(entry.Artists[0]), " ")
+	}
+	if artist != "" {
+		queries = []string{`track:"` + title + `" artist:"` + artist + `"`, title + " " + artist}
+	}
+
+	var best *schema.ImportMatch
+	for _, query := range queries {
+		result, err := s.spotify.Search(ctx, access, spotify.SearchRequest{
+			Query: query,
+			Types: "track",
+			Limit: searchCandidates,
+		})
+		if err != nil {
+			if unmatched(err) {
+				continue
+			}
+			return nil, err
+		}
+		if result.Tracks == nil {
+			continue
+		}
+
+		for i := range result.Tracks.Items {
+			track := &result.Tracks.Items[i]
+			if confidence := matchConfidence(entry, track); best == nil || confidence > best.Confidence {
+				best = trackMatch(track, confidence, "search")
+			}
+		}
+		if best != nil && best.Confidence >= MinMatchConfidence {
+			return best, nil
+		}
+	}
+	return nil, nil
+}
+
+// unmatched reports whether a Spotify error means the entry has no match, rather than that matching failed.
+func unmatched(err error) bool {
+	status := spotify.StatusOf(err)
+	return status == http.StatusBadRequest || status == http.StatusNotFound
+}
+
+func trackMatch(track *spotify.Track, confidence float64, method string) *schema.ImportMatch {
+	match := &schema.ImportMatch{
+		URI:        track.URI,
+		Title:      track.Name,
+		Album:      track.Album.Name,
+		DurationMs: track.DurationMs,
+		// two decimals are plenty to review.
+		Confidence: float64(int(confidence*100+0.5)) / 100,
+		Method:     method,
+	}
+	for _, artist := range track.Artists {
+		match.Artists = append(match.Artists, artist.Name)
+	}
+	return match
+}
+
+// matchConfidence scores how likely a track found by search is the entry, between 0 and 1:
+// mostly by the similarity of their titles, then of their artists, then by how close their durations are.
+// artists and duration only count if the entry has them.
+func matchConfidence(entry playlistfile.Entry, track *spotify.Track) float64 {
+	score, weight := 0.55*similarity(entry.Title, track.Name), 0.55
+
+	if len(entry.Artists) > 0 {
+		var artists float64
+		for _, want := range entry.Artists {
+			for _, got := range track.Artists {
+				if s := similarity(want, got.Name); s > artists {
+					artists = s
+				}
+			}
+		}
+		score, weight = score+0.3*artists, weight+0.3
+	}
+
+	if entry.Duration > 0 {
+		// within 2 seconds is the same recording; 30 seconds apart is another one.
+		diff := entry.Duration - time.Duration(track.DurationMs)*time.Millisecond
+		if diff < 0 {
+			diff = -diff
+		}
+		duration := 1 - float64(diff-2*time.Second)/float64(28*time.Second)
+		if duration > 1 {
+			duration = 1
+		} else if duration < 0 {
+			duration = 0
+		}
+		score, weight = score+0.15*duration, weight+0.15
+	}
+	return score / weight
+}
+
+// similarity is the share of words two names have in common (the Sørensen–Dice coefficient of their words),
+// once normalized; 1 if they are the same.
+func similarity(a, b string) float64 {
+	wordsA, wordsB := matchWords(a), matchWords(b)
+	if len(wordsA) == 0 || len(wordsB) == 0 {
+		return 0
+	}
+
+	counts := map[string]int{}
+	for _, word := range wordsA {
+		counts[word]++
+	}
+	var common int
+	for _, word := range wordsB {
+		if counts[word] > 0 {
+			counts[word]--
+			common++
+		}
+	}
+	return 2 * float64(common) / float64(len(wordsA)+len(wordsB))
+}
+
+// matchWords normalizes a name to its words: lowercase, without punctuation, nor what is usually left out of
+// a name by other services (i.e. "(feat. ...)", "[Live]", " - Remastered 2011").
+func matchWords(name string) []string {
+	name = strings.ToLower(name)
+	if before, _, found := strings.Cut(name, " - "); found && before != "" {
+		name = before
+	}
+
+	var b strings.Builder
+	depth := 0
+	for _, r := range name {
+		switch {
+		case r == '(' || r == '[':
+			depth++
+		case (r == ')' || r == ']') && depth > 0:
+			depth--
+		case depth > 0:
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		default:
+			b.WriteRune(' ')
+		}
+	}
+	return strings.Fields(b.String())
+}
+
+// createImport creates the playlist of a confirmed import and adds its matched tracks, saving its progress after
+// every request; then marks it done.
+func (s *Scheduler) createImport(ctx context.Context, imp *ent.PlaylistImport) error {
+	access, err := s.importAccess(ctx, imp.UserID)
+	if err != nil {
+		return err
+	}
+
+	progress := imp.Progress
+	// resumed imports already have their playlist.
+	if imp.PlaylistID != nil {
+		// a batch Spotify answered with an error may still have been added; the playlist knows.
+		playlist, err := s.spotify.GetPlaylist(ctx, access, *imp.PlaylistID, "")
+		if err != nil {
+			return err
+		}
+		if total := playlist.Tracks.Total; total > progress {
+			progress = total
+		}
+	} else {
+		user, err := s.spotify.GetCurrentUser(ctx, access)
+		if err != nil {
+			return err
+		}
+		playlist, err := s.spotify.CreatePlaylist(ctx, access, spotify.CreatePlaylistRequest{
+			UserID:      user.ID,
+			Name:        imp.Name,
+			Description: imp.Description,
+			Public:      imp.Public,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = s.saveImport(imp).
+			SetPlaylistID(playlist.ID).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		imp.PlaylistID = &playlist.ID
+	}
+
+	uris := ImportURIs(imp.Entries)
+	for start := progress; start < len(uris); start += spotify.MaxItemsPerRequest {
+		end := start + spotify.MaxItemsPerRequest
+		if end > len(uris) {
+			end = len(uris)
+		}
+
+		if access, err = s.importAccess(ctx, imp.UserID); err != nil {
+			return err
+		}
+		_, err = s.spotify.AddPlaylistItems(ctx, access, spotify.AddItemsRequest{
+			PlaylistID: *imp.PlaylistID,
+			URIs:       uris[start:end],
+		})
+		if err != nil {
+			return err
+		}
+
+		err = s.saveImport(imp).
+			SetProgress(end).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.saveImport(imp).
+		SetStatus(PlaylistImport.StatusDone).
+		Exec(ctx)
+}
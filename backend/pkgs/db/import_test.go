@@ -0,0 +1,207 @@
+package db_test
+
+import (
+	"context"
+	"groove/pkgs/db"
+	"groove/pkgs/db/dbtest"
+	"groove/pkgs/ent"
+	PlaylistImport "groove/pkgs/ent/playlistimport"
+	"groove/pkgs/ent/schema"
+	"groove/pkgs/playlistfile"
+	"groove/pkgs/secret"
+	"groove/pkgs/spotify"
+	"groove/pkgs/spotify/spotifytest"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type importTest struct {
+	client    *ent.Client
+	fake      *spotifytest.Server
+	spotify   *spotify.Client
+	scheduler *db.Scheduler
+	userID    int
+}
+
+// newImportTest sets up a scheduler and a user linked to the Spotify user of the fake.
+func newImportTest(t *testing.T) *importTest {
+	t.Helper()
+
+	keyring, err := secret.ParseKeyring("k1:MDEyMzQ1Njc4OTAxMjM0NTY3ODkwMTIzNDU2Nzg5MDE=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret.Use(keyring)
+
+	fake := spotifytest.NewServer()
+	t.Cleanup(fake.Close)
+	client := dbtest.NewClient(t)
+	sp := spotify.New(fake.Config())
+	ctx := context.Background()
+
+	user := client.User.Create().SetUsername("import1").SetEmail("import1@groove.test").SetPassword("").SaveX(ctx)
+	tokens := fake.Link(spotifytest.UserID)
+	client.SpotifyLink.Create().
+		SetUserID(user.ID).
+		SetAccessToken(tokens.AccessToken).
+		SetAccessTokenExpiration(time.Now().Add(time.Hour)).
+		SetRefreshToken(tokens.RefreshToken).
+		ExecX(ctx)
+
+	return &importTest{client: client, fake: fake, spotify: sp, scheduler: db.NewTestScheduler(client, sp), userID: user.ID}
+}
+
+func (it *importTest) create(entries ...playlistfile.Entry) *ent.PlaylistImport {
+	imported := make([]schema.ImportEntry, len(entries))
+	for i, entry := range entries {
+		imported[i] = schema.ImportEntry{Entry: entry}
+	}
+	return it.client.PlaylistImport.Create().
+		SetUserID(it.userID).
+		SetFormat("csv").
+		SetName("Imported").
+		SetEntries(imported).
+		SaveX(context.Background())
+}
+
+func (it *importTest) get(id int) *ent.PlaylistImport {
+	return it.client.PlaylistImport.GetX(context.Background(), id)
+}
+
+func TestRunImportsMatches(t *testing.T) {
+	it := newImportTest(t)
+	imp := it.create(
+		playlistfile.Entry{Title: "Whatever", ISRC: "USGRV2100002"},
+		playlistfile.Entry{Title: "Neon Avenue (Remastered)", Artists: []string{"Nova Lights"}, Duration: 214 * time.Second},
+		playlistfile.Entry{URI: "spotify:track:7Undertow000000000005"},
+		playlistfile.Entry{Title: "Completely Unknown Song", Artists: []string{"Someone"}},
+	)
+
+	it.scheduler.RunImports()
+	imp = it.get(imp.ID)
+	if imp.Status != PlaylistImport.StatusReview || imp.Progress != 4 {
+		t.Fatalf("status = %s (%d/4), want review", imp.Status, imp.Progress)
+	}
+
+	want := []struct{ uri, method string }{
+		{"spotify:track:7Afterglow00000000002", "isrc"},
+		{"spotify:track:7NeonAvenue0000000001", "search"},
+		{"spotify:track:7Undertow000000000005", "uri"},
+		{"", ""},
+	}
+	for i, entry := range imp.Entries {
+		var uri, method string
+		if entry.Match != nil {
+			uri, method = entry.Match.URI, entry.Match.Method
+		}
+		if uri != want[i].uri || method != want[i].method {
+			t.Errorf("entry %d matched %q by %q, want %q by %q", i, uri, method, want[i].uri, want[i].method)
+		}
+	}
+}
+
+func TestRunImportsReleasesOnTransientErrors(t *testing.T) {
+	it := newImportTest(t)
+	imp := it.create(playlistfile.Entry{Title: "Afterglow", Artists: []string{"Nova Lights"}})
+
+	it.fake.Fail(spotifytest.Fault{Path: "/v1/search", Status: http.StatusTooManyRequests, RetryAfter: time.Hour})
+	it.scheduler.RunImports()
+	if imp = it.get(imp.ID); imp.Status != PlaylistImport.StatusPending || imp.Error != nil {
+		t.Fatalf("status = %s (error %v) while rate limited, want pending", imp.Status, imp.Error)
+	}
+
+	it.fake.ClearFaults()
+	it.scheduler.RunImports()
+	if imp = it.get(imp.ID); imp.Status != PlaylistImport.StatusReview {
+		t.Fatalf("status = %s once no longer rate limited, want review", imp.Status)
+	}
+}
+
+func TestRunImportsClaims(t *testing.T) {
+	it := newImportTest(t)
+	ctx := context.Background()
+	entry := playlistfile.Entry{Title: "Afterglow", Artists: []string{"Nova Lights"}}
+
+	// the claim of a run that stopped making progress expired: the import is claimed again.
+	stalled := it.create(entry)
+	it.client.PlaylistImport.UpdateOne(stalled).
+		SetStatus(PlaylistImport.StatusMatching).
+		SetClaim("stalled").
+		SetClaimExpiration(time.Now().Add(-time.Second)).
+		ExecX(ctx)
+	// the claim of a live run holds, however long since the import was last updated.
+	running := it.create(entry)
+	it.client.PlaylistImport.UpdateOne(running).
+		SetStatus(PlaylistImport.StatusMatching).
+		SetClaim("running").
+		SetClaimExpiration(time.Now().Add(time.Minute)).
+		SetUpdatedAt(time.Now().Add(-time.Hour)).
+		ExecX(ctx)
+
+	it.scheduler.RunImports()
+	if stalled = it.get(stalled.ID); stalled.Status != PlaylistImport.StatusReview || *stalled.Claim == "stalled" {
+		t.Errorf("stalled import: status = %s, claim %q, want review under a new claim", stalled.Status, *stalled.Claim)
+	}
+	if running = it.get(running.ID); running.Status != PlaylistImport.StatusMatching || *running.Claim != "running" {
+		t.Errorf("running import: status = %s, claim %q, want matching under its claim", running.Status, *running.Claim)
+	}
+	if calls := it.fake.Calls(http.MethodGet, "/v1/search"); calls != 1 {
+		t.Errorf("searched spotify %d times, want once for the stalled import", calls)
+	}
+}
+
+func TestRunImportsSkipsDisabledUsers(t *testing.T) {
+	it := newImportTest(t)
+	imp := it.create(playlistfile.Entry{Title: "Afterglow", Artists: []string{"Nova Lights"}})
+	it.client.User.UpdateOneID(it.userID).SetDisabledAt(time.Now()).ExecX(context.Background())
+
+	it.scheduler.RunImports()
+	if imp = it.get(imp.ID); imp.Status != PlaylistImport.StatusPending {
+		t.Fatalf("status = %s for a disabled user, want pending", imp.Status)
+	}
+	if calls := it.fake.Calls(http.MethodGet, "/v1/search"); calls > 0 {
+		t.Errorf("searched spotify %d times for a disabled user", calls)
+	}
+}
+
+func TestRunImportsResumesFromPlaylist(t *testing.T) {
+	it := newImportTest(t)
+	ctx := context.Background()
+	uris := []string{"spotify:track:7NeonAvenue0000000001", "spotify:track:7Afterglow00000000002", "spotify:track:7Undertow000000000005"}
+
+	// the first batch was added, but its progress not saved (i.e. Spotify answered with an error after adding it).
+	tokens := it.client.SpotifyLink.Query().OnlyX(ctx)
+	playlist, err := it.spotify.CreatePlaylist(ctx, tokens.AccessToken, spotify.CreatePlaylistRequest{UserID: spotifytest.UserID, Name: "Imported"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = it.spotify.AddPlaylistItems(ctx, tokens.AccessToken, spotify.AddItemsRequest{PlaylistID: playlist.ID, URIs: uris[:2]}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := make([]schema.ImportEntry, len(uris))
+	for i, uri := range uris {
+		entries[i] = schema.ImportEntry{Entry: playlistfile.Entry{URI: uri}, Match: &schema.ImportMatch{URI: uri, Confidence: 1, Method: "uri"}}
+	}
+	imp := it.client.PlaylistImport.Create().
+		SetUserID(it.userID).
+		SetFormat("csv").
+		SetName("Imported").
+		SetEntries(entries).
+		SetStatus(PlaylistImport.StatusConfirmed).
+		SetPlaylistID(playlist.ID).
+		SaveX(ctx)
+
+	it.scheduler.RunImports()
+	if imp = it.get(imp.ID); imp.Status != PlaylistImport.StatusDone {
+		t.Fatalf("status = %s (error %v), want done", imp.Status, imp.Error)
+	}
+	resumed, err := it.spotify.GetPlaylist(ctx, tokens.AccessToken, playlist.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resumed.Tracks.Total != len(uris) {
+		t.Errorf("playlist has %d tracks, want %d", resumed.Tracks.Total, len(uris))
+	}
+}
@@ -0,0 +1,283 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"entgo.io/ent/dialect"
+	"errors"
+	"fmt"
+	"go.uber.org/fx"
+	"groove/pkgs/env"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+ * The database schema is managed through versioned migrations in migrations/<dialect>, generated from the ent
+ * schema (see migrations/generate.go) and embedded in the binary. Applied versions are recorded in the
+ * schema_migrations table. Migrations are applied with `./main migrate up`, never on startup: the server
+ * refuses to start while the database is behind the migrations it was compiled with.
+ */
+
+//go:embed migrations/*/*.sql
+var migrationFiles embed.FS
+
+// Migration is a single versioned migration.
+type Migration struct {
+	Version string
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus is a Migration along with the time it was applied (nil if pending).
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Migrator applies and rolls back the migrations of a dialect.
+type Migrator struct {
+	db         *sql.DB
+	dialect    string
+	migrations []Migration
+}
+
+func ProvideMigrator(db *sql.DB, env *env.Env) (*Migrator, error) {
+	return NewMigrator(db, env.DBDriver)
+}
+
+func NewMigrator(db *sql.DB, dialect string) (*Migrator, error) {
+	migrations, err := loadMigrations(dialect)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, dialect: dialect, migrations: migrations}, nil
+}
+
+// InvokeMigrationCheck refuses to start the app while the database has pending migrations.
+func InvokeMigrationCheck(lc fx.Lifecycle, migrator *Migrator) {
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			pending, err := migrator.Pending(ctx)
+			if err != nil {
+				return fmt.Errorf("checking migrations: %w", err)
+			}
+			if len(pending) > 0 {
+				return fmt.Errorf(
+					"database is behind the compiled schema (%d pending migrations, next: %s_%s): run `./main migrate up`",
+					len(pending), pending[0].Version, pending[0].Name,
+				)
+			}
+			return nil
+		},
+	})
+}
+
+// loadMigrations reads the embedded migrations of a dialect, ordered by version.
+// files are named <version>_<name>.up.sql and <version>_<name>.down.sql.
+func loadMigrations(dialect string) ([]Migration, error) {
+	dir := path.Join("migrations", dialect)
+	names, err := fs.Glob(migrationFiles, path.Join(dir, "*.up.sql"))
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no migrations for dialect %q", dialect)
+	}
+	sort.Strings(names)
+
+	migrations := make([]Migration, len(names))
+	for i, name := range names {
+		base := strings.TrimSuffix(path.Base(name), ".up.sql")
+		version, desc, _ := strings.Cut(base, "_")
+
+		up, err := fs.ReadFile(migrationFiles, name)
+		if err != nil {
+			return nil, err
+		}
+		down, err := fs.ReadFile(migrationFiles, path.Join(dir, base+".down.sql"))
+		if err != nil {
+			return nil, fmt.Errorf("migration %s has no down migration: %w", base, err)
+		}
+
+		migrations[i] = Migration{Version: version, Name: desc, Up: string(up), Down: string(down)}
+	}
+	return migrations, nil
+}
+
+// init creates the schema_migrations table if needed.
+func (m *Migrator) init(ctx context.Context) error {
+	_, err := m.db.ExecContext(ctx,
+		`CREATE TABLE IF NOT EXISTS schema_migrations (version varchar(32) PRIMARY KEY, applied_at timestamp NOT NULL)`,
+	)
+	return err
+}
+
+// applied returns the applied versions and when they were applied.
+func (m *Migrator) applied(ctx context.Context) (map[string]time.Time, error) {
+	if err := m.init(ctx); err != nil {
+		return nil, err
+	}
+
+	rows, err := m.db.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[string]time.Time{}
+	for rows.Next() {
+		var version string
+		var appliedAt time.Time
+		if err = rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// Status returns every migration along with whether (and when) it was applied.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, len(m.migrations))
+	for i, migration := range m.migrations {
+		statuses[i] = MigrationStatus{Migration: migration}
+		if appliedAt, ok := applied[migration.Version]; ok {
+			statuses[i].AppliedAt = &appliedAt
+		}
+	}
+	return statuses, nil
+}
+
+// Pending returns the migrations that haven't been applied, in order.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			pending = append(pending, status.Migration)
+		}
+	}
+	return pending, nil
+}
+
+// Up applies every pending migration in order, each in its own transaction.
+// returns the applied migrations; on error, the ones applied before the failing one.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, migration := range pending {
+		err = m.exec(ctx, migration.Up,
+			`INSERT INTO schema_migrations (version, applied_at) VALUES (`+m.placeholder(1)+`, `+m.placeholder(2)+`)`,
+			migration.Version, time.Now().UTC(),
+		)
+		if err != nil {
+			return done, fmt.Errorf("applying %s_%s: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Down rolls back the last n applied migrations, most recent first.
+// returns the rolled back migrations; on error, the ones rolled back before the failing one.
+func (m *Migrator) Down(ctx context.Context, n int) ([]Migration, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(statuses) - 1; i >= 0 && len(done) < n; i-- {
+		if statuses[i].AppliedAt == nil {
+			continue
+		}
+
+		migration := statuses[i].Migration
+		err = m.exec(ctx, migration.Down,
+			`DELETE FROM schema_migrations WHERE version = `+m.placeholder(1),
+			migration.Version,
+		)
+		if err != nil {
+			return done, fmt.Errorf("rolling back %s_%s: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+
+	if len(done) == 0 {
+		return nil, errors.New("no applied migrations to roll back")
+	}
+	return done, nil
+}
+
+// Baseline records every migration up to and including version as applied, without running them.
+// used once for databases created before versioned migrations (when the server created the schema on startup).
+func (m *Migrator) Baseline(ctx context.Context, version string) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, migration := range m.migrations {
+		if migration.Version > version {
+			break
+		}
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+
+		_, err = m.db.ExecContext(ctx,
+			`INSERT INTO schema_migrations (version, applied_at) VALUES (`+m.placeholder(1)+`, `+m.placeholder(2)+`)`,
+			migration.Version, time.Now().UTC(),
+		)
+		if err != nil {
+			return done, err
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// exec runs the statements of a migration file along with the bookkeeping query in a single transaction.
+func (m *Migrator) exec(ctx context.Context, statements, bookkeeping string, args ...any) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err = tx.ExecContext(ctx, statements); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, bookkeeping, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// placeholder returns the i-th (starting at 1) bind parameter of the dialect.
+func (m *Migrator) placeholder(i int) string {
+	if m.dialect == dialect.SQLite {
+		return "?"
+	}
+	return fmt.Sprintf("$%d", i)
+}
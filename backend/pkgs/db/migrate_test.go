@@ -0,0 +1,99 @@
+package db_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"entgo.io/ent/dialect"
+	"entgo.io/ent/dialect/sql/schema"
+	"groove/pkgs/db"
+	"groove/pkgs/db/dbtest"
+	"strings"
+	"testing"
+)
+
+// tables returns the tables of a SQLite database, other than the bookkeeping of SQLite and of the migrator.
+func tables(t *testing.T, sqlDB *sql.DB) []string {
+	t.Helper()
+
+	rows, err := sqlDB.Query(`SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' AND name <> 'schema_migrations' ORDER BY name`)
+	if err != nil {
+		t.Fatalf("listing tables: %v", err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			t.Fatalf("listing tables: %v", err)
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestMigrationsMatchSchema(t *testing.T) {
+	sqlDB := dbtest.Open(t)
+	ctx := context.Background()
+
+	migrator, err := db.NewMigrator(sqlDB, dialect.SQLite)
+	if err != nil {
+		t.Fatalf("loading migrations: %v", err)
+	}
+	pending, err := migrator.Pending(ctx)
+	if err != nil {
+		t.Fatalf("checking migrations: %v", err)
+	}
+	if len(pending) > 0 {
+		t.Fatalf("%d migrations still pending after up", len(pending))
+	}
+
+	// a dry run of the ent schema against the migrated database prints the statements it would need.
+	var diff bytes.Buffer
+	err = db.NewClient(sqlDB, dialect.SQLite).Schema.WriteTo(ctx, &diff, schema.WithDropColumn(true), schema.WithDropIndex(true))
+	if err != nil {
+		t.Fatalf("diffing schema: %v", err)
+	}
+	// only the statements wrapping the changes are printed when there are none.
+	wrapping := map[string]bool{"": true, "BEGIN;": true, "COMMIT;": true, "PRAGMA foreign_keys = off;": true, "PRAGMA foreign_keys = on;": true}
+	for _, line := range strings.Split(diff.String(), "\n") {
+		if line = strings.TrimSpace(line); !wrapping[line] {
+			t.Errorf("migrations differ from the ent schema, missing: %s", line)
+		}
+	}
+}
+
+func TestMigrationsRollBack(t *testing.T) {
+	sqlDB := dbtest.Open(t)
+	ctx := context.Background()
+
+	if len(tables(t, sqlDB)) == 0 {
+		t.Fatal("no tables after up")
+	}
+
+	migrator, err := db.NewMigrator(sqlDB, dialect.SQLite)
+	if err != nil {
+		t.Fatalf("loading migrations: %v", err)
+	}
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("checking migrations: %v", err)
+	}
+
+	down, err := migrator.Down(ctx, len(statuses))
+	if err != nil {
+		t.Fatalf("rolling back migrations: %v", err)
+	}
+	if len(down) != len(statuses) {
+		t.Fatalf("rolled back %d of %d migrations", len(down), len(statuses))
+	}
+	if left := tables(t, sqlDB); len(left) > 0 {
+		t.Fatalf("tables left after rolling back every migration: %v", left)
+	}
+
+	// the migrations apply again once rolled back.
+	if _, err = migrator.Up(ctx); err != nil {
+		t.Fatalf("reapplying migrations: %v", err)
+	}
+}
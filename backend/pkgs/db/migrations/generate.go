@@ -0,0 +1,76 @@
+//go:build ignore
+
+/*
+ * generate writes a new versioned migration to the migration directory of a dialect, by diffing the ent schema
+ * against the state the existing migrations produce when replayed on an empty dev database.
+ *
+ * usage (from backend/):
+ *   go run -mod=mod ./pkgs/db/migrations/generate.go -dev-url "postgres://localhost:5432/dev?sslmode=disable" <name>
+ *   go run -mod=mod ./pkgs/db/migrations/generate.go -dialect sqlite3 -dev-url "sqlite://dev?mode=memory&_fk=1" <name>
+ *   go run -mod=mod ./pkgs/db/migrations/generate.go -hash   (re-hashes atlas.sum after editing a migration by hand)
+ *
+ * every change to the ent schema needs a migration for both dialects.
+ * migrations run in a transaction, where SQLite ignores `PRAGMA foreign_keys = off`: when Atlas rebuilds a
+ * SQLite table (new_<table>, copy, drop), dropping the old table cascades to the rows referencing it. rewrite
+ * such migrations with ALTER TABLE (ADD/DROP/RENAME COLUMN) whenever possible.
+ * review the generated .up.sql/.down.sql files, then apply them with `./main migrate up`.
+ */
+
+package main
+
+import (
+	"ariga.io/atlas/sql/migrate"
+	"ariga.io/atlas/sql/sqltool"
+	"context"
+	"entgo.io/ent/dialect"
+	"entgo.io/ent/dialect/sql/schema"
+	"flag"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+	entmigrate "groove/pkgs/ent/migrate"
+	"log"
+	"path/filepath"
+)
+
+func main() {
+	devURL := flag.String("dev-url", "", "url of an empty database used to replay the migrations")
+	name := flag.String("dialect", dialect.Postgres, "dialect of the migrations to generate")
+	hash := flag.Bool("hash", false, "only re-hash the migration directory")
+	flag.Parse()
+
+	dir, err := sqltool.NewGolangMigrateDir(filepath.Join("pkgs", "db", "migrations", *name))
+	if err != nil {
+		log.Fatalf("opening migration directory: %v", err)
+	}
+
+	if *hash {
+		sum, err := dir.Checksum()
+		if err != nil {
+			log.Fatalf("hashing migration directory: %v", err)
+		}
+		if err = migrate.WriteSumFile(dir, sum); err != nil {
+			log.Fatalf("writing atlas.sum: %v", err)
+		}
+		return
+	}
+
+	if *devURL == "" || flag.NArg() != 1 {
+		log.Fatalln("usage: generate.go -dev-url <url> <migration-name>")
+	}
+
+	m, err := schema.NewMigrateURL(*devURL,
+		schema.WithDir(dir),
+		schema.WithMigrationMode(schema.ModeReplay),
+		schema.WithDialect(*name),
+		schema.WithFormatter(sqltool.GolangMigrateFormatter),
+		schema.WithDropColumn(true),
+		schema.WithDropIndex(true),
+	)
+	if err != nil {
+		log.Fatalf("connecting to dev database: %v", err)
+	}
+
+	if err = m.NamedDiff(context.Background(), flag.Arg(0), entmigrate.Tables...); err != nil {
+		log.Fatalf("generating migration: %v", err)
+	}
+}
@@ -0,0 +1,16 @@
+-- Create "users" table
+CREATE TABLE "users" ("id" bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY, "username" character varying NOT NULL, "password" character varying NOT NULL, "email" character varying NOT NULL, PRIMARY KEY ("id"));
+-- Create index "users_email_key" to table: "users"
+CREATE UNIQUE INDEX "users_email_key" ON "users" ("email");
+-- Create index "users_username_key" to table: "users"
+CREATE UNIQUE INDEX "users_username_key" ON "users" ("username");
+-- Create "oauth_states" table
+CREATE TABLE "oauth_states" ("id" bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY, "state" character varying NOT NULL, "expiration" timestamptz NOT NULL, "user_id" bigint NOT NULL, PRIMARY KEY ("id"), CONSTRAINT "oauth_states_users_oauth_state" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON UPDATE NO ACTION ON DELETE CASCADE);
+-- Create index "oauth_states_user_id_key" to table: "oauth_states"
+CREATE UNIQUE INDEX "oauth_states_user_id_key" ON "oauth_states" ("user_id");
+-- Create "sessions" table
+CREATE TABLE "sessions" ("id" bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY, "token" character varying NOT NULL, "csrf" character varying NOT NULL, "expiration" timestamptz NOT NULL, "user_id" bigint NOT NULL, PRIMARY KEY ("id"), CONSTRAINT "sessions_users_session" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON UPDATE NO ACTION ON DELETE CASCADE);
+-- Create "spotify_links" table
+CREATE TABLE "spotify_links" ("id" bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY, "access_token" character varying NOT NULL, "access_token_expiration" timestamptz NOT NULL, "refresh_token" character varying NOT NULL, "user_id" bigint NOT NULL, PRIMARY KEY ("id"), CONSTRAINT "spotify_links_users_spotify_link" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON UPDATE NO ACTION ON DELETE CASCADE);
+-- Create index "spotify_links_user_id_key" to table: "spotify_links"
+CREATE UNIQUE INDEX "spotify_links_user_id_key" ON "spotify_links" ("user_id");
@@ -0,0 +1,8 @@
+-- reverse: create index "cacheentry_expiration" to table: "cache_entries"
+DROP INDEX "cacheentry_expiration";
+-- reverse: create index "cache_entries_key_key" to table: "cache_entries"
+DROP INDEX "cache_entries_key_key";
+-- reverse: create "cache_entries" table
+DROP TABLE "cache_entries";
+-- reverse: modify "spotify_links" table
+ALTER TABLE "spotify_links" DROP COLUMN "revoked";
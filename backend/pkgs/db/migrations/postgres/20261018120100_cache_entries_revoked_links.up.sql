@@ -0,0 +1,8 @@
+-- Modify "spotify_links" table
+ALTER TABLE "spotify_links" ADD COLUMN "revoked" boolean NOT NULL DEFAULT false;
+-- Create "cache_entries" table
+CREATE TABLE "cache_entries" ("id" bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY, "key" character varying NOT NULL, "value" bytea NOT NULL, "expiration" timestamptz NOT NULL, PRIMARY KEY ("id"));
+-- Create index "cache_entries_key_key" to table: "cache_entries"
+CREATE UNIQUE INDEX "cache_entries_key_key" ON "cache_entries" ("key");
+-- Create index "cacheentry_expiration" to table: "cache_entries"
+CREATE INDEX "cacheentry_expiration" ON "cache_entries" ("expiration");
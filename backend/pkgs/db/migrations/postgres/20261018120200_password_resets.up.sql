@@ -0,0 +1,6 @@
+-- Create "password_resets" table
+CREATE TABLE "password_resets" ("id" bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY, "token_hash" character varying NOT NULL, "expiration" timestamptz NOT NULL, "user_id" bigint NOT NULL, PRIMARY KEY ("id"), CONSTRAINT "password_resets_users_password_reset" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON UPDATE NO ACTION ON DELETE CASCADE);
+-- Create index "password_resets_token_hash_key" to table: "password_resets"
+CREATE UNIQUE INDEX "password_resets_token_hash_key" ON "password_resets" ("token_hash");
+-- Create index "passwordreset_expiration" to table: "password_resets"
+CREATE INDEX "passwordreset_expiration" ON "password_resets" ("expiration");
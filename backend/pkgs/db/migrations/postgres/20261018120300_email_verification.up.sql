@@ -0,0 +1,12 @@
+-- Modify "users" table
+ALTER TABLE "users" ADD COLUMN "email_verified" boolean NOT NULL DEFAULT false;
+-- Accounts created before email verification existed are trusted as verified
+UPDATE "users" SET "email_verified" = true;
+-- Create "email_verifications" table
+CREATE TABLE "email_verifications" ("id" bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY, "email" character varying NOT NULL, "token_hash" character varying NOT NULL, "expiration" timestamptz NOT NULL, "sent_at" timestamptz NOT NULL, "user_id" bigint NOT NULL, PRIMARY KEY ("id"), CONSTRAINT "email_verifications_users_email_verification" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON UPDATE NO ACTION ON DELETE CASCADE);
+-- Create index "email_verifications_token_hash_key" to table: "email_verifications"
+CREATE UNIQUE INDEX "email_verifications_token_hash_key" ON "email_verifications" ("token_hash");
+-- Create index "email_verifications_user_id_key" to table: "email_verifications"
+CREATE UNIQUE INDEX "email_verifications_user_id_key" ON "email_verifications" ("user_id");
+-- Create index "emailverification_expiration" to table: "email_verifications"
+CREATE INDEX "emailverification_expiration" ON "email_verifications" ("expiration");
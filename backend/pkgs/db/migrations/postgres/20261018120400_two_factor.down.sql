@@ -0,0 +1,12 @@
+-- reverse: create index "recovery_codes_code_hash_key" to table: "recovery_codes"
+DROP INDEX "recovery_codes_code_hash_key";
+-- reverse: create "recovery_codes" table
+DROP TABLE "recovery_codes";
+-- reverse: create index "loginchallenge_expiration" to table: "login_challenges"
+DROP INDEX "loginchallenge_expiration";
+-- reverse: create index "login_challenges_token_hash_key" to table: "login_challenges"
+DROP INDEX "login_challenges_token_hash_key";
+-- reverse: create "login_challenges" table
+DROP TABLE "login_challenges";
+-- reverse: modify "users" table
+ALTER TABLE "users" DROP COLUMN "totp_last_step", DROP COLUMN "totp_enabled", DROP COLUMN "totp_secret";
@@ -0,0 +1,12 @@
+-- Modify "users" table
+ALTER TABLE "users" ADD COLUMN "totp_secret" character varying NULL, ADD COLUMN "totp_enabled" boolean NOT NULL DEFAULT false, ADD COLUMN "totp_last_step" bigint NOT NULL DEFAULT 0;
+-- Create "login_challenges" table
+CREATE TABLE "login_challenges" ("id" bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY, "token_hash" character varying NOT NULL, "attempts" bigint NOT NULL DEFAULT 0, "expiration" timestamptz NOT NULL, "user_id" bigint NOT NULL, PRIMARY KEY ("id"), CONSTRAINT "login_challenges_users_login_challenge" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON UPDATE NO ACTION ON DELETE CASCADE);
+-- Create index "login_challenges_token_hash_key" to table: "login_challenges"
+CREATE UNIQUE INDEX "login_challenges_token_hash_key" ON "login_challenges" ("token_hash");
+-- Create index "loginchallenge_expiration" to table: "login_challenges"
+CREATE INDEX "loginchallenge_expiration" ON "login_challenges" ("expiration");
+-- Create "recovery_codes" table
+CREATE TABLE "recovery_codes" ("id" bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY, "code_hash" character varying NOT NULL, "user_id" bigint NOT NULL, PRIMARY KEY ("id"), CONSTRAINT "recovery_codes_users_recovery_code" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON UPDATE NO ACTION ON DELETE CASCADE);
+-- Create index "recovery_codes_code_hash_key" to table: "recovery_codes"
+CREATE UNIQUE INDEX "recovery_codes_code_hash_key" ON "recovery_codes" ("code_hash");
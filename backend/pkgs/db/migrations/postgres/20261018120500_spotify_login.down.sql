@@ -0,0 +1,10 @@
+-- reverse: create index "users_spotify_id_key" to table: "users"
+DROP INDEX "users_spotify_id_key";
+-- reverse: modify "users" table
+ALTER TABLE "users" DROP COLUMN "spotify_id";
+-- reverse: create index "oauthstate_state" to table: "oauth_states"
+DROP INDEX "oauthstate_state";
+-- reverse: states of a login with Spotify have no user and cannot be kept
+DELETE FROM "oauth_states" WHERE "user_id" IS NULL;
+-- reverse: modify "oauth_states" table
+ALTER TABLE "oauth_states" ALTER COLUMN "user_id" SET NOT NULL;
@@ -0,0 +1,8 @@
+-- Modify "oauth_states" table
+ALTER TABLE "oauth_states" ALTER COLUMN "user_id" DROP NOT NULL;
+-- Create index "oauthstate_state" to table: "oauth_states"
+CREATE INDEX "oauthstate_state" ON "oauth_states" ("state");
+-- Modify "users" table
+ALTER TABLE "users" ADD COLUMN "spotify_id" character varying NULL;
+-- Create index "users_spotify_id_key" to table: "users"
+CREATE UNIQUE INDEX "users_spotify_id_key" ON "users" ("spotify_id");
@@ -0,0 +1,2 @@
+-- reverse: modify "sessions" table
+ALTER TABLE "sessions" DROP COLUMN "last_seen", DROP COLUMN "created_at", DROP COLUMN "ip", DROP COLUMN "user_agent";
@@ -0,0 +1,4 @@
+-- Modify "sessions" table; existing sessions are dated to the migration
+ALTER TABLE "sessions" ADD COLUMN "user_agent" character varying NOT NULL DEFAULT '', ADD COLUMN "ip" character varying NOT NULL DEFAULT '', ADD COLUMN "created_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP, ADD COLUMN "last_seen" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP;
+-- Modify "sessions" table
+ALTER TABLE "sessions" ALTER COLUMN "created_at" DROP DEFAULT, ALTER COLUMN "last_seen" DROP DEFAULT;
@@ -0,0 +1,6 @@
+-- Create "throttles" table
+CREATE TABLE "throttles" ("id" bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY, "key" character varying NOT NULL, "count" bigint NOT NULL DEFAULT 0, "last_attempt" timestamptz NOT NULL, "locked_until" timestamptz NULL, PRIMARY KEY ("id"));
+-- Create index "throttles_key_key" to table: "throttles"
+CREATE UNIQUE INDEX "throttles_key_key" ON "throttles" ("key");
+-- Create index "throttle_last_attempt" to table: "throttles"
+CREATE INDEX "throttle_last_attempt" ON "throttles" ("last_attempt");
@@ -0,0 +1,8 @@
+-- Create "data_exports" table
+CREATE TABLE "data_exports" ("id" bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY, "status" character varying NOT NULL DEFAULT 'pending', "bundle" bytea NULL, "token_hash" character varying NULL, "created_at" timestamptz NOT NULL, "expiration" timestamptz NULL, "user_id" bigint NOT NULL, PRIMARY KEY ("id"), CONSTRAINT "data_exports_users_data_export" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON UPDATE NO ACTION ON DELETE CASCADE);
+-- Create index "data_exports_token_hash_key" to table: "data_exports"
+CREATE UNIQUE INDEX "data_exports_token_hash_key" ON "data_exports" ("token_hash");
+-- Create index "data_exports_user_id_key" to table: "data_exports"
+CREATE UNIQUE INDEX "data_exports_user_id_key" ON "data_exports" ("user_id");
+-- Create index "dataexport_status" to table: "data_exports"
+CREATE INDEX "dataexport_status" ON "data_exports" ("status");
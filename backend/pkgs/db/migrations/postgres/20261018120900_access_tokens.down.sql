@@ -0,0 +1,6 @@
+-- reverse: create index "accesstoken_expiration" to table: "access_tokens"
+DROP INDEX "accesstoken_expiration";
+-- reverse: create index "access_tokens_token_hash_key" to table: "access_tokens"
+DROP INDEX "access_tokens_token_hash_key";
+-- reverse: create "access_tokens" table
+DROP TABLE "access_tokens";
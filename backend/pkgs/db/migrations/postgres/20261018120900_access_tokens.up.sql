@@ -0,0 +1,6 @@
+-- Create "access_tokens" table
+CREATE TABLE "access_tokens" ("id" bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY, "name" character varying NOT NULL, "token_hash" character varying NOT NULL, "scopes" jsonb NOT NULL, "created_at" timestamptz NOT NULL, "last_used" timestamptz NULL, "expiration" timestamptz NULL, "user_id" bigint NOT NULL, PRIMARY KEY ("id"), CONSTRAINT "access_tokens_users_access_token" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON UPDATE NO ACTION ON DELETE CASCADE);
+-- Create index "access_tokens_token_hash_key" to table: "access_tokens"
+CREATE UNIQUE INDEX "access_tokens_token_hash_key" ON "access_tokens" ("token_hash");
+-- Create index "accesstoken_expiration" to table: "access_tokens"
+CREATE INDEX "accesstoken_expiration" ON "access_tokens" ("expiration");
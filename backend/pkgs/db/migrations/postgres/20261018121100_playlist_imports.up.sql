@@ -0,0 +1,6 @@
+-- Create "playlist_imports" table
+CREATE TABLE "playlist_imports" ("id" bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY, "status" character varying NOT NULL DEFAULT 'pending', "format" character varying NOT NULL, "name" character varying NOT NULL, "description" character varying NOT NULL DEFAULT '', "public" boolean NOT NULL DEFAULT false, "entries" jsonb NOT NULL, "progress" bigint NOT NULL DEFAULT 0, "playlist_id" character varying NULL, "error" character varying NULL, "created_at" timestamptz NOT NULL, "updated_at" timestamptz NOT NULL, "user_id" bigint NOT NULL, PRIMARY KEY ("id"), CONSTRAINT "playlist_imports_users_playlist_import" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON UPDATE NO ACTION ON DELETE CASCADE);
+-- Create index "playlistimport_status" to table: "playlist_imports"
+CREATE INDEX "playlistimport_status" ON "playlist_imports" ("status");
+-- Create index "playlistimport_updated_at" to table: "playlist_imports"
+CREATE INDEX "playlistimport_updated_at" ON "playlist_imports" ("updated_at");
@@ -0,0 +1,2 @@
+-- reverse: modify "login_challenges" table
+ALTER TABLE "login_challenges" DROP COLUMN "spotify_refresh_token", DROP COLUMN "spotify_access_token";
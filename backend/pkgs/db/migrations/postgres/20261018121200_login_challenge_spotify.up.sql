@@ -0,0 +1,2 @@
+-- Modify "login_challenges" table
+ALTER TABLE "login_challenges" ADD COLUMN "spotify_access_token" character varying NULL, ADD COLUMN "spotify_refresh_token" character varying NULL;
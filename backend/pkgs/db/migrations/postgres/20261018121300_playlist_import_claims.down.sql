@@ -0,0 +1,2 @@
+-- reverse: modify "playlist_imports" table
+ALTER TABLE "playlist_imports" DROP COLUMN "claim_expiration", DROP COLUMN "claim";
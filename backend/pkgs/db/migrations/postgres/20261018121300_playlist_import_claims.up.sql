@@ -0,0 +1,2 @@
+-- Modify "playlist_imports" table
+ALTER TABLE "playlist_imports" ADD COLUMN "claim" character varying NULL, ADD COLUMN "claim_expiration" timestamptz NULL;
@@ -0,0 +1,22 @@
+-- reverse: create index "users_email_key" to table: "users"
+DROP INDEX `users_email_key`;
+-- reverse: create index "users_username_key" to table: "users"
+DROP INDEX `users_username_key`;
+-- reverse: create "users" table
+DROP TABLE `users`;
+-- reverse: create index "spotify_links_user_id_key" to table: "spotify_links"
+DROP INDEX `spotify_links_user_id_key`;
+-- reverse: create "spotify_links" table
+DROP TABLE `spotify_links`;
+-- reverse: create "sessions" table
+DROP TABLE `sessions`;
+-- reverse: create index "oauth_states_user_id_key" to table: "oauth_states"
+DROP INDEX `oauth_states_user_id_key`;
+-- reverse: create "oauth_states" table
+DROP TABLE `oauth_states`;
+-- reverse: create index "cacheentry_expiration" to table: "cache_entries"
+DROP INDEX `cacheentry_expiration`;
+-- reverse: create index "cache_entries_key_key" to table: "cache_entries"
+DROP INDEX `cache_entries_key_key`;
+-- reverse: create "cache_entries" table
+DROP TABLE `cache_entries`;
@@ -0,0 +1,22 @@
+-- create "cache_entries" table
+CREATE TABLE `cache_entries` (`id` integer NOT NULL PRIMARY KEY AUTOINCREMENT, `key` text NOT NULL, `value` blob NOT NULL, `expiration` datetime NOT NULL);
+-- create index "cache_entries_key_key" to table: "cache_entries"
+CREATE UNIQUE INDEX `cache_entries_key_key` ON `cache_entries` (`key`);
+-- create index "cacheentry_expiration" to table: "cache_entries"
+CREATE INDEX `cacheentry_expiration` ON `cache_entries` (`expiration`);
+-- create "oauth_states" table
+CREATE TABLE `oauth_states` (`id` integer NOT NULL PRIMARY KEY AUTOINCREMENT, `state` text NOT NULL, `expiration` datetime NOT NULL, `user_id` integer NOT NULL, CONSTRAINT `oauth_states_users_oauth_state` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE);
+-- create index "oauth_states_user_id_key" to table: "oauth_states"
+CREATE UNIQUE INDEX `oauth_states_user_id_key` ON `oauth_states` (`user_id`);
+-- create "sessions" table
+CREATE TABLE `sessions` (`id` integer NOT NULL PRIMARY KEY AUTOINCREMENT, `token` text NOT NULL, `csrf` text NOT NULL, `expiration` datetime NOT NULL, `user_id` integer NOT NULL, CONSTRAINT `sessions_users_session` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE);
+-- create "spotify_links" table
+CREATE TABLE `spotify_links` (`id` integer NOT NULL PRIMARY KEY AUTOINCREMENT, `access_token` text NOT NULL, `access_token_expiration` datetime NOT NULL, `refresh_token` text NOT NULL, `revoked` bool NOT NULL DEFAULT false, `user_id` integer NOT NULL, CONSTRAINT `spotify_links_users_spotify_link` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE);
+-- create index "spotify_links_user_id_key" to table: "spotify_links"
+CREATE UNIQUE INDEX `spotify_links_user_id_key` ON `spotify_links` (`user_id`);
+-- create "users" table
+CREATE TABLE `users` (`id` integer NOT NULL PRIMARY KEY AUTOINCREMENT, `username` text NOT NULL, `password` text NOT NULL, `email` text NOT NULL);
+-- create index "users_username_key" to table: "users"
+CREATE UNIQUE INDEX `users_username_key` ON `users` (`username`);
+-- create index "users_email_key" to table: "users"
+CREATE UNIQUE INDEX `users_email_key` ON `users` (`email`);
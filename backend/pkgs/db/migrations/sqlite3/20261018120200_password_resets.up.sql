@@ -0,0 +1,6 @@
+-- create "password_resets" table
+CREATE TABLE `password_resets` (`id` integer NOT NULL PRIMARY KEY AUTOINCREMENT, `token_hash` text NOT NULL, `expiration` datetime NOT NULL, `user_id` integer NOT NULL, CONSTRAINT `password_resets_users_password_reset` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE);
+-- create index "password_resets_token_hash_key" to table: "password_resets"
+CREATE UNIQUE INDEX `password_resets_token_hash_key` ON `password_resets` (`token_hash`);
+-- create index "passwordreset_expiration" to table: "password_resets"
+CREATE INDEX `passwordreset_expiration` ON `password_resets` (`expiration`);
@@ -0,0 +1,10 @@
+-- reverse: create index "emailverification_expiration" to table: "email_verifications"
+DROP INDEX `emailverification_expiration`;
+-- reverse: create index "email_verifications_user_id_key" to table: "email_verifications"
+DROP INDEX `email_verifications_user_id_key`;
+-- reverse: create index "email_verifications_token_hash_key" to table: "email_verifications"
+DROP INDEX `email_verifications_token_hash_key`;
+-- reverse: create "email_verifications" table
+DROP TABLE `email_verifications`;
+-- reverse: add column "email_verified" to table: "users"
+ALTER TABLE `users` DROP COLUMN `email_verified`;
@@ -0,0 +1,12 @@
+-- add column "email_verified" to table: "users"
+ALTER TABLE `users` ADD COLUMN `email_verified` bool NOT NULL DEFAULT false;
+-- accounts created before email verification existed are trusted as verified
+UPDATE `users` SET `email_verified` = true;
+-- create "email_verifications" table
+CREATE TABLE `email_verifications` (`id` integer NOT NULL PRIMARY KEY AUTOINCREMENT, `email` text NOT NULL, `token_hash` text NOT NULL, `expiration` datetime NOT NULL, `sent_at` datetime NOT NULL, `user_id` integer NOT NULL, CONSTRAINT `email_verifications_users_email_verification` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE);
+-- create index "email_verifications_token_hash_key" to table: "email_verifications"
+CREATE UNIQUE INDEX `email_verifications_token_hash_key` ON `email_verifications` (`token_hash`);
+-- create index "email_verifications_user_id_key" to table: "email_verifications"
+CREATE UNIQUE INDEX `email_verifications_user_id_key` ON `email_verifications` (`user_id`);
+-- create index "emailverification_expiration" to table: "email_verifications"
+CREATE INDEX `emailverification_expiration` ON `email_verifications` (`expiration`);
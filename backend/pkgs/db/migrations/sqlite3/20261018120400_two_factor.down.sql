@@ -0,0 +1,16 @@
+-- reverse: create index "recovery_codes_code_hash_key" to table: "recovery_codes"
+DROP INDEX `recovery_codes_code_hash_key`;
+-- reverse: create "recovery_codes" table
+DROP TABLE `recovery_codes`;
+-- reverse: create index "loginchallenge_expiration" to table: "login_challenges"
+DROP INDEX `loginchallenge_expiration`;
+-- reverse: create index "login_challenges_token_hash_key" to table: "login_challenges"
+DROP INDEX `login_challenges_token_hash_key`;
+-- reverse: create "login_challenges" table
+DROP TABLE `login_challenges`;
+-- reverse: add column "totp_last_step" to table: "users"
+ALTER TABLE `users` DROP COLUMN `totp_last_step`;
+-- reverse: add column "totp_enabled" to table: "users"
+ALTER TABLE `users` DROP COLUMN `totp_enabled`;
+-- reverse: add column "totp_secret" to table: "users"
+ALTER TABLE `users` DROP COLUMN `totp_secret`;
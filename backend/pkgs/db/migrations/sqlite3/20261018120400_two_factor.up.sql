@@ -0,0 +1,16 @@
+-- add column "totp_secret" to table: "users"
+ALTER TABLE `users` ADD COLUMN `totp_secret` text NULL;
+-- add column "totp_enabled" to table: "users"
+ALTER TABLE `users` ADD COLUMN `totp_enabled` bool NOT NULL DEFAULT false;
+-- add column "totp_last_step" to table: "users"
+ALTER TABLE `users` ADD COLUMN `totp_last_step` integer NOT NULL DEFAULT 0;
+-- create "login_challenges" table
+CREATE TABLE `login_challenges` (`id` integer NOT NULL PRIMARY KEY AUTOINCREMENT, `token_hash` text NOT NULL, `attempts` integer NOT NULL DEFAULT 0, `expiration` datetime NOT NULL, `user_id` integer NOT NULL, CONSTRAINT `login_challenges_users_login_challenge` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE);
+-- create index "login_challenges_token_hash_key" to table: "login_challenges"
+CREATE UNIQUE INDEX `login_challenges_token_hash_key` ON `login_challenges` (`token_hash`);
+-- create index "loginchallenge_expiration" to table: "login_challenges"
+CREATE INDEX `loginchallenge_expiration` ON `login_challenges` (`expiration`);
+-- create "recovery_codes" table
+CREATE TABLE `recovery_codes` (`id` integer NOT NULL PRIMARY KEY AUTOINCREMENT, `code_hash` text NOT NULL, `user_id` integer NOT NULL, CONSTRAINT `recovery_codes_users_recovery_code` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE);
+-- create index "recovery_codes_code_hash_key" to table: "recovery_codes"
+CREATE UNIQUE INDEX `recovery_codes_code_hash_key` ON `recovery_codes` (`code_hash`);
@@ -0,0 +1,14 @@
+-- reverse: create index "oauthstate_state" to table: "oauth_states"
+DROP INDEX `oauthstate_state`;
+-- reverse: states of a login with Spotify have no user and cannot be kept
+DELETE FROM `oauth_states` WHERE `user_id` IS NULL;
+-- reverse: create "new_oauth_states" table
+CREATE TABLE `old_oauth_states` (`id` integer NOT NULL PRIMARY KEY AUTOINCREMENT, `state` text NOT NULL, `expiration` datetime NOT NULL, `user_id` integer NOT NULL, CONSTRAINT `oauth_states_users_oauth_state` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE);
+INSERT INTO `old_oauth_states` (`id`, `state`, `expiration`, `user_id`) SELECT `id`, `state`, `expiration`, `user_id` FROM `oauth_states`;
+DROP TABLE `oauth_states`;
+ALTER TABLE `old_oauth_states` RENAME TO `oauth_states`;
+CREATE UNIQUE INDEX `oauth_states_user_id_key` ON `oauth_states` (`user_id`);
+-- reverse: create index "users_spotify_id_key" to table: "users"
+DROP INDEX `users_spotify_id_key`;
+-- reverse: add column "spotify_id" to table: "users"
+ALTER TABLE `users` DROP COLUMN `spotify_id`;
@@ -0,0 +1,16 @@
+-- add column "spotify_id" to table: "users"
+ALTER TABLE `users` ADD COLUMN `spotify_id` text NULL;
+-- create index "users_spotify_id_key" to table: "users"
+CREATE UNIQUE INDEX `users_spotify_id_key` ON `users` (`spotify_id`);
+-- create "new_oauth_states" table; no table references "oauth_states", so it is safe to rebuild
+CREATE TABLE `new_oauth_states` (`id` integer NOT NULL PRIMARY KEY AUTOINCREMENT, `state` text NOT NULL, `expiration` datetime NOT NULL, `user_id` integer NULL, CONSTRAINT `oauth_states_users_oauth_state` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE);
+-- copy rows from old table "oauth_states" to new temporary table "new_oauth_states"
+INSERT INTO `new_oauth_states` (`id`, `state`, `expiration`, `user_id`) SELECT `id`, `state`, `expiration`, `user_id` FROM `oauth_states`;
+-- drop "oauth_states" table after copying rows
+DROP TABLE `oauth_states`;
+-- rename temporary table "new_oauth_states" to "oauth_states"
+ALTER TABLE `new_oauth_states` RENAME TO `oauth_states`;
+-- create index "oauth_states_user_id_key" to table: "oauth_states"
+CREATE UNIQUE INDEX `oauth_states_user_id_key` ON `oauth_states` (`user_id`);
+-- create index "oauthstate_state" to table: "oauth_states"
+CREATE INDEX `oauthstate_state` ON `oauth_states` (`state`);
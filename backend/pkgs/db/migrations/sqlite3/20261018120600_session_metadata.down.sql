@@ -0,0 +1,5 @@
+-- reverse: modify "sessions" table
+ALTER TABLE `sessions` DROP COLUMN `last_seen`;
+ALTER TABLE `sessions` DROP COLUMN `created_at`;
+ALTER TABLE `sessions` DROP COLUMN `ip`;
+ALTER TABLE `sessions` DROP COLUMN `user_agent`;
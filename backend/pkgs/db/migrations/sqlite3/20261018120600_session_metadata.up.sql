@@ -0,0 +1,8 @@
+-- create "new_sessions" table; no table references "sessions", so it is safe to rebuild
+CREATE TABLE `new_sessions` (`id` integer NOT NULL PRIMARY KEY AUTOINCREMENT, `token` text NOT NULL, `csrf` text NOT NULL, `expiration` datetime NOT NULL, `user_agent` text NOT NULL DEFAULT '', `ip` text NOT NULL DEFAULT '', `created_at` datetime NOT NULL, `last_seen` datetime NOT NULL, `user_id` integer NOT NULL, CONSTRAINT `sessions_users_session` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE);
+-- copy rows from old table "sessions" to new temporary table "new_sessions"; existing sessions are dated to the migration
+INSERT INTO `new_sessions` (`id`, `token`, `csrf`, `expiration`, `created_at`, `last_seen`, `user_id`) SELECT `id`, `token`, `csrf`, `expiration`, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, `user_id` FROM `sessions`;
+-- drop "sessions" table after copying rows
+DROP TABLE `sessions`;
+-- rename temporary table "new_sessions" to "sessions"
+ALTER TABLE `new_sessions` RENAME TO `sessions`;
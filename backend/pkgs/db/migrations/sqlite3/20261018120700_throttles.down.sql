@@ -0,0 +1,6 @@
+-- reverse: create index "throttle_last_attempt" to table: "throttles"
+DROP INDEX `throttle_last_attempt`;
+-- reverse: create index "throttles_key_key" to table: "throttles"
+DROP INDEX `throttles_key_key`;
+-- reverse: create "throttles" table
+DROP TABLE `throttles`;
@@ -0,0 +1,6 @@
+-- create "throttles" table
+CREATE TABLE `throttles` (`id` integer NOT NULL PRIMARY KEY AUTOINCREMENT, `key` text NOT NULL, `count` integer NOT NULL DEFAULT 0, `last_attempt` datetime NOT NULL, `locked_until` datetime NULL);
+-- create index "throttles_key_key" to table: "throttles"
+CREATE UNIQUE INDEX `throttles_key_key` ON `throttles` (`key`);
+-- create index "throttle_last_attempt" to table: "throttles"
+CREATE INDEX `throttle_last_attempt` ON `throttles` (`last_attempt`);
@@ -0,0 +1,8 @@
+-- reverse: create index "dataexport_status" to table: "data_exports"
+DROP INDEX `dataexport_status`;
+-- reverse: create index "data_exports_user_id_key" to table: "data_exports"
+DROP INDEX `data_exports_user_id_key`;
+-- reverse: create index "data_exports_token_hash_key" to table: "data_exports"
+DROP INDEX `data_exports_token_hash_key`;
+-- reverse: create "data_exports" table
+DROP TABLE `data_exports`;
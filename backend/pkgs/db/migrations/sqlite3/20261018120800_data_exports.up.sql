@@ -0,0 +1,8 @@
+-- create "data_exports" table
+CREATE TABLE `data_exports` (`id` integer NOT NULL PRIMARY KEY AUTOINCREMENT, `status` text NOT NULL DEFAULT 'pending', `bundle` blob NULL, `token_hash` text NULL, `created_at` datetime NOT NULL, `expiration` datetime NULL, `user_id` integer NOT NULL, CONSTRAINT `data_exports_users_data_export` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE);
+-- create index "data_exports_token_hash_key" to table: "data_exports"
+CREATE UNIQUE INDEX `data_exports_token_hash_key` ON `data_exports` (`token_hash`);
+-- create index "data_exports_user_id_key" to table: "data_exports"
+CREATE UNIQUE INDEX `data_exports_user_id_key` ON `data_exports` (`user_id`);
+-- create index "dataexport_status" to table: "data_exports"
+CREATE INDEX `dataexport_status` ON `data_exports` (`status`);
@@ -0,0 +1,6 @@
+-- create "access_tokens" table
+CREATE TABLE `access_tokens` (`id` integer NOT NULL PRIMARY KEY AUTOINCREMENT, `name` text NOT NULL, `token_hash` text NOT NULL, `scopes` json NOT NULL, `created_at` datetime NOT NULL, `last_used` datetime NULL, `expiration` datetime NULL, `user_id` integer NOT NULL, CONSTRAINT `access_tokens_users_access_token` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE);
+-- create index "access_tokens_token_hash_key" to table: "access_tokens"
+CREATE UNIQUE INDEX `access_tokens_token_hash_key` ON `access_tokens` (`token_hash`);
+-- create index "accesstoken_expiration" to table: "access_tokens"
+CREATE INDEX `accesstoken_expiration` ON `access_tokens` (`expiration`);
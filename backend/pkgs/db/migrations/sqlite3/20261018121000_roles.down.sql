@@ -0,0 +1,4 @@
+-- reverse: add column "disabled_at" to table: "users"
+ALTER TABLE `users` DROP COLUMN `disabled_at`;
+-- reverse: add column "role" to table: "users"
+ALTER TABLE `users` DROP COLUMN `role`;
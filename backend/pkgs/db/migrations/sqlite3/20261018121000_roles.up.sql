@@ -0,0 +1,4 @@
+-- add column "role" to table: "users"
+ALTER TABLE `users` ADD COLUMN `role` text NOT NULL DEFAULT 'user';
+-- add column "disabled_at" to table: "users"
+ALTER TABLE `users` ADD COLUMN `disabled_at` datetime NULL;
@@ -0,0 +1,6 @@
+-- reverse: create index "playlistimport_updated_at" to table: "playlist_imports"
+DROP INDEX `playlistimport_updated_at`;
+-- reverse: create index "playlistimport_status" to table: "playlist_imports"
+DROP INDEX `playlistimport_status`;
+-- reverse: create "playlist_imports" table
+DROP TABLE `playlist_imports`;
@@ -0,0 +1,6 @@
+-- create "playlist_imports" table
+CREATE TABLE `playlist_imports` (`id` integer NOT NULL PRIMARY KEY AUTOINCREMENT, `status` text NOT NULL DEFAULT 'pending', `format` text NOT NULL, `name` text NOT NULL, `description` text NOT NULL DEFAULT '', `public` bool NOT NULL DEFAULT false, `entries` json NOT NULL, `progress` integer NOT NULL DEFAULT 0, `playlist_id` text NULL, `error` text NULL, `created_at` datetime NOT NULL, `updated_at` datetime NOT NULL, `user_id` integer NOT NULL, CONSTRAINT `playlist_imports_users_playlist_import` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE);
+-- create index "playlistimport_status" to table: "playlist_imports"
+CREATE INDEX `playlistimport_status` ON `playlist_imports` (`status`);
+-- create index "playlistimport_updated_at" to table: "playlist_imports"
+CREATE INDEX `playlistimport_updated_at` ON `playlist_imports` (`updated_at`);
@@ -0,0 +1,4 @@
+-- reverse: add column "spotify_refresh_token" to table: "login_challenges"
+ALTER TABLE `login_challenges` DROP COLUMN `spotify_refresh_token`;
+-- reverse: add column "spotify_access_token" to table: "login_challenges"
+ALTER TABLE `login_challenges` DROP COLUMN `spotify_access_token`;
@@ -0,0 +1,4 @@
+-- add column "spotify_access_token" to table: "login_challenges"
+ALTER TABLE `login_challenges` ADD COLUMN `spotify_access_token` text NULL;
+-- add column "spotify_refresh_token" to table: "login_challenges"
+ALTER TABLE `login_challenges` ADD COLUMN `spotify_refresh_token` text NULL;
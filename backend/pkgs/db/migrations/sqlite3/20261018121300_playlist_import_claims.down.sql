@@ -0,0 +1,4 @@
+-- reverse: add column "claim_expiration" to table: "playlist_imports"
+ALTER TABLE `playlist_imports` DROP COLUMN `claim_expiration`;
+-- reverse: add column "claim" to table: "playlist_imports"
+ALTER TABLE `playlist_imports` DROP COLUMN `claim`;
@@ -0,0 +1,4 @@
+-- add column "claim" to table: "playlist_imports"
+ALTER TABLE `playlist_imports` ADD COLUMN `claim` text NULL;
+-- add column "claim_expiration" to table: "playlist_imports"
+ALTER TABLE `playlist_imports` ADD COLUMN `claim_expiration` datetime NULL;
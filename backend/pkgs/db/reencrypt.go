@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+	"groove/pkgs/ent"
+	SpotifyLink "groove/pkgs/ent/spotifylink"
+	User "groove/pkgs/ent/user"
+)
+
+// reencryptBatch is how many rows are re-encrypted per query.
+const reencryptBatch = 100
+
+// ReencryptLinks re-encrypts the tokens of every SpotifyLink under the primary key of the keyring.
+// run along with ReencryptTOTPSecrets after adding a new primary key; once both are done, the previous keys can be
+// removed from TOKEN_KEYS. (this also encrypts tokens stored before encryption was introduced)
+func ReencryptLinks(ctx context.Context, client *ent.Client) (int, error) {
+	var affected, lastID int
+	for {
+		// tokens are decrypted (under any known key) when read, and encrypted under the primary key when saved.
+		links, err := client.SpotifyLink.
+			Query().
+			Where(SpotifyLink.IDGT(lastID)).
+			Order(ent.Asc(SpotifyLink.FieldID)).
+			Limit(reencryptBatch).
+			All(ctx)
+		if err != nil {
+			return affected, err
+		}
+
+		for _, link := range links {
+			_, err = link.Update().
+				SetAccessToken(link.AccessToken).
+				SetRefreshToken(link.RefreshToken).
+				Save(ctx)
+			if err != nil {
+				return affected, err
+			}
+			affected++
+			lastID = link.ID
+		}
+
+		if len(links) < reencryptBatch {
+			return affected, nil
+		}
+	}
+}
+
+// ReencryptTOTPSecrets re-encrypts the TOTP secret of every user enrolled in two-factor authentication
+// under the primary key of the keyring; see ReencryptLinks. a secret left under a removed key fails every
+// query loading its user, locking them out.
+func ReencryptTOTPSecrets(ctx context.Context, client *ent.Client) (int, error) {
+	var affected, lastID int
+	for {
+		// only the secrets are loaded, the rest of the users is left untouched.
+		users, err := client.User.
+			Query().
+			Where(User.IDGT(lastID), User.TotpSecretNotNil()).
+			Order(ent.Asc(User.FieldID)).
+			Limit(reencryptBatch).
+			Select(User.FieldID, User.FieldTotpSecret).
+			All(ctx)
+		if err != nil {
+			return affected, err
+		}
+
+		for _, user := range users {
+			err = client.User.
+				UpdateOneID(user.ID).
+				SetTotpSecret(user.TotpSecret).
+				Exec(ctx)
+			if err != nil {
+				return affected, err
+			}
+			affected++
+			lastID = user.ID
+		}
+
+		if len(users) < reencryptBatch {
+			return affected, nil
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package db_test
+
+import (
+	"context"
+	"groove/pkgs/db"
+	"groove/pkgs/db/dbtest"
+	"groove/pkgs/secret"
+	"testing"
+	"time"
+)
+
+const (
+	oldKey = "k1:MDEyMzQ1Njc4OTAxMjM0NTY3ODkwMTIzNDU2Nzg5MDE="
+	newKey = "k2:YWJjZGVmZ2hpamtsbW5vcHFyc3R1dnd4eXowMTIzNDU="
+)
+
+func useKeyring(t *testing.T, spec string) {
+	t.Helper()
+
+	keyring, err := secret.ParseKeyring(spec)
+	if err != nil {
+		t.Fatalf("parsing keyring: %v", err)
+	}
+	secret.Use(keyring)
+}
+
+func TestReencryptRotatesEveryField(t *testing.T) {
+	client := dbtest.NewClient(t)
+	ctx := context.Background()
+
+	useKeyring(t, oldKey)
+	user := client.User.Create().
+		SetUsername("rotate1").
+		SetEmail("rotate1@groove.test").
+		SetPassword("").
+		SetTotpSecret("JBSWY3DPEHPK3PXP").
+		SetTotpEnabled(true).
+		SaveX(ctx)
+	client.User.Create().SetUsername("rotate2").SetEmail("rotate2@groove.test").SetPassword("").ExecX(ctx)
+	client.SpotifyLink.Create().
+		SetUserID(user.ID).
+		SetAccessToken("access").
+		SetAccessTokenExpiration(time.Now().Add(time.Hour)).
+		SetRefreshToken("refresh").
+		ExecX(ctx)
+
+	// a new primary key is added, the old one still decrypts.
+	useKeyring(t, newKey+","+oldKey)
+	links, err := db.ReencryptLinks(ctx, client)
+	if err != nil || links != 1 {
+		t.Fatalf("ReencryptLinks = %d, %v, want 1", links, err)
+	}
+	secrets, err := db.ReencryptTOTPSecrets(ctx, client)
+	if err != nil || secrets != 1 {
+		t.Fatalf("ReencryptTOTPSecrets = %d, %v, want 1", secrets, err)
+	}
+
+	// once re-encrypted, the old key can be removed.
+	useKeyring(t, newKey)
+	users, err := client.User.Query().All(ctx)
+	if err != nil {
+		t.Fatalf("loading users without the old key: %v", err)
+	}
+	for _, u := range users {
+		if u.ID == user.ID && u.TotpSecret != "JBSWY3DPEHPK3PXP" {
+			t.Errorf("totp secret = %q after re-encryption", u.TotpSecret)
+		}
+	}
+	link, err := client.SpotifyLink.Query().Only(ctx)
+	if err != nil {
+		t.Fatalf("loading link without the old key: %v", err)
+	}
+	if link.AccessToken != "access" || link.RefreshToken != "refresh" {
+		t.Errorf("tokens = %q, %q after re-encryption", link.AccessToken, link.RefreshToken)
+	}
+}
@@ -0,0 +1,98 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"golang.org/x/sync/singleflight"
+	"groove/pkgs/ent"
+	"groove/pkgs/spotify"
+	. "groove/pkgs/util"
+	"strconv"
+	"time"
+)
+
+// ErrLinkRevoked is returned when Spotify no longer accepts the refresh token of a SpotifyLink.
+var ErrLinkRevoked = errors.New("spotify link revoked")
+
+// refreshTimeout bounds a refresh, which runs detached from the callers waiting on it.
+const refreshTimeout = 30 * time.Second
+
+// Refresher refreshes the access tokens of SpotifyLinks.
+// concurrent refreshes of the same link are deduplicated, so a burst of requests only posts to /token once.
+type Refresher struct {
+	client  *ent.Client
+	spotify *spotify.Client
+	group   singleflight.Group
+}
+
+func ProvideRefresher(client *ent.Client, spotify *spotify.Client) *Refresher {
+	return &Refresher{
+		client:  client,
+		spotify: spotify,
+	}
+}
+
+// Refresh refreshes the access token of the link if it expires before the given time,
+// returning the up-to-date link. returns ErrLinkRevoked if the user has to link their account again.
+// the refresh is shared by every caller waiting on it, so it runs without the cancellation of the one that
+// started it: a disconnecting client neither fails the others nor loses a refresh token Spotify already rotated.
+// a cancelled caller stops waiting, and the refresh completes in the background.
+func (r *Refresher) Refresh(ctx context.Context, linkID int, before time.Time) (*ent.SpotifyLink, error) {
+	shared := WithoutCancel(ctx)
+	results := r.group.DoChan(strconv.Itoa(linkID), func() (any, error) {
+		ctx, cancel := context.WithTimeout(shared, refreshTimeout)
+		defer cancel()
+
+		// re-read the link: another refresh may have completed since the caller loaded it.
+		link, err := r.client.SpotifyLink.Get(ctx, linkID)
+		if err != nil {
+			return nil, err
+		}
+		if link.Revoked {
+			return nil, ErrLinkRevoked
+		}
+		if link.AccessTokenExpiration.After(before) {
+			return link, nil
+		}
+
+		return r.refresh(ctx, link)
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case result := <-results:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		return result.Val.(*ent.SpotifyLink), nil
+	}
+}
+
+// refresh exchanges the refresh token of the link for a new access token and saves it.
+func (r *Refresher) refresh(ctx context.Context, link *ent.SpotifyLink) (*ent.SpotifyLink, error) {
+	tokens, err := r.spotify.RefreshToken(ctx, link.RefreshToken)
+	if err != nil {
+		var spotifyErr *spotify.Error
+		if errors.As(err, &spotifyErr) && spotifyErr.Reason == "invalid_grant" {
+			// the user revoked Groove's access (or the token was otherwise invalidated).
+			if _, err = link.Update().SetRevoked(true).Save(ctx); err != nil {
+				LogError("Refresher-Refresh", "marking spotify link revoked", err)
+			}
+			return nil, ErrLinkRevoked
+		}
+		return nil, err
+	}
+
+	if tokens.RefreshToken == "" {
+		tokens.RefreshToken = link.RefreshToken
+	}
+
+	// update link with new access and refresh tokens.
+	return link.Update().
+		SetAccessToken(tokens.AccessToken).
+		SetRefreshToken(tokens.RefreshToken).
+		// Spotify's Access-Token expire after 1 hour, so we set the expiration to 58 minutes to be safe.
+		SetAccessTokenExpiration(time.Now().Add(Time58Minutes)).
+		Save(ctx)
+}
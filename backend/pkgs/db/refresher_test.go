@@ -0,0 +1,35 @@
+package db_test
+
+import (
+	"context"
+	"groove/pkgs/db"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRefreshOutlivesCancelledCaller(t *testing.T) {
+	it := newImportTest(t)
+	refresher := db.ProvideRefresher(it.client, it.spotify)
+	link := it.client.SpotifyLink.Query().OnlyX(context.Background())
+	it.client.SpotifyLink.UpdateOne(link).SetAccessTokenExpiration(time.Now().Add(-time.Minute)).ExecX(context.Background())
+
+	// the caller that starts the refresh is gone before it completes.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := refresher.Refresh(ctx, link.ID, time.Now()); err != context.Canceled {
+		t.Fatalf("Refresh with a cancelled context = %v, want context.Canceled", err)
+	}
+
+	// another caller gets the same refresh, which completed regardless.
+	refreshed, err := refresher.Refresh(context.Background(), link.ID, time.Now())
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if refreshed.AccessToken == link.AccessToken || !refreshed.AccessTokenExpiration.After(time.Now()) {
+		t.Errorf("link not refreshed: %+v", refreshed)
+	}
+	if calls := it.fake.Calls(http.MethodPost, "/api/token"); calls != 1 {
+		t.Errorf("token requested %d times, want 1", calls)
+	}
+}
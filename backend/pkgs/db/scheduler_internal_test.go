@@ -0,0 +1,15 @@
+package db
+
+import (
+	"groove/pkgs/ent"
+	"groove/pkgs/spotify"
+)
+
+// NewTestScheduler returns a scheduler whose jobs are run by the tests themselves, rather than on its tickers.
+func NewTestScheduler(client *ent.Client, spotify *spotify.Client) *Scheduler {
+	return &Scheduler{
+		client:    client,
+		refresher: ProvideRefresher(client, spotify),
+		spotify:   spotify,
+	}
+}
@@ -0,0 +1,42 @@
+package db
+
+import (
+	"errors"
+	"strings"
+)
+
+// Scopes of personal access tokens; each endpoint accepting tokens requires one of them.
+// endpoints managing the account (password, sessions, tokens, ...) never accept tokens, only sessions.
+const (
+	ScopeCatalogRead    = "catalog:read"    // artists, albums, tracks and search.
+	ScopeProfileRead    = "profile:read"    // the linked Spotify profile.
+	ScopePlaylistsRead  = "playlists:read"  // playlists and their tracks.
+	ScopePlaylistsWrite = "playlists:write" // creating, editing and deleting playlists, their tracks and imports.
+)
+
+// Scopes are all the scopes a personal access token can be given.
+var Scopes = []string{ScopeCatalogRead, ScopeProfileRead, ScopePlaylistsRead, ScopePlaylistsWrite}
+
+// ValidateScopes validates the scopes given to a personal access token: at least one, all known, none repeated.
+func ValidateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return errors.New("invalid scopes: must contain at least 1 scope")
+	}
+
+	seen := make(map[string]bool, len(scopes))
+	for _, scope := range scopes {
+		if seen[scope] {
+			return errors.New("invalid scopes: " + scope + " is repeated")
+		}
+		seen[scope] = true
+
+		var known bool
+		for _, s := range Scopes {
+			known = known || s == scope
+		}
+		if !known {
+			return errors.New("invalid scopes: " + scope + " is unknown, must be one of " + strings.Join(Scopes, ", "))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package db
+
+import (
+	"context"
+	"groove/pkgs/ent"
+	Throttle "groove/pkgs/ent/throttle"
+	"time"
+)
+
+// ThrottleWindow is the longest a Policy's window can be; throttles quiet for longer are cleaned by the scheduler.
+const ThrottleWindow = 24 * time.Hour
+
+// Policy is how many attempts a key is allowed before being locked out, and for how long.
+type Policy struct {
+	// Free is how many attempts are allowed before the first lockout.
+	Free int
+	// Base is the first lockout, doubled on every attempt after it, up to Max.
+	Base time.Duration
+	Max  time.Duration
+	// Window is how long a key has to be quiet for its attempts to be forgotten (at most ThrottleWindow).
+	Window time.Duration
+}
+
+// lockout returns how long a key is locked out for after its count-th attempt.
+func (p Policy) lockout(count int) time.Duration {
+	if count <= p.Free {
+		return 0
+	}
+	lock := p.Base
+	for i := p.Free + 1; i < count && lock < p.Max; i++ {
+		lock *= 2
+	}
+	if lock > p.Max {
+		lock = p.Max
+	}
+	return lock
+}
+
+// Throttles tracks attempts of actions (i.e. failed logins) per key, locking keys out exponentially.
+// the state is kept in the database, so lockouts survive restarts.
+type Throttles struct {
+	client *ent.Client
+}
+
+func ProvideThrottles(client *ent.Client) *Throttles {
+	return &Throttles{client: client}
+}
+
+// Locked returns how long the key remains locked out for, or 0 if it isn't.
+func (t *Throttles) Locked(ctx context.Context, key string) (time.Duration, error) {
+	throttle, err := t.client.Throttle.
+		Query().
+		Where(Throttle.KeyEQ(key)).
+		First(ctx)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	if throttle.LockedUntil == nil {
+		return 0, nil
+	}
+	return time.Until(*throttle.LockedUntil), nil
+}
+
+// Record records an attempt under the key, returning how long the key is now locked out for, or 0 if it isn't.
+// the count is incremented in the database, so concurrent attempts cannot slip through uncounted.
+func (t *Throttles) Record(ctx context.Context, key string, policy Policy) (time.Duration, error) {
+	now := time.Now()
+
+	affected, err := t.client.Throttle.
+		Update().
+		Where(
+			Throttle.KeyEQ(key),
+			Throttle.LastAttemptGTE(now.Add(-policy.Window)),
+		).
+		AddCount(1).
+		SetLastAttempt(now).
+		Save(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// attempts are forgotten once the key was quiet for a whole window.
+	if affected == 0 {
+		affected, err = t.client.Throttle.
+			Update().
+			Where(Throttle.KeyEQ(key)).
+			SetCount(1).
+			SetLastAttempt(now).
+			ClearLockedUntil().
+			Save(ctx)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if affected == 0 {
+		err = t.client.Throttle.Create().
+			SetKey(key).
+			SetCount(1).
+			SetLastAttempt(now).
+			Exec(ctx)
+		if ent.IsConstraintError(err) {
+			// created by a concurrent attempt; count this one on top of it.
+			return t.Record(ctx, key, policy)
+		} else if err != nil {
+			return 0, err
+		}
+	}
+
+	throttle, err := t.client.Throttle.
+		Query().
+		Where(Throttle.KeyEQ(key)).
+		First(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	lock := policy.lockout(throttle.Count)
+	if lock == 0 {
+		return 0, nil
+	}
+
+	// never shorten a lockout set by a concurrent attempt.
+	until := now.Add(lock)
+	_, err = t.client.Throttle.
+		Update().
+		Where(
+			Throttle.KeyEQ(key),
+			Throttle.Or(
+				Throttle.LockedUntilIsNil(),
+				Throttle.LockedUntilLT(until),
+			),
+		).
+		SetLockedUntil(until).
+		Save(ctx)
+	return lock, err
+}
+
+// Reset forgets the attempts of the key, i.e. once a login succeeds.
+func (t *Throttles) Reset(ctx context.Context, key string) error {
+	_, err := t.client.Throttle.
+		Delete().
+		Where(Throttle.KeyEQ(key)).
+		Exec(ctx)
+	return err
+}
@@ -62,3 +62,27 @@ func ValidateUser(username, email, password string) error {
 	}
 	return nil
 }
+
+// ValidatePassword validates a password on its own, i.e. when it is reset or changed.
+func ValidatePassword(password string) error {
+	return validateField(FieldPassword, password)
+}
+
+// ValidateUsername validates a username on its own, i.e. when it is changed.
+func ValidateUsername(username string) error {
+	return validateField(FieldUsername, username)
+}
+
+// ValidateEmail validates an email on its own, i.e. when it is changed.
+func ValidateEmail(email string) error {
+	return validateField(FieldEmail, email)
+}
+
+func validateField(field UserField, value string) error {
+	for _, v := range userValidators {
+		if v.Field == field && !v.Regex.MatchString(value) {
+			return errors.New(v.Message)
+		}
+	}
+	return nil
+}
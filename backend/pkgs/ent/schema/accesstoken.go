@@ -0,0 +1,56 @@
+package schema
+
+import (
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+	"time"
+)
+
+/*
+ * AccessToken is a personal access token, created by a user to script against the API. It is sent as an
+ * `Authorization: Bearer` header instead of the session cookies, and only to the endpoints allowed by its scopes.
+ * The token is only shown once, on creation; only its SHA-256 hash is stored. It is revoked by deleting it, and
+ * expired ones are cleaned by the scheduler.
+ */
+
+// AccessToken holds the schema definition for the AccessToken entity.
+type AccessToken struct {
+	ent.Schema
+}
+
+// Fields of the AccessToken.
+func (AccessToken) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int("id").Immutable(),
+		field.Int("user_id"),
+		field.String("name").NotEmpty().MaxLen(64),
+		field.String("token_hash").Sensitive().Unique().NotEmpty(),
+		// scopes are the endpoints the token can be used for; see db.Scopes.
+		field.Strings("scopes"),
+		field.Time("created_at").Default(time.Now).Immutable(),
+		// last_used is only updated about once a minute, like the last_seen of sessions.
+		field.Time("last_used").Optional().Nillable(),
+		// expiration is not set for tokens that never expire.
+		field.Time("expiration").Optional().Nillable(),
+	}
+}
+
+// Edges of the AccessToken.
+func (AccessToken) Edges() []ent.Edge {
+	return []ent.Edge{
+		// M2O AccessToken <--> User(required)
+		edge.From("user", User.Type).Ref("access_token").Field("user_id").Unique().
+			// Required() to make edge required on creation;
+			// i.e. AccessToken cannot be created without its linked User
+			Required(),
+	}
+}
+
+// Indexes of the AccessToken.
+func (AccessToken) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("expiration"),
+	}
+}
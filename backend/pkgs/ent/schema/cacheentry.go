@@ -0,0 +1,35 @@
+package schema
+
+import (
+	"entgo.io/ent"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+/*
+ * CacheEntry is the persistent tier of the catalog cache. Spotify catalog responses (artists, albums, tracks)
+ * barely change, so they are kept here to survive restarts and be shared between instances.
+ * Entries are looked up by their normalized key and lazily deleted by the scheduler once expired.
+ */
+
+// CacheEntry holds the schema definition for the CacheEntry entity.
+type CacheEntry struct {
+	ent.Schema
+}
+
+// Fields of the CacheEntry.
+func (CacheEntry) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int("id").Immutable(),
+		field.String("key").Unique().NotEmpty(),
+		field.Bytes("value"),
+		field.Time("expiration"),
+	}
+}
+
+// Indexes of the CacheEntry.
+func (CacheEntry) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("expiration"),
+	}
+}
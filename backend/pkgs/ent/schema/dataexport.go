@@ -0,0 +1,53 @@
+package schema
+
+import (
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+	"time"
+)
+
+/*
+ * DataExport is an export of everything Groove holds about a user, requested from the account settings.
+ * It is built in the background by the scheduler: pending -> running -> ready (or failed). Once ready, the bundle
+ * (a zip of JSON files) is stored along with the SHA-256 hash of a token, which is emailed to the user as a download
+ * link; it expires with the export, which is then cleaned by the scheduler. A user has at most one export.
+ */
+
+// DataExport holds the schema definition for the DataExport entity.
+type DataExport struct {
+	ent.Schema
+}
+
+// Fields of the DataExport.
+func (DataExport) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int("id").Immutable(),
+		field.Int("user_id").Unique(),
+		field.Enum("status").Values("pending", "running", "ready", "failed").Default("pending"),
+		field.Bytes("bundle").Optional().Sensitive(),
+		field.String("token_hash").Optional().Nillable().Sensitive().Unique(),
+		field.Time("created_at").Default(time.Now).Immutable(),
+		// expiration is set once the export is ready, after which the download link no longer works.
+		field.Time("expiration").Optional().Nillable(),
+	}
+}
+
+// Edges of the DataExport.
+func (DataExport) Edges() []ent.Edge {
+	return []ent.Edge{
+		// O2O DataExport <--> User(required)
+		edge.From("user", User.Type).Ref("data_export").Field("user_id").Unique().
+			// Required() to make edge required on creation;
+			// i.e. DataExport cannot be created without its linked User
+			Required(),
+	}
+}
+
+// Indexes of the DataExport.
+func (DataExport) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("status"),
+	}
+}
@@ -0,0 +1,50 @@
+package schema
+
+import (
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+/*
+ * EmailVerification is a pending verification of a user's email. The token is emailed to the address being
+ * verified and only its SHA-256 hash is stored. A user has at most one pending verification: resending replaces
+ * its token, and sent_at rate limits resends. It is deleted once used; expired ones are cleaned by the scheduler.
+ */
+
+// EmailVerification holds the schema definition for the EmailVerification entity.
+type EmailVerification struct {
+	ent.Schema
+}
+
+// Fields of the EmailVerification.
+func (EmailVerification) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int("id").Immutable(),
+		field.Int("user_id").Unique(),
+		// email is the address being verified; it becomes the user's email once verified (i.e. after changing it).
+		field.String("email").Sensitive().MaxLen(320),
+		field.String("token_hash").Sensitive().Unique().NotEmpty(),
+		field.Time("expiration"),
+		field.Time("sent_at"),
+	}
+}
+
+// Edges of the EmailVerification.
+func (EmailVerification) Edges() []ent.Edge {
+	return []ent.Edge{
+		// O2O EmailVerification <--> User(required)
+		edge.From("user", User.Type).Ref("email_verification").Field("user_id").Unique().
+			// Required() to make edge required on creation;
+			// i.e. EmailVerification cannot be created without its linked User
+			Required(),
+	}
+}
+
+// Indexes of the EmailVerification.
+func (EmailVerification) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("expiration"),
+	}
+}
@@ -0,0 +1,53 @@
+package schema
+
+import (
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+	"groove/pkgs/secret"
+)
+
+/*
+ * LoginChallenge is a pending login of a user with two-factor authentication: the password was correct,
+ * but no Session is created until a TOTP or recovery code is given along with the challenge token.
+ * Challenges are short-lived and only allow a few attempts; only the SHA-256 hash of the token is stored.
+ */
+
+// LoginChallenge holds the schema definition for the LoginChallenge entity.
+type LoginChallenge struct {
+	ent.Schema
+}
+
+// Fields of the LoginChallenge.
+func (LoginChallenge) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int("id").Immutable(),
+		field.Int("user_id"),
+		field.String("token_hash").Sensitive().Unique().NotEmpty(),
+		field.Int("attempts").Default(0),
+		field.Time("expiration"),
+		// the tokens of a login with Spotify are only saved as the SpotifyLink of the user once the challenge
+		// is completed, so nothing is written on behalf of the user before their second factor is checked.
+		field.String("spotify_access_token").Optional().Sensitive().ValueScanner(secret.Field("spotify_access_token")),
+		field.String("spotify_refresh_token").Optional().Sensitive().ValueScanner(secret.Field("spotify_refresh_token")),
+	}
+}
+
+// Edges of the LoginChallenge.
+func (LoginChallenge) Edges() []ent.Edge {
+	return []ent.Edge{
+		// M2O LoginChallenge <--> User(required)
+		edge.From("user", User.Type).Ref("login_challenge").Field("user_id").Unique().
+			// Required() to make edge required on creation;
+			// i.e. LoginChallenge cannot be created without its linked User
+			Required(),
+	}
+}
+
+// Indexes of the LoginChallenge.
+func (LoginChallenge) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("expiration"),
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"entgo.io/ent"
 	"entgo.io/ent/schema/edge"
 	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
 )
 
 /*
@@ -11,6 +12,7 @@ import (
  * by the backend and forwarded to Spotify. Then Spotify, after authentication, will send the state back to the
  * backend during a redirect. The backend will then verify that the state is the same as the one it sent.
  * This is to prevent CSRF attacks. A temporary table is required to store the state to keep the backend stateless.
+ * States of a login with Spotify have no user; they are bound to the browser with a cookie instead.
  */
 
 // OAuthState holds the schema definition for the OAuthState entity.
@@ -22,7 +24,8 @@ type OAuthState struct {
 func (OAuthState) Fields() []ent.Field {
 	return []ent.Field{
 		field.Int("id").Immutable(),
-		field.Int("user_id").Unique(),
+		// user_id is empty for states of a login with Spotify, where there is no user yet.
+		field.Int("user_id").Optional().Unique(),
 		field.String("state").MinLen(16).MaxLen(16),
 		field.Time("expiration"),
 	}
@@ -31,9 +34,14 @@ func (OAuthState) Fields() []ent.Field {
 // Edges of the OAuthState.
 func (OAuthState) Edges() []ent.Edge {
 	return []ent.Edge{
-		edge.From("user", User.Type).Ref("oauth_state").Field("user_id").Unique().
-			// Required() to make edge required on creation;
-			// i.e. state cannot be created without its linked User
-			Required(),
+		// not Required(), as logging in with Spotify starts without a User.
+		edge.From("user", User.Type).Ref("oauth_state").Field("user_id").Unique(),
+	}
+}
+
+// Indexes of the OAuthState.
+func (OAuthState) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("state"),
 	}
 }
@@ -0,0 +1,47 @@
+package schema
+
+import (
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+/*
+ * PasswordReset is a pending password reset. The token is emailed to the user and only its SHA-256 hash
+ * is stored, so a leaked database cannot be used to reset passwords. Resets are single-use: they are deleted
+ * once used (along with every other reset of the user), and expired ones are cleaned by the scheduler.
+ */
+
+// PasswordReset holds the schema definition for the PasswordReset entity.
+type PasswordReset struct {
+	ent.Schema
+}
+
+// Fields of the PasswordReset.
+func (PasswordReset) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int("id").Immutable(),
+		field.Int("user_id"),
+		field.String("token_hash").Sensitive().Unique().NotEmpty(),
+		field.Time("expiration"),
+	}
+}
+
+// Edges of the PasswordReset.
+func (PasswordReset) Edges() []ent.Edge {
+	return []ent.Edge{
+		// M2O PasswordReset <--> User(required)
+		edge.From("user", User.Type).Ref("password_reset").Field("user_id").Unique().
+			// Required() to make edge required on creation;
+			// i.e. PasswordReset cannot be created without its linked User
+			Required(),
+	}
+}
+
+// Indexes of the PasswordReset.
+func (PasswordReset) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("expiration"),
+	}
+}
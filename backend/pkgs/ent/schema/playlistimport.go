@@ -0,0 +1,92 @@
+package schema
+
+import (
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+	"groove/pkgs/playlistfile"
+	"time"
+)
+
+/*
+ * PlaylistImport is a playlist file uploaded by a user to be recreated on Spotify. It is processed in the background
+ * by the scheduler: pending -> matching -> review, where each entry of the file was matched to a Spotify track (or
+ * not) with a confidence, for the user to review. Once the user confirms it: confirmed -> creating -> done, where
+ * the playlist was created and the matched tracks added to it. Either step can end as failed. progress counts the
+ * entries matched, then the tracks added, and lets a job resume where it stopped. A run of the scheduler claims an
+ * import with a token of its own, which expires unless renewed as the run makes progress. Imports are cleaned by
+ * the scheduler a day after they were last updated.
+ */
+
+// PlaylistImport holds the schema definition for the PlaylistImport entity.
+type PlaylistImport struct {
+	ent.Schema
+}
+
+// ImportEntry is an entry of an imported file, along with the Spotify track it was matched to.
+type ImportEntry struct {
+	playlistfile.Entry
+	// Match is nil until the entry is matched, and if no track matched it.
+	Match *ImportMatch `json:"match,omitempty"`
+}
+
+// ImportMatch is the Spotify track an ImportEntry was matched to.
+type ImportMatch struct {
+	URI        string   `json:"uri"`
+	Title      string   `json:"title"`
+	Artists    []string `json:"artists"`
+	Album      string   `json:"album"`
+	DurationMs int      `json:"duration_ms"`
+	// Confidence is between 0 and 1; exact matches (by uri or ISRC) are 1.
+	Confidence float64 `json:"confidence"`
+	// Method is how the track was found: "uri", "isrc" or "search".
+	Method string `json:"method"`
+}
+
+// Fields of the PlaylistImport.
+func (PlaylistImport) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int("id").Immutable(),
+		field.Int("user_id"),
+		field.Enum("status").
+			Values("pending", "matching", "review", "confirmed", "creating", "done", "failed").
+			Default("pending"),
+		field.String("format"),
+		// name, description and public are those of the file until the user confirms the import.
+		field.String("name"),
+		field.String("description").Default(""),
+		field.Bool("public").Default(false),
+		field.JSON("entries", []ImportEntry{}),
+		field.Int("progress").Default(0),
+		// playlist_id is the Spotify playlist created for the import.
+		field.String("playlist_id").Optional().Nillable(),
+		// error is why the import failed.
+		field.String("error").Optional().Nillable(),
+		// claim is the token of the run that last claimed the import; it can be claimed by another run
+		// once claim_expiration has passed, i.e. when the server restarted in the middle of it.
+		field.String("claim").Optional().Nillable(),
+		field.Time("claim_expiration").Optional().Nillable(),
+		field.Time("created_at").Default(time.Now).Immutable(),
+		field.Time("updated_at").Default(time.Now).UpdateDefault(time.Now),
+	}
+}
+
+// Edges of the PlaylistImport.
+func (PlaylistImport) Edges() []ent.Edge {
+	return []ent.Edge{
+		// M2O PlaylistImport <--> User(required)
+		edge.From("user", User.Type).Ref("playlist_import").Field("user_id").Unique().
+			// Required() to make edge required on creation;
+			// i.e. PlaylistImport cannot be created without its linked User
+			Required(),
+	}
+}
+
+// Indexes of the PlaylistImport.
+func (PlaylistImport) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("status"),
+		index.Fields("updated_at"),
+	}
+}
@@ -0,0 +1,38 @@
+package schema
+
+import (
+	"entgo.io/ent"
+	"entgo.io/ent/schema/edge"
+	"entgo.io/ent/schema/field"
+)
+
+/*
+ * RecoveryCode is a single-use code that can be used instead of a TOTP code to log in, i.e. when the
+ * authenticator is lost. Codes are shown once when two-factor authentication is enabled and only their
+ * SHA-256 hash is stored; a code is deleted as soon as it is used.
+ */
+
+// RecoveryCode holds the schema definition for the RecoveryCode entity.
+type RecoveryCode struct {
+	ent.Schema
+}
+
+// Fields of the RecoveryCode.
+func (RecoveryCode) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int("id").Immutable(),
+		field.Int("user_id"),
+		field.String("code_hash").Sensitive().Unique().NotEmpty(),
+	}
+}
+
+// Edges of the RecoveryCode.
+func (RecoveryCode) Edges() []ent.Edge {
+	return []ent.Edge{
+		// M2O RecoveryCode <--> User(required)
+		edge.From("user", User.Type).Ref("recovery_code").Field("user_id").Unique().
+			// Required() to make edge required on creation;
+			// i.e. RecoveryCode cannot be created without its linked User
+			Required(),
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"entgo.io/ent"
 	"entgo.io/ent/schema/edge"
 	"entgo.io/ent/schema/field"
+	"time"
 )
 
 // Session holds the schema definition for the Session entity.
@@ -19,6 +20,12 @@ func (Session) Fields() []ent.Field {
 		field.String("token"),
 		field.String("csrf"),
 		field.Time("expiration"),
+		// where the session was started from, and last used from; shown to the user to recognize their sessions.
+		field.String("user_agent").Default("").MaxLen(512),
+		field.String("ip").Default(""),
+		field.Time("created_at").Default(time.Now).Immutable(),
+		// last_seen is only updated about once a minute, to avoid a write on every request.
+		field.Time("last_seen").Default(time.Now),
 	}
 }
 
@@ -4,6 +4,7 @@ import (
 	"entgo.io/ent"
 	"entgo.io/ent/schema/edge"
 	"entgo.io/ent/schema/field"
+	"groove/pkgs/secret"
 )
 
 // SpotifyLink holds the schema definition for the SpotifyLink entity.
@@ -16,9 +17,13 @@ func (SpotifyLink) Fields() []ent.Field {
 	return []ent.Field{
 		field.Int("id").Immutable(),
 		field.Int("user_id").Unique(),
-		field.String("access_token").MinLen(1),
+		// tokens are encrypted at rest; see pkgs/secret.
+		field.String("access_token").MinLen(1).Sensitive().ValueScanner(secret.Field("access_token")),
 		field.Time("access_token_expiration"),
-		field.String("refresh_token").MinLen(1),
+		field.String("refresh_token").MinLen(1).Sensitive().ValueScanner(secret.Field("refresh_token")),
+		// revoked is set once Spotify rejects the refresh token (invalid_grant);
+		// the link is then unusable until the user links their account again.
+		field.Bool("revoked").Default(false),
 	}
 }
 
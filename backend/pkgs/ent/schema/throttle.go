@@ -0,0 +1,37 @@
+package schema
+
+import (
+	"entgo.io/ent"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+/*
+ * Throttle tracks repeated attempts of an action under a key (i.e. failed logins of an IP or an account),
+ * locking the key out for exponentially longer as attempts keep coming. It is stored in the database, so
+ * lockouts survive restarts and are shared between instances. Stale throttles are cleaned by the scheduler.
+ */
+
+// Throttle holds the schema definition for the Throttle entity.
+type Throttle struct {
+	ent.Schema
+}
+
+// Fields of the Throttle.
+func (Throttle) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int("id").Immutable(),
+		field.String("key").Unique().NotEmpty(),
+		// count is the number of attempts since the key was last quiet for a whole window.
+		field.Int("count").Default(0),
+		field.Time("last_attempt"),
+		field.Time("locked_until").Optional().Nillable(),
+	}
+}
+
+// Indexes of the Throttle.
+func (Throttle) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("last_attempt"),
+	}
+}
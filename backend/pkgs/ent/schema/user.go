@@ -5,6 +5,7 @@ import (
 	"entgo.io/ent/dialect/entsql"
 	"entgo.io/ent/schema/edge"
 	"entgo.io/ent/schema/field"
+	"groove/pkgs/secret"
 	"regexp"
 )
 
@@ -18,10 +19,23 @@ func (User) Fields() []ent.Field {
 	return []ent.Field{
 		field.Int("id").Immutable(),
 		field.String("username").Unique().MinLen(4).MaxLen(16),
+		// password is empty for accounts created by logging in with Spotify, until they set one.
 		field.String("password").Sensitive(), // sensitive won't print in logs/stack-traces.
 		field.String("email").Sensitive().Unique().MinLen(4).MaxLen(320).Match(
 			regexp.MustCompile("^[a-zA-Z0-9+_.-]+@[a-zA-Z0-9.-]+$"), // regex to validate email
 		),
+		field.Bool("email_verified").Default(false),
+		// spotify_id is the Spotify user the account logs in with; set when Spotify is linked.
+		field.String("spotify_id").Optional().Nillable().Unique(),
+		// totp_secret is set on enrollment, but only used once totp_enabled is set by confirming a first code.
+		field.String("totp_secret").Optional().Sensitive().ValueScanner(secret.Field("totp_secret")),
+		field.Bool("totp_enabled").Default(false),
+		// totp_last_step is the time step of the last accepted code, so a code cannot be used twice.
+		field.Int64("totp_last_step").Default(0),
+		// role is "admin" for users managing other users through the admin API; set with `./main role`.
+		field.Enum("role").Values("user", "admin").Default("user"),
+		// disabled_at is set while an admin has disabled the account, which then cannot be logged in to.
+		field.Time("disabled_at").Optional().Nillable(),
 	}
 }
 
@@ -40,5 +54,33 @@ func (User) Edges() []ent.Edge {
 		edge.To("oauth_state", OAuthState.Type).Unique().
 			// When User is deleted, cascade State referencing it.
 			Annotations(entsql.OnDelete(entsql.Cascade)),
+		// O2O User <--> EmailVerification(optional)
+		edge.To("email_verification", EmailVerification.Type).Unique().
+			// When User is deleted, cascade EmailVerification referencing it.
+			Annotations(entsql.OnDelete(entsql.Cascade)),
+		// O2M User <--> PasswordReset
+		edge.To("password_reset", PasswordReset.Type).
+			// When User is deleted, cascade PasswordReset referencing it.
+			Annotations(entsql.OnDelete(entsql.Cascade)),
+		// O2M User <--> RecoveryCode
+		edge.To("recovery_code", RecoveryCode.Type).
+			// When User is deleted, cascade RecoveryCode referencing it.
+			Annotations(entsql.OnDelete(entsql.Cascade)),
+		// O2M User <--> LoginChallenge
+		edge.To("login_challenge", LoginChallenge.Type).
+			// When User is deleted, cascade LoginChallenge referencing it.
+			Annotations(entsql.OnDelete(entsql.Cascade)),
+		// O2O User <--> DataExport(optional)
+		edge.To("data_export", DataExport.Type).Unique().
+			// When User is deleted, cascade DataExport referencing it.
+			Annotations(entsql.OnDelete(entsql.Cascade)),
+		// O2M User <--> AccessToken
+		edge.To("access_token", AccessToken.Type).
+			// When User is deleted, cascade AccessToken referencing it.
+			Annotations(entsql.OnDelete(entsql.Cascade)),
+		// O2M User <--> PlaylistImport
+		edge.To("playlist_import", PlaylistImport.Type).
+			// When User is deleted, cascade PlaylistImport referencing it.
+			Annotations(entsql.OnDelete(entsql.Cascade)),
 	}
 }
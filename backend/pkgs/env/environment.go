@@ -7,18 +7,32 @@ import (
 	"go.uber.org/fx"
 	. "groove/pkgs/util"
 	"os"
+	"strings"
 )
 
 type Env struct {
-	Port          string
-	IsProd        bool
-	Secure        bool
-	SameSite      string
-	FrontendURL   string
-	BackendURL    string
-	PgURI         string
-	SpotifyClient string
-	SpotifySecret string
+	Port               string
+	IsProd             bool
+	Secure             bool
+	SameSite           string
+	FrontendURL        string
+	BackendURL         string
+	DBDriver           string
+	PgURI              string
+	SQLitePath         string
+	SpotifyClient      string
+	SpotifySecret      string
+	SpotifyAPIURL      string
+	SpotifyAccountsURL string
+	CacheDB            bool
+	TokenKeys          string
+	SMTPHost           string
+	SMTPPort           string
+	SMTPUsername       string
+	SMTPPassword       string
+	MailFrom           string
+	ProxyHeader        string
+	TrustedProxies     []string
 }
 
 func ProvideEnvVars(shutdowner fx.Shutdowner) *Env {
@@ -40,12 +54,40 @@ func ProvideEnvVars(shutdowner fx.Shutdowner) *Env {
 		// (variable for cookies) will be Lax if in production, None if in development;
 		// this is because during development, the frontend and backend are on different ports.
 		SameSite: os.Getenv("SAME_SITE"),
+		// "postgres" (default) or "sqlite3"; SQLite is meant for local development and tests.
+		DBDriver: os.Getenv("DB_DRIVER"),
 		PgURI:    os.Getenv("PG_URI"),
+		// (required only for sqlite3) path to the database file, or ":memory:".
+		SQLitePath: os.Getenv("SQLITE_PATH"),
 		// (required only for development) used to set CORS.
 		FrontendURL:   os.Getenv("FRONTEND_URL"),
 		BackendURL:    os.Getenv("BACKEND_URL"),
 		SpotifyClient: os.Getenv("SPOTIFY_CLIENT"),
 		SpotifySecret: os.Getenv("SPOTIFY_SECRET"),
+		// (optional) point the app at another Spotify API, i.e. a fake one during development or tests.
+		SpotifyAPIURL:      os.Getenv("SPOTIFY_API_URL"),
+		SpotifyAccountsURL: os.Getenv("SPOTIFY_ACCOUNTS_URL"),
+		// (optional) persists the catalog cache in the database, on top of the in-memory cache.
+		CacheDB: os.Getenv("CACHE_DB") == "true",
+		// keys encrypting Spotify tokens and TOTP secrets at rest, as "<key-id>:<base64 32-byte key>" separated by commas.
+		// the first key encrypts; the others only decrypt values encrypted before a rotation.
+		// (generate a key with `openssl rand -base64 32`, rotate with `./main reencrypt` before removing the old keys)
+		TokenKeys: os.Getenv("TOKEN_KEYS"),
+		// (optional) SMTP server used to send emails (i.e. password resets);
+		// when SMTP_HOST is not set, emails are printed to the logs instead.
+		SMTPHost:     os.Getenv("SMTP_HOST"),
+		SMTPPort:     os.Getenv("SMTP_PORT"),
+		SMTPUsername: os.Getenv("SMTP_USERNAME"),
+		SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+		MailFrom:     os.Getenv("MAIL_FROM"),
+		// (optional) header holding the client IP when behind a reverse proxy (i.e. X-Forwarded-For),
+		// only trusted from the comma separated TRUSTED_PROXIES (IPs or CIDR ranges), which it requires.
+		ProxyHeader:    os.Getenv("PROXY_HEADER"),
+		TrustedProxies: splitList(os.Getenv("TRUSTED_PROXIES")),
+	}
+
+	if env.DBDriver == "" {
+		env.DBDriver = "postgres"
 	}
 
 	if err := env.validate(); err != nil {
@@ -55,9 +97,23 @@ func ProvideEnvVars(shutdowner fx.Shutdowner) *Env {
 	return env
 }
 
-func (Env) validate() error {
+func (env Env) validate() error {
 	var errs []string
-	variables := []string{"PORT", "PROD", "SECURE", "SAME_SITE", "PG_URI", "SPOTIFY_CLIENT", "SPOTIFY_SECRET", "BACKEND_URL"}
+	variables := []string{"PORT", "PROD", "SECURE", "SAME_SITE", "SPOTIFY_CLIENT", "SPOTIFY_SECRET", "BACKEND_URL", "TOKEN_KEYS"}
+	switch env.DBDriver {
+	case "postgres":
+		variables = append(variables, "PG_URI")
+	case "sqlite3":
+		variables = append(variables, "SQLITE_PATH")
+	default:
+		errs = append(errs, "DB_DRIVER must be postgres or sqlite3")
+	}
+	if env.SMTPHost != "" {
+		variables = append(variables, "MAIL_FROM")
+	}
+	if env.ProxyHeader != "" {
+		variables = append(variables, "TRUSTED_PROXIES")
+	}
 	for _, variable := range variables {
 		if os.Getenv(variable) == "" {
 			errs = append(errs, variable+" is not set")
@@ -68,3 +124,23 @@ func (Env) validate() error {
 	}
 	return nil
 }
+
+// splitList splits a comma separated variable, ignoring empty items.
+func splitList(variable string) []string {
+	var items []string
+	for _, item := range strings.Split(variable, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// AppURL is the url of the frontend, i.e. for links sent by email.
+// in production, the frontend is served by the backend.
+func (env *Env) AppURL() string {
+	if env.FrontendURL != "" {
+		return env.FrontendURL
+	}
+	return env.BackendURL
+}
@@ -0,0 +1,19 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Log "sends" emails by printing them, for development and tests.
+type Log struct{}
+
+func (Log) Send(_ context.Context, msg Message) error {
+	fmt.Printf(
+		"%s [MAIL] [To: %s (Subject: %s)]\n%s\n",
+		time.Now().Format("15:04:05"),
+		msg.To, msg.Subject, msg.Body,
+	)
+	return nil
+}
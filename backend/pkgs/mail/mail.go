@@ -0,0 +1,32 @@
+package mail
+
+import (
+	"context"
+	"groove/pkgs/env"
+)
+
+// Message is a plain-text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender sends emails.
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// ProvideSender provides an SMTP sender when SMTP_HOST is set, and a Log sender otherwise (i.e. in development).
+func ProvideSender(env *env.Env) Sender {
+	if env.SMTPHost == "" {
+		return Log{}
+	}
+	return NewSMTP(SMTPConfig{
+		Host:     env.SMTPHost,
+		Port:     env.SMTPPort,
+		Username: env.SMTPUsername,
+		Password: env.SMTPPassword,
+		From:     env.MailFrom,
+	})
+}
@@ -0,0 +1,93 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+type SMTPConfig struct {
+	Host string
+	// Port defaults to 587 (submission with STARTTLS).
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// SMTP sends emails through an SMTP server, upgrading the connection with STARTTLS when offered.
+type SMTP struct {
+	config SMTPConfig
+}
+
+func NewSMTP(config SMTPConfig) *SMTP {
+	if config.Port == "" {
+		config.Port = "587"
+	}
+	return &SMTP{config: config}
+}
+
+func (s *SMTP) Send(ctx context.Context, msg Message) error {
+	if strings.ContainsAny(msg.To+msg.Subject, "\r\n") {
+		return errors.New("mail: header contains a line break")
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(s.config.Host, s.config.Port))
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	} else {
+		_ = conn.SetDeadline(time.Now().Add(30 * time.Second))
+	}
+
+	client, err := smtp.NewClient(conn, s.config.Host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer func() { _ = client.Close() }()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err = client.StartTLS(&tls.Config{ServerName: s.config.Host}); err != nil {
+			return err
+		}
+	}
+	if s.config.Username != "" {
+		// PlainAuth refuses to send credentials over an unencrypted connection (except to localhost).
+		if err = client.Auth(smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err = client.Mail(s.config.From); err != nil {
+		return err
+	}
+	if err = client.Rcpt(msg.To); err != nil {
+		return err
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w,
+		"From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s",
+		s.config.From, msg.To, msg.Subject, time.Now().Format(time.RFC1123Z),
+		strings.ReplaceAll(msg.Body, "\n", "\r\n"),
+	)
+	if err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -0,0 +1,144 @@
+package playlistfile
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// csvHeader is the header row of the CSV files written by this package, which marks them (see separatorMarker).
+var csvHeader = []string{"title", "artists", "album", "isrc", "duration_ms", "added_at", "uri"}
+
+const (
+	// artistSeparator joins the artists of an entry in a single field, in every format.
+	artistSeparator = "; "
+	// separatorMarker marks the files written by this package, whose artists are only ever joined by
+	// artistSeparator; as a directive of M3U files and a meta of XSPF and JSPF ones. CSV files are marked by csvHeader.
+	separatorMarker = "groove:artist-separator"
+)
+
+func writeCSV(w io.Writer, playlist *Playlist) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, entry := range playlist.Entries {
+		var duration, addedAt string
+		if entry.Duration > 0 {
+			duration = strconv.FormatInt(entry.Duration.Milliseconds(), 10)
+		}
+		if !entry.AddedAt.IsZero() {
+			addedAt = entry.AddedAt.UTC().Format(time.RFC3339)
+		}
+
+		err := writer.Write([]string{
+			entry.Title,
+			strings.Join(entry.Artists, artistSeparator),
+			entry.Album,
+			entry.ISRC,
+			duration,
+			addedAt,
+			entry.URI,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// readCSV reads a CSV file with a header row, as written by writeCSV or exported by other tools:
+// columns are found by name (case-insensitively), and only a title (or uri) column is required.
+// durations are read from duration_ms, or from duration in seconds or as m:ss.
+func readCSV(r io.Reader) (*Playlist, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("invalid csv: missing header row")
+	}
+	columns := map[string]int{}
+	marked := len(header) == len(csvHeader)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, bom)))
+		if _, ok := columns[name]; !ok {
+			columns[name] = i
+		}
+		marked = marked && name == csvHeader[i]
+	}
+	column := func(record []string, names ...string) string {
+		for _, name := range names {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+		}
+		return ""
+	}
+	if _, ok := columns["title"]; !ok {
+		if _, ok = columns["uri"]; !ok {
+			return nil, errors.New("invalid csv: missing title column")
+		}
+	}
+
+	playlist := new(Playlist)
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid csv: line %d: %w", line, err)
+		}
+
+		entry := Entry{
+			Title:   column(record, "title", "track", "name"),
+			Artists: splitArtists(column(record, "artists", "artist"), marked),
+			Album:   column(record, "album"),
+			ISRC:    strings.ToUpper(column(record, "isrc")),
+			URI:     spotifyURI(column(record, "uri", "spotify_uri", "url")),
+		}
+		if ms, err := strconv.Atoi(column(record, "duration_ms")); err == nil && ms > 0 {
+			entry.Duration = time.Duration(ms) * time.Millisecond
+		} else {
+			entry.Duration = parseDuration(column(record, "duration"))
+		}
+		if addedAt, err := time.Parse(time.RFC3339, column(record, "added_at")); err == nil {
+			entry.AddedAt = addedAt
+		}
+
+		if entry.Title == "" && entry.URI == "" {
+			continue
+		}
+		playlist.Entries = append(playlist.Entries, entry)
+		if len(playlist.Entries) > MaxEntries {
+			break
+		}
+	}
+	return playlist, nil
+}
+
+// parseDuration parses a duration in seconds ("213") or minutes and seconds ("3:33"); 0 if invalid.
+func parseDuration(value string) time.Duration {
+	minutes, seconds, found := strings.Cut(value, ":")
+	if !found {
+		minutes, seconds = "0", value
+	}
+
+	m, err := strconv.Atoi(minutes)
+	if err != nil || m < 0 {
+		return 0
+	}
+	s, err := strconv.Atoi(seconds)
+	if err != nil || s < 0 {
+		return 0
+	}
+	return time.Duration(m)*time.Minute + time.Duration(s)*time.Second
+}
@@ -0,0 +1,115 @@
+package playlistfile
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// writeM3U writes an extended M3U playlist, whose entries point to the Spotify urls of the tracks.
+func writeM3U(w io.Writer, playlist *Playlist) error {
+	writer := bufio.NewWriter(w)
+	_, _ = writer.WriteString("#EXTM3U\n")
+	_, _ = writer.WriteString("#" + separatorMarker + ":" + artistSeparator + "\n")
+	if playlist.Title != "" {
+		_, _ = writer.WriteString("#PLAYLIST:" + oneLine(playlist.Title) + "\n")
+	}
+
+	for _, entry := range playlist.Entries {
+		// -1 is the duration of an entry of unknown length.
+		seconds := -1
+		if entry.Duration > 0 {
+			seconds = int(entry.Duration.Seconds())
+		}
+
+		display := entry.Title
+		if len(entry.Artists) > 0 {
+			display = strings.Join(entry.Artists, artistSeparator) + " - " + entry.Title
+		}
+		_, _ = writer.WriteString("#EXTINF:" + strconv.Itoa(seconds) + "," + oneLine(display) + "\n")
+		if entry.Album != "" {
+			_, _ = writer.WriteString("#EXTALB:" + oneLine(entry.Album) + "\n")
+		}
+
+		location := entry.Location
+		if location == "" {
+			location = entry.URI
+		}
+		_, _ = writer.WriteString(oneLine(location) + "\n")
+	}
+	return writer.Flush()
+}
+
+// oneLine keeps a value from breaking the line-based format.
+func oneLine(s string) string {
+	return strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ").Replace(s)
+}
+
+// readM3U reads a plain or extended M3U file. entries are described by their #EXTINF ("Artists - Title");
+// entries without one are described by the name of their file instead.
+func readM3U(r io.Reader) (*Playlist, error) {
+	scanner := bufio.NewScanner(r)
+	playlist := new(Playlist)
+
+	var entry Entry
+	var described, marked bool
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), bom))
+		switch {
+		case line == "" || line == "#EXTM3U":
+		case strings.HasPrefix(line, "#"+separatorMarker+":"):
+			marked = true
+		case strings.HasPrefix(line, "#PLAYLIST:"):
+			playlist.Title = strings.TrimSpace(strings.TrimPrefix(line, "#PLAYLIST:"))
+		case strings.HasPrefix(line, "#EXTINF:"):
+			info := strings.TrimPrefix(line, "#EXTINF:")
+			seconds, display, _ := strings.Cut(info, ",")
+			// attributes (i.e. tvg-id="...") may follow the duration.
+			seconds, _, _ = strings.Cut(seconds, " ")
+			if n, err := strconv.Atoi(seconds); err == nil && n > 0 {
+				entry.Duration = time.Duration(n) * time.Second
+			}
+			entry.Artists, entry.Title = splitDisplay(display, marked)
+			described = true
+		case strings.HasPrefix(line, "#EXTALB:"):
+			entry.Album = strings.TrimSpace(strings.TrimPrefix(line, "#EXTALB:"))
+		case strings.HasPrefix(line, "#EXTART:"):
+			if len(entry.Artists) == 0 {
+				entry.Artists = splitArtists(strings.TrimPrefix(line, "#EXTART:"), marked)
+			}
+		case strings.HasPrefix(line, "#"):
+			// other directives and comments.
+		default:
+			entry.Location = line
+			entry.URI = spotifyURI(line)
+			if !described && entry.URI == "" {
+				name := path.Base(strings.ReplaceAll(line, "\\", "/"))
+				entry.Artists, entry.Title = splitDisplay(strings.TrimSuffix(name, path.Ext(name)), false)
+			}
+			if entry.Title != "" || entry.URI != "" {
+				playlist.Entries = append(playlist.Entries, entry)
+			}
+			if len(playlist.Entries) > MaxEntries {
+				return playlist, nil
+			}
+			entry, described = Entry{}, false
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.New("invalid m3u: " + err.Error())
+	}
+	return playlist, nil
+}
+
+// splitDisplay splits an "Artists - Title" display name; without a separator it is only a title.
+func splitDisplay(display string, marked bool) ([]string, string) {
+	artists, title, found := strings.Cut(display, " - ")
+	if !found {
+		return nil, strings.TrimSpace(display)
+	}
+	return splitArtists(artists, marked), strings.TrimSpace(title)
+}
@@ -0,0 +1,188 @@
+// Package playlistfile writes playlists in the file formats other players import: CSV, extended M3U, XSPF and JSPF;
+// and reads them back, except for XSPF.
+package playlistfile
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+type Format string
+
+const (
+	CSV  Format = "csv"
+	M3U  Format = "m3u"
+	XSPF Format = "xspf"
+	JSPF Format = "jspf"
+)
+
+// Formats are the supported formats, in the order they're listed to users.
+var Formats = []Format{CSV, M3U, XSPF, JSPF}
+
+// ParseFormat returns the format with the given name, case-insensitively.
+func ParseFormat(name string) (Format, error) {
+	for _, format := range Formats {
+		if strings.EqualFold(name, string(format)) {
+			return format, nil
+		}
+	}
+	return "", errors.New("invalid format: must be one of csv, m3u, xspf, jspf")
+}
+
+// ContentType is the media type of files of the format.
+func (f Format) ContentType() string {
+	switch f {
+	case CSV:
+		return "text/csv; charset=utf-8"
+	case M3U:
+		return "audio/x-mpegurl; charset=utf-8"
+	case XSPF:
+		return "application/xspf+xml"
+	case JSPF:
+		return "application/jspf+json"
+	}
+	return "application/octet-stream"
+}
+
+// Extension is the file extension of the format, including the dot.
+func (f Format) Extension() string {
+	switch f {
+	case M3U:
+		return ".m3u8"
+	}
+	return "." + string(f)
+}
+
+// Playlist is a playlist as found in a file.
+type Playlist struct {
+	Title       string
+	Description string
+	Creator     string
+	// Location is where the playlist can be found, i.e. its Spotify url.
+	Location string
+	Entries  []Entry
+}
+
+// Entry is a track of a Playlist.
+type Entry struct {
+	Title   string   `json:"title"`
+	Artists []string `json:"artists"`
+	Album   string   `json:"album,omitempty"`
+	ISRC    string   `json:"isrc,omitempty"`
+	// Duration is 0 if unknown.
+	Duration time.Duration `json:"duration,omitempty"`
+	// AddedAt is when the track was added to the playlist; zero if unknown.
+	AddedAt time.Time `json:"added_at"`
+	// Location is where the track can be played, i.e. its Spotify url.
+	Location string `json:"location,omitempty"`
+	// URI is the Spotify uri of the track, if known.
+	URI string `json:"uri,omitempty"`
+}
+
+// Write writes the playlist to w in the given format.
+func Write(w io.Writer, format Format, playlist *Playlist) error {
+	switch format {
+	case CSV:
+		return writeCSV(w, playlist)
+	case M3U:
+		return writeM3U(w, playlist)
+	case XSPF:
+		return writeXSPF(w, playlist)
+	case JSPF:
+		return writeJSPF(w, playlist)
+	}
+	return errors.New("playlistfile: unknown format " + string(format))
+}
+
+// bom is the byte order mark some editors start text files with.
+const bom = "\uFEFF"
+
+// MaxEntries is the most entries Read accepts, the most tracks a Spotify playlist can hold.
+const MaxEntries = 10000
+
+// Read reads a playlist in the given format from r. XSPF files cannot be read.
+// entries without a title (nor a Spotify uri to identify them) are skipped.
+func Read(r io.Reader, format Format) (*Playlist, error) {
+	var playlist *Playlist
+	var err error
+	switch format {
+	case CSV:
+		playlist, err = readCSV(r)
+	case M3U:
+		playlist, err = readM3U(r)
+	case JSPF:
+		playlist, err = readJSPF(r)
+	default:
+		return nil, errors.New("invalid format: " + string(format) + " files cannot be imported")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(playlist.Entries) == 0 {
+		return nil, errors.New("no tracks found in the file")
+	}
+	if len(playlist.Entries) > MaxEntries {
+		return nil, fmt.Errorf("too many tracks: a playlist holds at most %d", MaxEntries)
+	}
+	return playlist, nil
+}
+
+// FormatOf returns the format of a file from its extension.
+func FormatOf(filename string) (Format, bool) {
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".csv":
+		return CSV, true
+	case ".m3u", ".m3u8":
+		return M3U, true
+	case ".xspf":
+		return XSPF, true
+	case ".jspf":
+		return JSPF, true
+	}
+	return "", false
+}
+
+// spotifyURI returns the Spotify uri of a track from its uri or its open.spotify.com url, or "" if it is neither.
+func spotifyURI(location string) string {
+	location = strings.TrimSpace(location)
+	if id, found := strings.CutPrefix(location, "spotify:track:"); found && id != "" {
+		return location
+	}
+	for _, prefix := range []string{"https://open.spotify.com/track/", "http://open.spotify.com/track/"} {
+		if id, found := strings.CutPrefix(location, prefix); found {
+			id, _, _ = strings.Cut(id, "?")
+			if id != "" {
+				return "spotify:track:" + id
+			}
+		}
+	}
+	return ""
+}
+
+// splitArtists splits the artists of a single field. files written by this package (see separatorMarker) always
+// join them by artistSeparator, so artists with a comma in their name (i.e. "Tyler, The Creator") are kept whole,
+// even on their own; fields of other files are split on semicolons if they have any, or else on commas.
+func splitArtists(artists string, marked bool) []string {
+	separator := ","
+	if marked || strings.Contains(artists, ";") {
+		separator = ";"
+	}
+
+	var split []string
+	for _, artist := range strings.Split(artists, separator) {
+		if artist = strings.TrimSpace(artist); artist != "" {
+			split = append(split, artist)
+		}
+	}
+	return split
+}
+
+// isrcURN is the identifier of a recording by ISRC in XSPF and JSPF.
+func isrcURN(isrc string) string {
+	return "urn:isrc:" + isrc
+}
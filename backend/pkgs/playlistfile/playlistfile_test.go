@@ -0,0 +1,97 @@
+package playlistfile
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRoundTrip(t *testing.T) {
+	playlist := &Playlist{
+		Title: "Round Trip",
+		Entries: []Entry{
+			{
+				Title:    "Neon Avenue",
+				Artists:  []string{"Nova Lights"},
+				Album:    "Midnight Transit",
+				ISRC:     "USGRV2100001",
+				Duration: 213 * time.Second,
+				Location: "https://open.spotify.com/track/7NeonAvenue0000000001",
+				URI:      "spotify:track:7NeonAvenue0000000001",
+			},
+			{
+				Title:    "Afterglow",
+				Artists:  []string{"Tyler, The Creator", "Nova Lights"},
+				Duration: 198 * time.Second,
+				Location: "https://open.spotify.com/track/7Afterglow00000000002",
+				URI:      "spotify:track:7Afterglow00000000002",
+			},
+			{
+				// a lone artist with a comma is kept whole too.
+				Title:    "Earfquake",
+				Artists:  []string{"Tyler, The Creator"},
+				Duration: 190 * time.Second,
+				Location: "https://open.spotify.com/track/7Earfquake0000000003",
+				URI:      "spotify:track:7Earfquake0000000003",
+			},
+		},
+	}
+
+	for _, format := range []Format{CSV, M3U, JSPF} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Write(&buf, format, playlist); err != nil {
+				t.Fatalf("writing: %v", err)
+			}
+			read, err := Read(&buf, format)
+			if err != nil {
+				t.Fatalf("reading: %v", err)
+			}
+
+			// CSV files only hold the entries.
+			if format != CSV && read.Title != playlist.Title {
+				t.Errorf("title = %q, want %q", read.Title, playlist.Title)
+			}
+			if len(read.Entries) != len(playlist.Entries) {
+				t.Fatalf("%d entries, want %d", len(read.Entries), len(playlist.Entries))
+			}
+			for i, entry := range read.Entries {
+				want := playlist.Entries[i]
+				if entry.Title != want.Title || !reflect.DeepEqual(entry.Artists, want.Artists) {
+					t.Errorf("entry %d = %q by %q, want %q by %q", i, entry.Title, entry.Artists, want.Title, want.Artists)
+				}
+				if entry.URI != want.URI || entry.Duration != want.Duration {
+					t.Errorf("entry %d = %s (%s), want %s (%s)", i, entry.URI, entry.Duration, want.URI, want.Duration)
+				}
+			}
+		})
+	}
+}
+
+func TestReadForeignArtists(t *testing.T) {
+	// files of other tools join artists by semicolons or commas.
+	tests := []struct {
+		format Format
+		file   string
+	}{
+		{CSV, "Title,Artist\nEarfquake,\"Tyler, Nova Lights\"\nEarfquake,Tyler; Nova Lights\n"},
+		{M3U, "#EXTM3U\n#EXTINF:190,Tyler, Nova Lights - Earfquake\nearfquake.mp3\n#EXTINF:190,Tyler; Nova Lights - Earfquake\nearfquake.mp3\n"},
+		{JSPF, `{"playlist": {"track": [{"title": "Earfquake", "creator": "Tyler, Nova Lights"}, {"title": "Earfquake", "creator": "Tyler; Nova Lights"}]}}`},
+	}
+	for _, test := range tests {
+		read, err := Read(strings.NewReader(test.file), test.format)
+		if err != nil {
+			t.Fatalf("%s: reading: %v", test.format, err)
+		}
+		if len(read.Entries) != 2 {
+			t.Fatalf("%s: %d entries, want 2", test.format, len(read.Entries))
+		}
+		for i, entry := range read.Entries {
+			if want := []string{"Tyler", "Nova Lights"}; !reflect.DeepEqual(entry.Artists, want) {
+				t.Errorf("%s: entry %d by %q, want %q", test.format, i, entry.Artists, want)
+			}
+		}
+	}
+}
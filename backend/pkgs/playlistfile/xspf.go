@@ -0,0 +1,182 @@
+package playlistfile
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+type xspfPlaylist struct {
+	XMLName    xml.Name   `xml:"http://xspf.org/ns/0/ playlist"`
+	Version    string     `xml:"version,attr"`
+	Title      string     `xml:"title,omitempty"`
+	Creator    string     `xml:"creator,omitempty"`
+	Annotation string     `xml:"annotation,omitempty"`
+	Location   string     `xml:"location,omitempty"`
+	Meta       []xspfMeta `xml:"meta"`
+	// TrackList is always written, as XSPF requires it even when empty.
+	TrackList struct {
+		Tracks []xspfTrack `xml:"track"`
+	} `xml:"trackList"`
+}
+
+type xspfMeta struct {
+	Rel     string `xml:"rel,attr"`
+	Content string `xml:",chardata"`
+}
+
+type xspfTrack struct {
+	Locations   []string `xml:"location"`
+	Identifiers []string `xml:"identifier"`
+	Title       string   `xml:"title,omitempty"`
+	Creator     string   `xml:"creator,omitempty"`
+	Album       string   `xml:"album,omitempty"`
+	// Duration is in milliseconds.
+	Duration int64 `xml:"duration,omitempty"`
+}
+
+// jspfFile is a JSPF file, XSPF in JSON where locations and identifiers are always arrays.
+type jspfFile struct {
+	Playlist jspfPlaylist `json:"playlist"`
+}
+
+type jspfPlaylist struct {
+	Title      string           `json:"title,omitempty"`
+	Creator    string           `json:"creator,omitempty"`
+	Annotation string           `json:"annotation,omitempty"`
+	Location   string           `json:"location,omitempty"`
+	Meta       []map[string]any `json:"meta,omitempty"`
+	Tracks     []jspfTrack      `json:"track"`
+}
+
+type jspfTrack struct {
+	Locations   []string `json:"location,omitempty"`
+	Identifiers []string `json:"identifier,omitempty"`
+	Title       string   `json:"title,omitempty"`
+	Creator     string   `json:"creator,omitempty"`
+	Album       string   `json:"album,omitempty"`
+	Duration    int64    `json:"duration,omitempty"`
+}
+
+// spiffTrack returns the fields shared by XSPF and JSPF tracks.
+func spiffTrack(entry Entry) (locations, identifiers []string, creator string, duration int64) {
+	if entry.Location != "" {
+		locations = append(locations, entry.Location)
+	}
+	if entry.URI != "" {
+		identifiers = append(identifiers, entry.URI)
+	}
+	if entry.ISRC != "" {
+		identifiers = append(identifiers, isrcURN(entry.ISRC))
+	}
+	return locations, identifiers, strings.Join(entry.Artists, artistSeparator), entry.Duration.Milliseconds()
+}
+
+func writeXSPF(w io.Writer, playlist *Playlist) error {
+	file := xspfPlaylist{
+		Version:    "1",
+		Title:      playlist.Title,
+		Creator:    playlist.Creator,
+		Annotation: playlist.Description,
+		Location:   playlist.Location,
+		Meta:       []xspfMeta{{Rel: separatorMarker, Content: artistSeparator}},
+	}
+	file.TrackList.Tracks = make([]xspfTrack, len(playlist.Entries))
+	for i, entry := range playlist.Entries {
+		locations, identifiers, creator, duration := spiffTrack(entry)
+		file.TrackList.Tracks[i] = xspfTrack{
+			Locations:   locations,
+			Identifiers: identifiers,
+			Title:       entry.Title,
+			Creator:     creator,
+			Album:       entry.Album,
+			Duration:    duration,
+		}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(file); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func writeJSPF(w io.Writer, playlist *Playlist) error {
+	file := jspfFile{Playlist: jspfPlaylist{
+		Title:      playlist.Title,
+		Creator:    playlist.Creator,
+		Annotation: playlist.Description,
+		Location:   playlist.Location,
+		Meta:       []map[string]any{{separatorMarker: artistSeparator}},
+		Tracks:     make([]jspfTrack, len(playlist.Entries)),
+	}}
+	for i, entry := range playlist.Entries {
+		locations, identifiers, creator, duration := spiffTrack(entry)
+		file.Playlist.Tracks[i] = jspfTrack{
+			Locations:   locations,
+			Identifiers: identifiers,
+			Title:       entry.Title,
+			Creator:     creator,
+			Album:       entry.Album,
+			Duration:    duration,
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(file)
+}
+
+func readJSPF(r io.Reader) (*Playlist, error) {
+	var file jspfFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, errors.New("invalid jspf: " + err.Error())
+	}
+
+	playlist := &Playlist{
+		Title:       file.Playlist.Title,
+		Description: file.Playlist.Annotation,
+		Creator:     file.Playlist.Creator,
+		Location:    file.Playlist.Location,
+	}
+	var marked bool
+	for _, meta := range file.Playlist.Meta {
+		_, found := meta[separatorMarker]
+		marked = marked || found
+	}
+	for _, track := range file.Playlist.Tracks {
+		entry := Entry{
+			Title:    strings.TrimSpace(track.Title),
+			Artists:  splitArtists(track.Creator, marked),
+			Album:    strings.TrimSpace(track.Album),
+			Duration: time.Duration(track.Duration) * time.Millisecond,
+		}
+		for _, identifier := range append(track.Identifiers, track.Locations...) {
+			if isrc, found := strings.CutPrefix(identifier, isrcURN("")); found && entry.ISRC == "" {
+				entry.ISRC = strings.ToUpper(isrc)
+			} else if uri := spotifyURI(identifier); uri != "" && entry.URI == "" {
+				entry.URI = uri
+			}
+		}
+		if len(track.Locations) > 0 {
+			entry.Location = track.Locations[0]
+		}
+
+		if entry.Title == "" && entry.URI == "" {
+			continue
+		}
+		playlist.Entries = append(playlist.Entries, entry)
+		if len(playlist.Entries) > MaxEntries {
+			break
+		}
+	}
+	return playlist, nil
+}
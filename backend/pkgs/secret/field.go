@@ -0,0 +1,58 @@
+package secret
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"entgo.io/ent/schema/field"
+	"errors"
+	"go.uber.org/fx"
+	"groove/pkgs/env"
+	. "groove/pkgs/util"
+	"sync/atomic"
+)
+
+// keyring is the Keyring used by encrypted ent fields.
+// it is global because ent schemas (and their ValueScanners) are static; it is set once on startup.
+var keyring atomic.Pointer[Keyring]
+
+var errNoKeyring = errors.New("secret: keyring not set")
+
+// Use sets the Keyring used by encrypted ent fields.
+func Use(k *Keyring) {
+	keyring.Store(k)
+}
+
+// InvokeKeyring parses the keyring from the environment and sets it for encrypted ent fields.
+func InvokeKeyring(shutdowner fx.Shutdowner, env *env.Env) {
+	k, err := ParseKeyring(env.TokenKeys)
+	if err != nil {
+		LogError("InvokeKeyring", "failed to parse TOKEN_KEYS", err)
+		_ = shutdowner.Shutdown()
+		return
+	}
+	Use(k)
+}
+
+// Field returns a ValueScanner that transparently encrypts a string ent field at rest.
+// the column name is authenticated along with the value, so values cannot be swapped between columns.
+func Field(column string) field.ValueScannerFunc[string, *sql.NullString] {
+	return field.ValueScannerFunc[string, *sql.NullString]{
+		V: func(plaintext string) (driver.Value, error) {
+			k := keyring.Load()
+			if k == nil {
+				return nil, errNoKeyring
+			}
+			return k.Encrypt(plaintext, column)
+		},
+		S: func(ns *sql.NullString) (string, error) {
+			if !ns.Valid {
+				return "", nil
+			}
+			k := keyring.Load()
+			if k == nil {
+				return "", errNoKeyring
+			}
+			return k.Decrypt(ns.String, column)
+		},
+	}
+}
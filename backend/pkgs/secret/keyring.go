@@ -0,0 +1,115 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+/*
+ * Values are encrypted with AES-256-GCM and stored as "enc:<key-id>:<base64(nonce|ciphertext)>".
+ * The key id recorded in each value allows keys to be rotated: new values are encrypted under the primary
+ * key, while values encrypted under any other key of the keyring can still be decrypted (and re-encrypted).
+ */
+
+const prefix = "enc:"
+
+var (
+	ErrUnknownKey = errors.New("secret: value encrypted under an unknown key")
+	ErrMalformed  = errors.New("secret: malformed encrypted value")
+)
+
+// Keyring holds the keys used to encrypt and decrypt values.
+type Keyring struct {
+	primary string
+	aeads   map[string]cipher.AEAD
+}
+
+// ParseKeyring parses a comma-separated list of "<key-id>:<base64 32-byte key>".
+// the first key is the primary key, used for encryption; the others are only used for decryption.
+func ParseKeyring(spec string) (*Keyring, error) {
+	keyring := &Keyring{aeads: map[string]cipher.AEAD{}}
+
+	for _, entry := range strings.Split(spec, ",") {
+		id, encoded, found := strings.Cut(strings.TrimSpace(entry), ":")
+		if !found || id == "" {
+			return nil, errors.New("secret: invalid key entry: expected <key-id>:<base64 key>")
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("secret: key %q must be 32 bytes encoded as base64", id)
+		}
+		if _, exists := keyring.aeads[id]; exists {
+			return nil, fmt.Errorf("secret: duplicate key %q", id)
+		}
+
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+
+		keyring.aeads[id] = aead
+		if keyring.primary == "" {
+			keyring.primary = id
+		}
+	}
+
+	return keyring, nil
+}
+
+// Encrypt encrypts plaintext under the primary key.
+// additional is authenticated but not encrypted; the same data must be given to Decrypt.
+func (k *Keyring) Encrypt(plaintext, additional string) (string, error) {
+	aead := k.aeads[k.primary]
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(additional))
+	return prefix + k.primary + ":" + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts a value produced by Encrypt under any key of the keyring.
+// values without the encryption prefix are assumed to predate encryption and are returned as is.
+func (k *Keyring) Decrypt(value, additional string) (string, error) {
+	if !strings.HasPrefix(value, prefix) {
+		return value, nil
+	}
+
+	id, encoded, found := strings.Cut(strings.TrimPrefix(value, prefix), ":")
+	if !found {
+		return "", ErrMalformed
+	}
+	aead, ok := k.aeads[id]
+	if !ok {
+		return "", ErrUnknownKey
+	}
+
+	sealed, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", ErrMalformed
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(additional))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// Primary returns the id of the key new values are encrypted under.
+func (k *Keyring) Primary() string {
+	return k.primary
+}
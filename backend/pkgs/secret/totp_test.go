@@ -0,0 +1,82 @@
+package secret
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA1 secret of the test vectors of RFC 6238 (appendix B), base32 encoded.
+var rfc6238Secret = totpEncoding.EncodeToString([]byte("12345678901234567890"))
+
+// rfc6238Vectors are the SHA1 test vectors of RFC 6238, truncated to the last 6 of their 8 digits.
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestTOTPCodeVectors(t *testing.T) {
+	key := []byte("12345678901234567890")
+	for _, vector := range rfc6238Vectors {
+		if code := totpCode(key, vector.unix/totpPeriod); code != vector.code {
+			t.Errorf("code at %d = %s, want %s", vector.unix, code, vector.code)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	for _, vector := range rfc6238Vectors {
+		at := time.Unix(vector.unix, 0)
+		step, ok := ValidateTOTP(rfc6238Secret, vector.code, at)
+		if !ok || step != vector.unix/totpPeriod {
+			t.Errorf("ValidateTOTP at %d = %d, %v, want step %d", vector.unix, step, ok, vector.unix/totpPeriod)
+		}
+	}
+
+	// a period of clock drift either way is allowed, not more.
+	at := time.Unix(1111111111, 0)
+	for _, drift := range []int64{-1, 1} {
+		if _, ok := ValidateTOTP(rfc6238Secret, "050471", at.Add(time.Duration(drift*totpPeriod)*time.Second)); !ok {
+			t.Errorf("code rejected %d period(s) off", drift)
+		}
+	}
+	for _, drift := range []int64{-2, 2} {
+		if _, ok := ValidateTOTP(rfc6238Secret, "050471", at.Add(time.Duration(drift*totpPeriod)*time.Second)); ok {
+			t.Errorf("code accepted %d periods off", drift)
+		}
+	}
+
+	for _, code := range []string{"", "05047", "0504710", "abcdef"} {
+		if _, ok := ValidateTOTP(rfc6238Secret, code, at); ok {
+			t.Errorf("malformed code %q accepted", code)
+		}
+	}
+	if _, ok := ValidateTOTP("not base32!", "050471", at); ok {
+		t.Error("code accepted for an invalid secret")
+	}
+}
+
+func TestRecoveryCode(t *testing.T) {
+	code, err := NewRecoveryCode()
+	if err != nil {
+		t.Fatalf("NewRecoveryCode: %v", err)
+	}
+	if len(code) != 19 || code[4] != '-' || code[9] != '-' || code[14] != '-' {
+		t.Errorf("recovery code %q not formatted as xxxx-xxxx-xxxx-xxxx", code)
+	}
+
+	// however it is typed back in, a code hashes the same.
+	normalized := NormalizeRecoveryCode(code)
+	for _, typed := range []string{code, " " + code + " ", normalized, strings.ToUpper(code)} {
+		if NormalizeRecoveryCode(typed) != normalized {
+			t.Errorf("NormalizeRecoveryCode(%q) = %q, want %q", typed, NormalizeRecoveryCode(typed), normalized)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package spotify
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-resty/resty/v2"
+	. "groove/pkgs/util"
+	"net/http"
+)
+
+// AuthorizeURL returns the url of the Spotify Authorization page, with the given query parameters.
+func (c *Client) AuthorizeURL(params Params) string {
+	return c.accountsURL + "/authorize?" + URLSearchParams(params)
+}
+
+// ExchangeCode exchanges an authorization code from the OAuth callback for access and refresh tokens.
+func (c *Client) ExchangeCode(ctx context.Context, code, redirectURI string) (*Tokens, error) {
+	return c.token(ctx, Form{
+		"grant_type":   "authorization_code",
+		"code":         code,
+		"redirect_uri": redirectURI,
+	})
+}
+
+// RefreshToken exchanges a refresh token for a new access token.
+// Spotify may or may not rotate the refresh token; if it doesn't, Tokens.RefreshToken is empty.
+func (c *Client) RefreshToken(ctx context.Context, refreshToken string) (*Tokens, error) {
+	return c.token(ctx, Form{
+		"grant_type":    "refresh_token",
+		"refresh_token": refreshToken,
+	})
+}
+
+// token requests tokens from the Accounts API, authenticating as the app.
+func (c *Client) token(ctx context.Context, form Form) (*Tokens, error) {
+	build := func() *resty.Request {
+		return c.http.R().
+			SetBasicAuth(c.clientID, c.clientSecret).
+			SetFormData(form)
+	}
+
+	// token requests share the app's bucket, as they are made on behalf of the app rather than a user.
+	// they are not idempotent: authorization codes are single-use, and refresh tokens may be rotated.
+	resp, err := c.send(ctx, c.clientID, build, http.MethodPost, c.accountsURL+"/api/token", false)
+	if err != nil {
+		return nil, err
+	}
+	if resp.IsError() {
+		return nil, accountsError(resp)
+	}
+
+	tokens := new(Tokens)
+	if err = json.Unmarshal(resp.Body(), tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// ClientCredentials requests an app access token through the client-credentials grant.
+// app tokens can only access the catalog (no user data) and come without a refresh token.
+func (c *Client) ClientCredentials(ctx context.Context) (*Tokens, error) {
+	return c.token(ctx, Form{
+		"grant_type": "client_credentials",
+	})
+}
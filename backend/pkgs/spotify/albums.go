@@ -0,0 +1,31 @@
+package spotify
+
+import (
+	"context"
+	"net/url"
+)
+
+type AlbumTracksRequest struct {
+	AlbumID string
+	Market  string
+	Limit   int
+	Offset  int
+}
+
+// GetAlbum returns the album with the given id.
+func (c *Client) GetAlbum(ctx context.Context, access, albumID, market string) (*Album, error) {
+	album := new(Album)
+	err := c.get(ctx, access, "/albums/"+url.PathEscape(albumID), query("market", market), album)
+	return album, err
+}
+
+// GetAlbumTracks returns a page of the tracks of an album.
+func (c *Client) GetAlbumTracks(ctx context.Context, access string, req AlbumTracksRequest) (*Paging[SimplifiedTrack], error) {
+	tracks := new(Paging[SimplifiedTrack])
+	err := c.get(ctx, access, "/albums/"+url.PathEscape(req.AlbumID)+"/tracks", query(
+		"market", req.Market,
+		"limit", itoa(req.Limit),
+		"offset", itoa(req.Offset),
+	), tracks)
+	return tracks, err
+}
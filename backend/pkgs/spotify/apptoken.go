@@ -0,0 +1,155 @@
+package spotify
+
+import (
+	"context"
+	"go.uber.org/fx"
+	"golang.org/x/sync/singleflight"
+	. "groove/pkgs/util"
+	"sync"
+	"time"
+)
+
+const (
+	// appTokenMargin is how long before expiration the app token is refreshed.
+	appTokenMargin = 5 * time.Minute
+	// appTokenExpiry is how long before expiration Get stops handing out the app token,
+	// so that a token is never used for a request it expires during.
+	appTokenExpiry = time.Minute
+	// appTokenRetry is how long to wait before retrying a failed background refresh.
+	appTokenRetry = 30 * time.Second
+	// appTokenTimeout bounds a refresh, which runs detached from the callers waiting on it.
+	appTokenTimeout = 30 * time.Second
+)
+
+// AppToken holds the app access token used for catalog requests of users not linked to Spotify.
+// the token is obtained through the client-credentials grant, cached, and refreshed in the background.
+// refreshes are deduplicated and made without holding mu, so a slow Accounts API only holds up the callers
+// that need a new token.
+type AppToken struct {
+	client *Client
+	group  singleflight.Group
+	// mu guards access and expiration.
+	mu         sync.Mutex
+	access     string
+	expiration time.Time
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+func NewAppToken(client *Client) *AppToken {
+	return &AppToken{
+		client: client,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+func ProvideAppToken(lc fx.Lifecycle, client *Client) *AppToken {
+	token := NewAppToken(client)
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go token.run()
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			close(token.stop)
+			<-token.done
+			return nil
+		},
+	})
+	return token
+}
+
+// Get returns a valid app access token, requesting a new one if the cached token expires within appTokenExpiry.
+func (t *AppToken) Get(ctx context.Context) (string, error) {
+	if access, ok := t.cached(appTokenExpiry); ok {
+		return access, nil
+	}
+
+	results := t.group.DoChan("app", func() (any, error) {
+		// the token may have been refreshed since the caller checked.
+		if access, ok := t.cached(appTokenExpiry); ok {
+			return access, nil
+		}
+		ctx, cancel := context.WithTimeout(WithoutCancel(ctx), appTokenTimeout)
+		defer cancel()
+		return t.refresh(ctx)
+	})
+
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case result := <-results:
+		if result.Err != nil {
+			return "", result.Err
+		}
+		return result.Val.(string), nil
+	}
+}
+
+// cached returns the cached token if it is valid for at least margin.
+func (t *AppToken) cached(margin time.Duration) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.access != "" && time.Now().Add(margin).Before(t.expiration) {
+		return t.access, true
+	}
+	return "", false
+}
+
+// refresh requests a new app token and caches it.
+func (t *AppToken) refresh(ctx context.Context) (string, error) {
+	tokens, err := t.client.ClientCredentials(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.access = tokens.AccessToken
+	t.expiration = time.Now().Add(time.Duration(tokens.ExpiresIn) * time.Second)
+	return t.access, nil
+}
+
+// until returns how long until the cached token expires.
+func (t *AppToken) until() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return time.Until(t.expiration)
+}
+
+// run refreshes the token shortly before it expires until stopped.
+func (t *AppToken) run() {
+	defer close(t.done)
+
+	for {
+		wait := t.until() - appTokenMargin
+		if wait <= 0 {
+			_, err, _ := t.group.Do("app", func() (any, error) {
+				ctx, cancel := context.WithTimeout(context.Background(), appTokenTimeout)
+				defer cancel()
+				return t.refresh(ctx)
+			})
+			if err != nil {
+				LogError("AppToken-Run", "refreshing app token", err)
+				wait = appTokenRetry
+			} else {
+				wait = t.until() - appTokenMargin
+			}
+		}
+
+		// guard against tokens that expire sooner than the margin.
+		if wait < appTokenRetry {
+			wait = appTokenRetry
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-t.stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
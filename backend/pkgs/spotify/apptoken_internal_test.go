@@ -0,0 +1,10 @@
+package spotify
+
+import "time"
+
+// Expire makes the cached app token expire at the given time, for the tests of Get.
+func (t *AppToken) Expire(expiration time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.expiration = expiration
+}
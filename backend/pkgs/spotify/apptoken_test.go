@@ -0,0 +1,61 @@
+package spotify_test
+
+import (
+	"context"
+	"groove/pkgs/spotify"
+	"groove/pkgs/spotify/spotifytest"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAppTokenRefreshesNearExpiry(t *testing.T) {
+	fake := spotifytest.NewServer()
+	defer fake.Close()
+	token := spotify.NewAppToken(spotify.New(fake.Config()))
+	ctx := context.Background()
+
+	first, err := token.Get(ctx)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if again, _ := token.Get(ctx); again != first {
+		t.Errorf("cached token not reused")
+	}
+
+	// a token expiring in the middle of the request is not handed out.
+	token.Expire(time.Now().Add(30 * time.Second))
+	second, err := token.Get(ctx)
+	if err != nil {
+		t.Fatalf("Get near expiry: %v", err)
+	}
+	if second == first {
+		t.Errorf("token expiring in 30s handed out")
+	}
+	if calls := fake.Calls(http.MethodPost, "/api/token"); calls != 2 {
+		t.Errorf("token requested %d times, want 2", calls)
+	}
+}
+
+func TestAppTokenSharesRefresh(t *testing.T) {
+	fake := spotifytest.NewServer()
+	defer fake.Close()
+	token := spotify.NewAppToken(spotify.New(fake.Config()))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := token.Get(context.Background()); err != nil {
+				t.Errorf("Get: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if calls := fake.Calls(http.MethodPost, "/api/token"); calls != 1 {
+		t.Errorf("token requested %d times by concurrent callers, want 1", calls)
+	}
+}
@@ -0,0 +1,56 @@
+package spotify
+
+import (
+	"context"
+	"net/url"
+)
+
+type RelatedArtists struct {
+	Artists []Artist `json:"artists"`
+}
+
+type TopTracks struct {
+	Tracks []Track `json:"tracks"`
+}
+
+type ArtistAlbumsRequest struct {
+	ArtistID string
+	// IncludeGroups is a comma-separated list of album, single, appears_on and compilation.
+	IncludeGroups string
+	Market        string
+	Limit         int
+	Offset        int
+}
+
+// GetArtist returns the artist with the given id.
+func (c *Client) GetArtist(ctx context.Context, access, artistID string) (*Artist, error) {
+	artist := new(Artist)
+	err := c.get(ctx, access, "/artists/"+url.PathEscape(artistID), nil, artist)
+	return artist, err
+}
+
+// GetRelatedArtists returns the artists related to the artist with the given id.
+func (c *Client) GetRelatedArtists(ctx context.Context, access, artistID string) (*RelatedArtists, error) {
+	related := new(RelatedArtists)
+	err := c.get(ctx, access, "/artists/"+url.PathEscape(artistID)+"/related-artists", nil, related)
+	return related, err
+}
+
+// GetArtistTopTracks returns the top tracks of the artist with the given id in a market.
+func (c *Client) GetArtistTopTracks(ctx context.Context, access, artistID, market string) (*TopTracks, error) {
+	top := new(TopTracks)
+	err := c.get(ctx, access, "/artists/"+url.PathEscape(artistID)+"/top-tracks", query("market", market), top)
+	return top, err
+}
+
+// GetArtistAlbums returns a page of the albums of an artist.
+func (c *Client) GetArtistAlbums(ctx context.Context, access string, req ArtistAlbumsRequest) (*Paging[SimplifiedAlbum], error) {
+	albums := new(Paging[SimplifiedAlbum])
+	err := c.get(ctx, access, "/artists/"+url.PathEscape(req.ArtistID)+"/albums", query(
+		"include_groups", req.IncludeGroups,
+		"market", req.Market,
+		"limit", itoa(req.Limit),
+		"offset", itoa(req.Offset),
+	), albums)
+	return albums, err
+}
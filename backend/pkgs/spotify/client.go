@@ -0,0 +1,235 @@
+package spotify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/go-resty/resty/v2"
+	"groove/pkgs/env"
+	. "groove/pkgs/util"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Client is a typed client for the Spotify Web API and Accounts API.
+// a single Client (and its underlying HTTP client) is shared by the whole server.
+type Client struct {
+	http         *resty.Client
+	apiURL       string
+	accountsURL  string
+	clientID     string
+	clientSecret string
+	throttle     *throttle
+	callers      *throttle
+	maxRetries   int
+	retryWait    time.Duration
+	maxRetryWait time.Duration
+}
+
+// Config configures a Client. Empty urls default to Spotify's production APIs.
+type Config struct {
+	// APIURL is the base url of the Web API, including the version (i.e. https://api.spotify.com/v1).
+	APIURL string
+	// AccountsURL is the root url of the Accounts service, serving /authorize and /api/token.
+	AccountsURL  string
+	ClientID     string
+	ClientSecret string
+	Timeout      time.Duration
+	// MaxRetries is the number of times a request is retried after a 429 or 5xx response.
+	MaxRetries int
+	// RetryWait is the base wait of the exponential backoff between retries.
+	RetryWait time.Duration
+	// MaxRetryWait caps a single wait; a longer Retry-After fails the request instead of holding it.
+	MaxRetryWait time.Duration
+	// RequestsPerSecond and Burst configure the throttle applied to each access token.
+	RequestsPerSecond float64
+	Burst             int
+	// SharedPerSecond and SharedBurst configure the allowance of each caller of a shared token.
+	SharedPerSecond float64
+	SharedBurst     int
+}
+
+// New creates a Client from the given Config.
+func New(config Config) *Client {
+	if config.APIURL == "" {
+		config.APIURL = SpotifyAPI
+	}
+	if config.AccountsURL == "" {
+		config.AccountsURL = SpotifyAccounts
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 15 * time.Second
+	}
+	if config.MaxRetries == 0 {
+		config.MaxRetries = 3
+	}
+	if config.RetryWait == 0 {
+		config.RetryWait = 500 * time.Millisecond
+	}
+	if config.MaxRetryWait == 0 {
+		config.MaxRetryWait = 10 * time.Second
+	}
+	if config.RequestsPerSecond == 0 {
+		config.RequestsPerSecond = 5
+	}
+	if config.Burst == 0 {
+		config.Burst = 20
+	}
+	if config.SharedPerSecond == 0 {
+		config.SharedPerSecond = 1
+	}
+	if config.SharedBurst == 0 {
+		config.SharedBurst = 10
+	}
+
+	return &Client{
+		http: resty.New().
+			SetTimeout(config.Timeout).
+			SetHeader("Accept", "application/json"),
+		apiURL:       config.APIURL,
+		accountsURL:  config.AccountsURL,
+		clientID:     config.ClientID,
+		clientSecret: config.ClientSecret,
+		throttle:     newThrottle(config.RequestsPerSecond, config.Burst),
+		callers:      newThrottle(config.SharedPerSecond, config.SharedBurst),
+		maxRetries:   config.MaxRetries,
+		retryWait:    config.RetryWait,
+		maxRetryWait: config.MaxRetryWait,
+	}
+}
+
+func ProvideClient(env *env.Env) *Client {
+	return New(Config{
+		APIURL:       env.SpotifyAPIURL,
+		AccountsURL:  env.SpotifyAccountsURL,
+		ClientID:     env.SpotifyClient,
+		ClientSecret: env.SpotifySecret,
+	})
+}
+
+// Error is returned when Spotify responds with a non-2xx status.
+type Error struct {
+	Status int
+	// Reason is the OAuth error code returned by the Accounts API (i.e. invalid_grant).
+	Reason  string
+	Message string
+	// RetryAfter is how long Spotify asked us to wait before trying again (429 and 503 only).
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	if e.Reason != "" {
+		return strconv.Itoa(e.Status) + ": " + e.Reason + ": " + e.Message
+	}
+	return strconv.Itoa(e.Status) + ": " + e.Message
+}
+
+// StatusOf returns the status of a Spotify error, or 0 if err is not one.
+func StatusOf(err error) int {
+	var spotifyErr *Error
+	if errors.As(err, &spotifyErr) {
+		return spotifyErr.Status
+	}
+	return 0
+}
+
+// request describes a single call to the Web API.
+type request struct {
+	method   string
+	endpoint string
+	access   string
+	query    Params
+	body     any
+	result   any
+	// idempotent marks a write that is safe to repeat, to retry it on 5xx like GETs; see retryable.
+	idempotent bool
+}
+
+// do sends the request to the Web API and decodes the response into request.result.
+func (c *Client) do(ctx context.Context, req *request) error {
+	build := func() *resty.Request {
+		r := c.http.R().
+			SetAuthToken(req.access).
+			SetQueryParams(req.query)
+		if req.body != nil {
+			r.SetHeader("Content-Type", "application/json").SetBody(req.body)
+		}
+		return r
+	}
+
+	idempotent := req.method == http.MethodGet || req.idempotent
+	resp, err := c.send(ctx, req.access, build, req.method, c.apiURL+req.endpoint, idempotent)
+	if err != nil {
+		return err
+	}
+	if resp.IsError() {
+		return apiError(resp)
+	}
+
+	if req.result == nil || len(resp.Body()) == 0 {
+		return nil
+	}
+	return json.Unmarshal(resp.Body(), req.result)
+}
+
+// get is shorthand for a GET request to the Web API.
+func (c *Client) get(ctx context.Context, access, endpoint string, query Params, result any) error {
+	return c.do(ctx, &request{
+		method:   http.MethodGet,
+		endpoint: endpoint,
+		access:   access,
+		query:    query,
+		result:   result,
+	})
+}
+
+// apiError builds an Error from a Web API error response.
+// the Web API wraps errors as {"error": {"status": 400, "message": "..."}}.
+func apiError(resp *resty.Response) error {
+	type Payload struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+
+	payload := new(Payload)
+	if json.Unmarshal(resp.Body(), payload) != nil || payload.Error.Message == "" {
+		return &Error{Status: resp.StatusCode(), Message: string(resp.Body()), RetryAfter: retryAfter(resp)}
+	}
+	return &Error{Status: resp.StatusCode(), Message: payload.Error.Message, RetryAfter: retryAfter(resp)}
+}
+
+// accountsError builds an Error from an Accounts API error response.
+// the Accounts API follows OAuth: {"error": "invalid_grant", "error_description": "..."}.
+func accountsError(resp *resty.Response) error {
+	type Payload struct {
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}
+
+	payload := new(Payload)
+	if json.Unmarshal(resp.Body(), payload) != nil || payload.Error == "" {
+		return &Error{Status: resp.StatusCode(), Message: string(resp.Body()), RetryAfter: retryAfter(resp)}
+	}
+	return &Error{Status: resp.StatusCode(), Reason: payload.Error, Message: payload.Description, RetryAfter: retryAfter(resp)}
+}
+
+// query builds query parameters from key-value pairs, leaving out empty values.
+func query(pairs ...string) Params {
+	params := Params{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i+1] != "" {
+			params[pairs[i]] = pairs[i+1]
+		}
+	}
+	return params
+}
+
+// itoa formats n for query, treating 0 as unset.
+func itoa(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
@@ -0,0 +1,103 @@
+package spotify
+
+import "context"
+
+// Paginator walks the items of a paged endpoint, fetching a page at a time as they are consumed.
+// it is not safe for concurrent use.
+type Paginator[T any] struct {
+	fetch    func(ctx context.Context, offset, limit int) (*Paging[T], error)
+	pageSize int
+	offset   int
+	total    int
+	// remaining is the number of items left to return; negative means no limit.
+	remaining int
+	done      bool
+}
+
+func newPaginator[T any](pageSize, offset int, fetch func(ctx context.Context, offset, limit int) (*Paging[T], error)) *Paginator[T] {
+	return &Paginator[T]{fetch: fetch, pageSize: pageSize, offset: offset, total: -1, remaining: -1}
+}
+
+// Limit stops the paginator once it returned n more items; pages are shrunk so no extra item is fetched.
+func (p *Paginator[T]) Limit(n int) *Paginator[T] {
+	p.remaining = n
+	if n == 0 {
+		p.done = true
+	}
+	return p
+}
+
+// Next returns the next page of items, or nil once Done.
+func (p *Paginator[T]) Next(ctx context.Context) ([]T, error) {
+	if p.done {
+		return nil, nil
+	}
+
+	limit := p.pageSize
+	if p.remaining >= 0 && p.remaining < limit {
+		limit = p.remaining
+	}
+
+	page, err := p.fetch(ctx, p.offset, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	p.total = page.Total
+	p.offset += len(page.Items)
+	if p.remaining >= 0 {
+		p.remaining -= len(page.Items)
+	}
+	if page.Next == nil || len(page.Items) == 0 || p.offset >= page.Total || p.remaining == 0 {
+		p.done = true
+	}
+	return page.Items, nil
+}
+
+// All returns every remaining item.
+func (p *Paginator[T]) All(ctx context.Context) ([]T, error) {
+	var items []T
+	for !p.done {
+		page, err := p.Next(ctx)
+		if err != nil {
+			return items, err
+		}
+		items = append(items, page...)
+	}
+	return items, nil
+}
+
+// Done reports whether every item was returned.
+func (p *Paginator[T]) Done() bool {
+	return p.done
+}
+
+// Offset is the offset of the next item; resuming from it continues where the paginator stopped.
+func (p *Paginator[T]) Offset() int {
+	return p.offset
+}
+
+// Total is the total number of items of the endpoint, or -1 before the first page.
+func (p *Paginator[T]) Total() int {
+	return p.total
+}
+
+// PaginatePlaylistTracks returns a paginator over the tracks of a playlist, starting at offset.
+func (c *Client) PaginatePlaylistTracks(access, playlistID, market string, offset int) *Paginator[PlaylistTrack] {
+	return newPaginator(100, offset, func(ctx context.Context, offset, limit int) (*Paging[PlaylistTrack], error) {
+		return c.GetPlaylistTracks(ctx, access, PlaylistTracksRequest{
+			PlaylistID: playlistID,
+			Market:     market,
+			Limit:      limit,
+			Offset:     offset,
+		})
+	})
+}
+
+// PaginateMyPlaylists returns a paginator over the playlists owned or followed by the owner of the access token,
+// starting at offset.
+func (c *Client) PaginateMyPlaylists(access string, offset int) *Paginator[SimplifiedPlaylist] {
+	return newPaginator(50, offset, func(ctx context.Context, offset, limit int) (*Paging[SimplifiedPlaylist], error) {
+		return c.GetMyPlaylists(ctx, access, limit, offset)
+	})
+}
@@ -0,0 +1,227 @@
+package spotify
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+type PlaylistTracksRequest struct {
+	PlaylistID string
+	Market     string
+	Limit      int
+	Offset     int
+}
+
+// MaxItemsPerRequest is the most items a playlist can be added or removed in a single request.
+const MaxItemsPerRequest = 100
+
+type AddItemsRequest struct {
+	PlaylistID string
+	// URIs are spotify:track:{id} or spotify:episode:{id} uris.
+	URIs []string
+	// Position is where the items are inserted; nil appends them.
+	Position *int
+}
+
+type RemoveItemsRequest struct {
+	PlaylistID string
+	// URIs are removed everywhere they occur in the playlist.
+	URIs []string
+	// Items are removed only at their positions.
+	Items []PositionedItem
+	// SnapshotID is the version of the playlist the positions refer to; empty means the latest.
+	SnapshotID string
+}
+
+// PositionedItem is an item of a playlist at the given positions.
+type PositionedItem struct {
+	URI       string `json:"uri"`
+	Positions []int  `json:"positions"`
+}
+
+type ReorderItemsRequest struct {
+	PlaylistID string
+	// RangeStart is the position of the first item to move.
+	RangeStart int
+	// RangeLength is the number of items to move, starting at RangeStart.
+	RangeLength int
+	// InsertBefore is the position the items are moved before; the length of the playlist moves them to the end.
+	InsertBefore int
+	// SnapshotID is the version of the playlist the positions refer to; empty means the latest.
+	SnapshotID string
+}
+
+type CreatePlaylistRequest struct {
+	// UserID is the Spotify user the playlist is created for; it must be the owner of the access token.
+	UserID        string
+	Name          string
+	Description   string
+	Public        bool
+	Collaborative bool
+}
+
+// PlaylistDetails are the details of a playlist to change; nil fields are left unchanged.
+// (collaborative playlists must be private)
+type PlaylistDetails struct {
+	Name          *string `json:"name,omitempty"`
+	Description   *string `json:"description,omitempty"`
+	Public        *bool   `json:"public,omitempty"`
+	Collaborative *bool   `json:"collaborative,omitempty"`
+}
+
+// GetMyPlaylists returns a page of the playlists owned or followed by the owner of the access token.
+func (c *Client) GetMyPlaylists(ctx context.Context, access string, limit, offset int) (*Paging[SimplifiedPlaylist], error) {
+	playlists := new(Paging[SimplifiedPlaylist])
+	err := c.get(ctx, access, "/me/playlists", query("limit", itoa(limit), "offset", itoa(offset)), playlists)
+	return playlists, err
+}
+
+// GetPlaylist returns the playlist with the given id along with its first 100 tracks.
+func (c *Client) GetPlaylist(ctx context.Context, access, playlistID, market string) (*Playlist, error) {
+	playlist := new(Playlist)
+	err := c.get(ctx, access, "/playlists/"+url.PathEscape(playlistID), query("market", market), playlist)
+	return playlist, err
+}
+
+// GetPlaylistSnapshot returns the current snapshot id of a playlist, without its tracks.
+func (c *Client) GetPlaylistSnapshot(ctx context.Context, access, playlistID string) (*Snapshot, error) {
+	snapshot := new(Snapshot)
+	err := c.get(ctx, access, "/playlists/"+url.PathEscape(playlistID), query("fields", "snapshot_id"), snapshot)
+	return snapshot, err
+}
+
+// GetPlaylistTracks returns a page of the tracks of a playlist.
+func (c *Client) GetPlaylistTracks(ctx context.Context, access string, req PlaylistTracksRequest) (*Paging[PlaylistTrack], error) {
+	tracks := new(Paging[PlaylistTrack])
+	err := c.get(ctx, access, "/playlists/"+url.PathEscape(req.PlaylistID)+"/tracks", query(
+		"market", req.Market,
+		"limit", itoa(req.Limit),
+		"offset", itoa(req.Offset),
+	), tracks)
+	return tracks, err
+}
+
+// AddPlaylistItems adds at most MaxItemsPerRequest items to a playlist.
+func (c *Client) AddPlaylistItems(ctx context.Context, access string, req AddItemsRequest) (*Snapshot, error) {
+	type Body struct {
+		URIs     []string `json:"uris"`
+		Position *int     `json:"position,omitempty"`
+	}
+
+	snapshot := new(Snapshot)
+	err := c.do(ctx, &request{
+		method:   http.MethodPost,
+		endpoint: "/playlists/" + url.PathEscape(req.PlaylistID) + "/tracks",
+		access:   access,
+		body:     Body{URIs: req.URIs, Position: req.Position},
+		result:   snapshot,
+	})
+	return snapshot, err
+}
+
+// RemovePlaylistItems removes at most MaxItemsPerRequest items from a playlist,
+// either everywhere they occur (URIs) or at specific positions (Items).
+// it is not retried after a 5xx, as the items may already be gone and their positions taken by others.
+func (c *Client) RemovePlaylistItems(ctx context.Context, access string, req RemoveItemsRequest) (*Snapshot, error) {
+	type Item struct {
+		URI       string `json:"uri"`
+		Positions []int  `json:"positions,omitempty"`
+	}
+	type Body struct {
+		Tracks     []Item `json:"tracks"`
+		SnapshotID string `json:"snapshot_id,omitempty"`
+	}
+
+	body := Body{Tracks: make([]Item, 0, len(req.Items)+len(req.URIs)), SnapshotID: req.SnapshotID}
+	for _, item := range req.Items {
+		body.Tracks = append(body.Tracks, Item{URI: item.URI, Positions: item.Positions})
+	}
+	for _, uri := range req.URIs {
+		body.Tracks = append(body.Tracks, Item{URI: uri})
+	}
+
+	snapshot := new(Snapshot)
+	err := c.do(ctx, &request{
+		method:   http.MethodDelete,
+		endpoint: "/playlists/" + url.PathEscape(req.PlaylistID) + "/tracks",
+		access:   access,
+		body:     body,
+		result:   snapshot,
+	})
+	return snapshot, err
+}
+
+// ReorderPlaylistItems moves a range of items of a playlist to another position.
+// it is not retried after a 5xx, as the items may already be moved; repeating it would move them again.
+func (c *Client) ReorderPlaylistItems(ctx context.Context, access string, req ReorderItemsRequest) (*Snapshot, error) {
+	type Body struct {
+		RangeStart   int    `json:"range_start"`
+		InsertBefore int    `json:"insert_before"`
+		RangeLength  int    `json:"range_length"`
+		SnapshotID   string `json:"snapshot_id,omitempty"`
+	}
+
+	snapshot := new(Snapshot)
+	err := c.do(ctx, &request{
+		method:   http.MethodPut,
+		endpoint: "/playlists/" + url.PathEscape(req.PlaylistID) + "/tracks",
+		access:   access,
+		body: Body{
+			RangeStart:   req.RangeStart,
+			InsertBefore: req.InsertBefore,
+			RangeLength:  req.RangeLength,
+			SnapshotID:   req.SnapshotID,
+		},
+		result: snapshot,
+	})
+	return snapshot, err
+}
+
+// CreatePlaylist creates a playlist for the owner of the access token.
+func (c *Client) CreatePlaylist(ctx context.Context, access string, req CreatePlaylistRequest) (*Playlist, error) {
+	type Body struct {
+		Name          string `json:"name"`
+		Description   string `json:"description,omitempty"`
+		Public        bool   `json:"public"`
+		Collaborative bool   `json:"collaborative"`
+	}
+
+	playlist := new(Playlist)
+	err := c.do(ctx, &request{
+		method:   http.MethodPost,
+		endpoint: "/users/" + url.PathEscape(req.UserID) + "/playlists",
+		access:   access,
+		body: Body{
+			Name:          req.Name,
+			Description:   req.Description,
+			Public:        req.Public,
+			Collaborative: req.Collaborative,
+		},
+		result: playlist,
+	})
+	return playlist, err
+}
+
+// ChangePlaylistDetails changes the name, description, public or collaborative flags of a playlist.
+// the details are set rather than changed relative to the current ones, so the request is safe to repeat.
+func (c *Client) ChangePlaylistDetails(ctx context.Context, access, playlistID string, details PlaylistDetails) error {
+	return c.do(ctx, &request{
+		method:     http.MethodPut,
+		endpoint:   "/playlists/" + url.PathEscape(playlistID),
+		access:     access,
+		body:       details,
+		idempotent: true,
+	})
+}
+
+// UnfollowPlaylist removes a playlist from the library of the owner of the access token.
+// Spotify has no way to delete a playlist; unfollowing one you own is what deleting it does in the Spotify apps.
+func (c *Client) UnfollowPlaylist(ctx context.Context, access, playlistID string) error {
+	return c.do(ctx, &request{
+		method:     http.MethodDelete,
+		endpoint:   "/playlists/" + url.PathEscape(playlistID) + "/followers",
+		access:     access,
+		idempotent: true,
+	})
+}
@@ -0,0 +1,168 @@
+package spotify
+
+import (
+	"context"
+	"github.com/go-resty/resty/v2"
+	"golang.org/x/time/rate"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+/*
+ * Spotify rate limits per app over a rolling 30-second window and answers with 429 and a Retry-After header
+ * once the limit is hit. Requests are throttled per access token so a single busy user (or the shared default
+ * token) cannot drain the app's budget, and 429/5xx responses are retried with jittered exponential backoff.
+ * Writes are only retried on 429: Spotify may answer 5xx after committing a write, so retrying one could add tracks
+ * twice, create a second playlist, reuse a single-use authorization code, or move or remove playlist items again
+ * (positions are only checked against the snapshot the caller gave, which the first attempt already replaced).
+ * Only GETs, and requests marked idempotent, are retried on 5xx.
+ */
+
+// throttle holds a token bucket per access token.
+type throttle struct {
+	mu       sync.Mutex
+	limit    rate.Limit
+	burst    int
+	limiters map[string]*limiter
+}
+
+type limiter struct {
+	*rate.Limiter
+	lastUsed time.Time
+}
+
+func newThrottle(perSecond float64, burst int) *throttle {
+	return &throttle{
+		limit:    rate.Limit(perSecond),
+		burst:    burst,
+		limiters: map[string]*limiter{},
+	}
+}
+
+// wait blocks until the bucket of key allows another request, or ctx is done.
+func (t *throttle) wait(ctx context.Context, key string) error {
+	return t.get(key).Wait(ctx)
+}
+
+// allow takes a token from the bucket of key without blocking.
+// if the bucket is empty, it returns false and how long until a token is available.
+func (t *throttle) allow(key string) (bool, time.Duration) {
+	reservation := t.get(key).Reserve()
+	if delay := reservation.Delay(); delay > 0 {
+		reservation.Cancel()
+		return false, delay
+	}
+	return true, 0
+}
+
+// get returns the limiter of key, creating it if needed.
+func (t *throttle) get(key string) *limiter {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	l, ok := t.limiters[key]
+	if !ok {
+		t.sweep(now)
+		l = &limiter{Limiter: rate.NewLimiter(t.limit, t.burst)}
+		t.limiters[key] = l
+	}
+	l.lastUsed = now
+	return l
+}
+
+// AllowShared reports whether the caller identified by key may make another request on a token it shares
+// with other callers (i.e. the default token), so one busy caller cannot drain that token's bucket.
+// if not, it also returns how long the caller should wait.
+func (c *Client) AllowShared(key string) (bool, time.Duration) {
+	return c.callers.allow(key)
+}
+
+// sweep forgets limiters of tokens that haven't been used for a while (access tokens expire after an hour).
+// must be called with t.mu held.
+func (t *throttle) sweep(now time.Time) {
+	if len(t.limiters) < 1024 {
+		return
+	}
+	for key, l := range t.limiters {
+		if now.Sub(l.lastUsed) > time.Hour {
+			delete(t.limiters, key)
+		}
+	}
+}
+
+// send executes the request built by build, throttled by key and retried on 429 and, if idempotent, 5xx responses
+// (see retryable). build is called for every attempt, as a resty.Request cannot be reused once executed.
+// once retries are exhausted, the last (error) response is returned for the caller to handle.
+func (c *Client) send(ctx context.Context, key string, build func() *resty.Request, method, url string, idempotent bool) (*resty.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if err := c.throttle.wait(ctx, key); err != nil {
+			return nil, err
+		}
+
+		resp, err := build().SetContext(ctx).Execute(method, url)
+		if err != nil {
+			return nil, err
+		}
+		if !retryable(idempotent, resp.StatusCode()) || attempt >= c.maxRetries {
+			return resp, nil
+		}
+
+		wait := c.backoff(resp, attempt)
+		if wait > c.maxRetryWait {
+			// Spotify asked for a longer break than we are willing to hold the request for.
+			return resp, nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns how long to wait before retrying after the given attempt (starting at 0).
+// Retry-After is honored when present; otherwise the wait is exponential with jitter.
+func (c *Client) backoff(resp *resty.Response, attempt int) time.Duration {
+	if wait := retryAfter(resp); wait > 0 {
+		return wait
+	}
+
+	ceiling := c.retryWait << attempt
+	if ceiling > c.maxRetryWait || ceiling <= 0 {
+		ceiling = c.maxRetryWait
+	}
+	// "equal jitter": at least half the ceiling so retries still back off, the rest random to spread them out.
+	half := ceiling / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// retryable reports whether a response with the given status is worth retrying.
+// 429s are never processed, so always retried; 5xx only for idempotent requests, as the write may have happened.
+func retryable(idempotent bool, status int) bool {
+	if status == http.StatusTooManyRequests {
+		return true
+	}
+	return status >= http.StatusInternalServerError && idempotent
+}
+
+// retryAfter parses the Retry-After header, given either in seconds or as an HTTP date.
+func retryAfter(resp *resty.Response) time.Duration {
+	header := resp.Header().Get("Retry-After")
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		return time.Until(date)
+	}
+	return 0
+}
@@ -0,0 +1,104 @@
+package spotify_test
+
+import (
+	"context"
+	"groove/pkgs/spotify"
+	"groove/pkgs/spotify/spotifytest"
+	"net/http"
+	"testing"
+)
+
+func trackIDs(t *testing.T, client *spotify.Client, access, playlistID string) []string {
+	t.Helper()
+
+	tracks, err := client.GetPlaylistTracks(context.Background(), access, spotify.PlaylistTracksRequest{PlaylistID: playlistID})
+	if err != nil {
+		t.Fatalf("getting playlist tracks: %v", err)
+	}
+	ids := make([]string, len(tracks.Items))
+	for i, item := range tracks.Items {
+		ids[i] = item.Track.ID
+	}
+	return ids
+}
+
+func TestRetryGetOnServerError(t *testing.T) {
+	fake := spotifytest.NewServer()
+	defer fake.Close()
+	client := spotify.New(fake.Config())
+	access := fake.Link(spotifytest.UserID).AccessToken
+
+	fake.Fail(spotifytest.Fault{Method: http.MethodGet, Path: "/v1/playlists/", Status: http.StatusBadGateway, Times: 1})
+	if _, err := client.GetPlaylist(context.Background(), access, "3RoadTrip000000000001", ""); err != nil {
+		t.Fatalf("GET after a transient 502: %v", err)
+	}
+	if calls := fake.Calls(http.MethodGet, "/v1/playlists/3RoadTrip000000000001"); calls != 2 {
+		t.Errorf("GET sent %d times, want 2", calls)
+	}
+}
+
+func TestNoRetryReorderOnServerError(t *testing.T) {
+	fake := spotifytest.NewServer()
+	defer fake.Close()
+	client := spotify.New(fake.Config())
+	access := fake.Link(spotifytest.UserID).AccessToken
+	const playlistID = "3RoadTrip000000000001"
+	path := "/v1/playlists/" + playlistID + "/tracks"
+
+	// the first item is moved to the end, then Spotify fails after applying it.
+	fake.Fail(spotifytest.Fault{Method: http.MethodPut, Path: path, Status: http.StatusBadGateway, Times: 1, Applied: true})
+	_, err := client.ReorderPlaylistItems(context.Background(), access, spotify.ReorderItemsRequest{
+		PlaylistID:   playlistID,
+		RangeStart:   0,
+		RangeLength:  1,
+		InsertBefore: 3,
+	})
+	if spotify.StatusOf(err) != http.StatusBadGateway {
+		t.Fatalf("reorder error = %v, want 502", err)
+	}
+	if calls := fake.Calls(http.MethodPut, path); calls != 1 {
+		t.Errorf("reorder sent %d times, want 1", calls)
+	}
+
+	want := []string{"7HarborLights00000004", "7GlassGarden000000006", "7NeonAvenue0000000001"}
+	got := trackIDs(t, client, access, playlistID)
+	if len(got) != len(want) {
+		t.Fatalf("tracks = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("tracks = %v, want %v (moved once)", got, want)
+		}
+	}
+}
+
+func TestNoRetryPositionalRemoveOnServerError(t *testing.T) {
+	fake := spotifytest.NewServer()
+	defer fake.Close()
+	client := spotify.New(fake.Config())
+	access := fake.Link(spotifytest.UserID).AccessToken
+	const playlistID = "3RoadTrip000000000001"
+	path := "/v1/playlists/" + playlistID + "/tracks"
+
+	snapshot, err := client.GetPlaylistSnapshot(context.Background(), access, playlistID)
+	if err != nil {
+		t.Fatalf("getting snapshot: %v", err)
+	}
+
+	// the first item is removed, then Spotify fails after applying it.
+	fake.Fail(spotifytest.Fault{Method: http.MethodDelete, Path: path, Status: http.StatusServiceUnavailable, Times: 1, Applied: true})
+	_, err = client.RemovePlaylistItems(context.Background(), access, spotify.RemoveItemsRequest{
+		PlaylistID: playlistID,
+		Items:      []spotify.PositionedItem{{URI: "spotify:track:7NeonAvenue0000000001", Positions: []int{0}}},
+		SnapshotID: snapshot.SnapshotID,
+	})
+	if spotify.StatusOf(err) != http.StatusServiceUnavailable {
+		t.Fatalf("remove error = %v, want 503", err)
+	}
+	if calls := fake.Calls(http.MethodDelete, path); calls != 1 {
+		t.Errorf("remove sent %d times, want 1", calls)
+	}
+	if got := trackIDs(t, client, access, playlistID); len(got) != 2 {
+		t.Errorf("tracks = %v, want 2 left (removed once)", got)
+	}
+}
@@ -0,0 +1,27 @@
+package spotify
+
+import (
+	"context"
+)
+
+type SearchRequest struct {
+	Query string
+	// Types is a comma-separated list of album, artist, playlist and track.
+	Types  string
+	Market string
+	Limit  int
+	Offset int
+}
+
+// Search searches the Spotify catalog.
+func (c *Client) Search(ctx context.Context, access string, req SearchRequest) (*SearchResult, error) {
+	result := new(SearchResult)
+	err := c.get(ctx, access, "/search", query(
+		"q", req.Query,
+		"type", req.Types,
+		"market", req.Market,
+		"limit", itoa(req.Limit),
+		"offset", itoa(req.Offset),
+	), result)
+	return result, err
+}
@@ -0,0 +1,890 @@
+package spotifytest
+
+import (
+	"encoding/json"
+	"groove/pkgs/spotify"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// load indexes the fixtures, resolving the objects they reference by id.
+func (s *Server) load(fixtures *Fixtures) {
+	for i := range fixtures.Users {
+		user := &fixtures.Users[i]
+		s.link(&user.PublicUser.ExternalURLs, &user.Href, &user.Type, &user.URI, "user", user.ID)
+		s.users[user.ID] = user
+	}
+
+	for i := range fixtures.Artists {
+		artist := &fixtures.Artists[i]
+		s.linkArtist(&artist.SimplifiedArtist)
+		s.artists[artist.ID] = artist
+		s.order.artists = append(s.order.artists, artist.ID)
+	}
+
+	for i := range fixtures.Albums {
+		album := &fixtures.Albums[i]
+		s.linkAlbum(&album.SimplifiedAlbum)
+		s.albums[album.ID] = album
+		s.order.albums = append(s.order.albums, album.ID)
+	}
+
+	for i := range fixtures.Tracks {
+		track := &fixtures.Tracks[i]
+		s.link(&track.ExternalURLs, &track.Href, &track.Type, &track.URI, "track", track.ID)
+		track.Artists = s.simplifiedArtists(track.Artists)
+		track.IsPlayable = true
+		if album, ok := s.albums[track.Album.ID]; ok {
+			album.TotalTracks++
+		}
+		s.tracks[track.ID] = track
+		s.order.tracks = append(s.order.tracks, track.ID)
+	}
+	// albums are resolved once every track is counted.
+	for _, track := range s.tracks {
+		if album, ok := s.albums[track.Album.ID]; ok {
+			track.Album = album.SimplifiedAlbum
+		}
+	}
+
+	for _, fixture := range fixtures.Playlists {
+		p := &playlist{SimplifiedPlaylist: fixture.SimplifiedPlaylist, followers: map[string]bool{}}
+		s.link(&p.ExternalURLs, &p.Href, &p.Type, &p.URI, "playlist", p.ID)
+		p.Owner = s.publicUser(p.Owner.ID)
+		p.SnapshotID = s.id("snapshot")
+		p.followers[p.Owner.ID] = true
+		for _, userID := range fixture.FollowedBy {
+			p.followers[userID] = true
+		}
+		for _, trackID := range fixture.Items {
+			p.items = append(p.items, s.playlistTrack(trackID, p.Owner))
+		}
+		s.playlists[p.ID] = p
+		s.order.playlists = append(s.order.playlists, p.ID)
+	}
+}
+
+// link fills in the href, type, uri and external url of an object.
+func (s *Server) link(external *spotify.ExternalURLs, href, kind, uri *string, objectType, id string) {
+	*href = s.APIURL() + "/" + objectType + "s/" + id
+	*kind = objectType
+	*uri = "spotify:" + objectType + ":" + id
+	external.Spotify = "https://open.spotify.com/" + objectType + "/" + id
+}
+
+func (s *Server) linkArtist(artist *spotify.SimplifiedArtist) {
+	s.link(&artist.ExternalURLs, &artist.Href, &artist.Type, &artist.URI, "artist", artist.ID)
+}
+
+func (s *Server) linkAlbum(album *spotify.SimplifiedAlbum) {
+	s.link(&album.ExternalURLs, &album.Href, &album.Type, &album.URI, "album", album.ID)
+	album.Artists = s.simplifiedArtists(album.Artists)
+	album.IsPlayable = true
+}
+
+// simplifiedArtists resolves artists referenced by id.
+func (s *Server) simplifiedArtists(refs []spotify.SimplifiedArtist) []spotify.SimplifiedArtist {
+	artists := make([]spotify.SimplifiedArtist, len(refs))
+	for i, ref := range refs {
+		if artist, ok := s.artists[ref.ID]; ok {
+			artists[i] = artist.SimplifiedArtist
+		} else {
+			artists[i] = ref
+			s.linkArtist(&artists[i])
+		}
+	}
+	return artists
+}
+
+func (s *Server) publicUser(userID string) spotify.PublicUser {
+	if user, ok := s.users[userID]; ok {
+		return user.PublicUser
+	}
+	user := spotify.PublicUser{ID: userID}
+	s.link(&user.ExternalURLs, &user.Href, &user.Type, &user.URI, "user", userID)
+	return user
+}
+
+func (s *Server) playlistTrack(trackID string, addedBy spotify.PublicUser) spotify.PlaylistTrack {
+	item := spotify.PlaylistTrack{
+		AddedAt: time.Now().UTC().Format(time.RFC3339),
+		AddedBy: addedBy,
+	}
+	if track, ok := s.tracks[trackID]; ok {
+		copied := *track
+		item.Track = &copied
+	}
+	return item
+}
+
+// api routes Web API requests.
+func (s *Server) api(w http.ResponseWriter, r *http.Request) {
+	grant, ok := s.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	segments := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/"), "/"), "/")
+	route := func(method string, pattern ...string) bool {
+		if len(segments) != len(pattern) {
+			return false
+		}
+		for i, part := range pattern {
+			if part != "*" && part != segments[i] {
+				return false
+			}
+		}
+		if r.Method != method {
+			return false
+		}
+		return true
+	}
+
+	switch {
+	case route(http.MethodGet, "artists", "*"):
+		s.getArtist(w, segments[1])
+	case route(http.MethodGet, "artists", "*", "related-artists"):
+		s.getRelatedArtists(w, segments[1])
+	case route(http.MethodGet, "artists", "*", "top-tracks"):
+		s.getArtistTopTracks(w, segments[1])
+	case route(http.MethodGet, "artists", "*", "albums"):
+		s.getArtistAlbums(w, r, segments[1])
+	case route(http.MethodGet, "albums", "*"):
+		s.getAlbum(w, segments[1])
+	case route(http.MethodGet, "albums", "*", "tracks"):
+		s.getAlbumTracks(w, r, segments[1])
+	case route(http.MethodGet, "tracks", "*"):
+		s.getTrack(w, segments[1])
+	case route(http.MethodGet, "search"):
+		s.search(w, r)
+	case route(http.MethodGet, "me"):
+		s.getMe(w, grant)
+	case route(http.MethodGet, "me", "playlists"):
+		s.getMyPlaylists(w, r, grant)
+	case route(http.MethodPost, "users", "*", "playlists"):
+		s.createPlaylist(w, r, grant, segments[1])
+	case route(http.MethodGet, "playlists", "*"):
+		s.getPlaylist(w, grant, segments[1])
+	case route(http.MethodPut, "playlists", "*"):
+		s.changePlaylistDetails(w, r, grant, segments[1])
+	case route(http.MethodDelete, "playlists", "*", "followers"):
+		s.unfollowPlaylist(w, grant, segments[1])
+	case route(http.MethodGet, "playlists", "*", "tracks"):
+		s.getPlaylistTracks(w, r, grant, segments[1])
+	case route(http.MethodPost, "playlists", "*", "tracks"):
+		s.addPlaylistItems(w, r, grant, segments[1])
+	case route(http.MethodPut, "playlists", "*", "tracks"):
+		s.reorderPlaylistItems(w, r, grant, segments[1])
+	case route(http.MethodDelete, "playlists", "*", "tracks"):
+		s.removePlaylistItems(w, r, grant, segments[1])
+	default:
+		apiError(w, http.StatusNotFound, "Service not found")
+	}
+}
+
+func (s *Server) getArtist(w http.ResponseWriter, id string) {
+	artist, ok := s.artists[id]
+	if !ok {
+		apiError(w, http.StatusNotFound, "Resource not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, artist)
+}
+
+func (s *Server) getRelatedArtists(w http.ResponseWriter, id string) {
+	if _, ok := s.artists[id]; !ok {
+		apiError(w, http.StatusNotFound, "Resource not found")
+		return
+	}
+
+	related := spotify.RelatedArtists{Artists: []spotify.Artist{}}
+	for _, relatedID := range s.related[id] {
+		if artist, ok := s.artists[relatedID]; ok {
+			related.Artists = append(related.Artists, *artist)
+		}
+	}
+	writeJSON(w, http.StatusOK, related)
+}
+
+func (s *Server) getArtistTopTracks(w http.ResponseWriter, id string) {
+	if _, ok := s.artists[id]; !ok {
+		apiError(w, http.StatusNotFound, "Resource not found")
+		return
+	}
+
+	top := spotify.TopTracks{Tracks: []spotify.Track{}}
+	for _, trackID := range s.order.tracks {
+		track := s.tracks[trackID]
+		if hasArtist(track.Artists, id) {
+			top.Tracks = append(top.Tracks, *track)
+		}
+	}
+	sort.SliceStable(top.Tracks, func(i, j int) bool {
+		return top.Tracks[i].Popularity > top.Tracks[j].Popularity
+	})
+	if len(top.Tracks) > 10 {
+		top.Tracks = top.Tracks[:10]
+	}
+	writeJSON(w, http.StatusOK, top)
+}
+
+func (s *Server) getArtistAlbums(w http.ResponseWriter, r *http.Request, id string) {
+	if _, ok := s.artists[id]; !ok {
+		apiError(w, http.StatusNotFound, "Resource not found")
+		return
+	}
+
+	var albums []spotify.SimplifiedAlbum
+	for _, albumID := range s.order.albums {
+		album := s.albums[albumID]
+		if hasArtist(album.Artists, id) {
+			albums = append(albums, album.SimplifiedAlbum)
+		}
+	}
+	writePage(w, r, s.URL+r.URL.Path, albums, 20, 50)
+}
+
+func (s *Server) getAlbum(w http.ResponseWriter, id string) {
+	album, ok := s.albums[id]
+	if !ok {
+		apiError(w, http.StatusNotFound, "Resource not found")
+		return
+	}
+
+	full := *album
+	tracks := s.albumTracks(id)
+	full.Tracks = page(s.APIURL()+"/albums/"+id+"/tracks", tracks, 50, 0)
+	writeJSON(w, http.StatusOK, full)
+}
+
+func (s *Server) getAlbumTracks(w http.ResponseWriter, r *http.Request, id string) {
+	if _, ok := s.albums[id]; !ok {
+		apiError(w, http.StatusNotFound, "Resource not found")
+		return
+	}
+	writePage(w, r, s.URL+r.URL.Path, s.albumTracks(id), 20, 50)
+}
+
+func (s *Server) albumTracks(albumID string) []spotify.SimplifiedTrack {
+	var tracks []spotify.SimplifiedTrack
+	for _, trackID := range s.order.tracks {
+		if track := s.tracks[trackID]; track.Album.ID == albumID {
+			tracks = append(tracks, track.SimplifiedTrack)
+		}
+	}
+	sort.SliceStable(tracks, func(i, j int) bool {
+		if tracks[i].DiscNumber != tracks[j].DiscNumber {
+			return tracks[i].DiscNumber < tracks[j].DiscNumber
+		}
+		return tracks[i].TrackNumber < tracks[j].TrackNumber
+	})
+	return tracks
+}
+
+func (s *Server) getTrack(w http.ResponseWriter, id string) {
+	track, ok := s.tracks[id]
+	if !ok {
+		apiError(w, http.StatusNotFound, "Resource not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, track)
+}
+
+// search matches every word of q against names (and a track's artists and album), case-insensitively.
+// the artist:, album:, track: and isrc: field filters are supported, with double-quoted values.
+func (s *Server) search(w http.ResponseWriter, r *http.Request) {
+	q, types := r.URL.Query().Get("q"), r.URL.Query().Get("type")
+	if q == "" {
+		apiError(w, http.StatusBadRequest, "No search query")
+		return
+	}
+	if types == "" {
+		apiError(w, http.StatusBadRequest, "Missing parameter type")
+		return
+	}
+	limit, offset, ok := pageParams(w, r, 20, 50)
+	if !ok {
+		return
+	}
+	query := parseQuery(q)
+
+	result := spotify.SearchResult{}
+	for _, kind := range strings.Split(types, ",") {
+		href := s.APIURL() + "/search?" + url.Values{"q": {q}, "type": {kind}}.Encode()
+		switch kind {
+		case "artist":
+			var artists []spotify.Artist
+			for _, id := range s.order.artists {
+				artist := s.artists[id]
+				if query.matches(artist.Name, map[string]string{"artist": artist.Name}) {
+					artists = append(artists, *artist)
+				}
+			}
+			result.Artists = pageRef(href, artists, limit, offset)
+		case "album":
+			var albums []spotify.SimplifiedAlbum
+			for _, id := range s.order.albums {
+				album := s.albums[id]
+				artists := artistNames(album.Artists)
+				if query.matches(album.Name+" "+artists, map[string]string{"album": album.Name, "artist": artists}) {
+					albums = append(albums, album.SimplifiedAlbum)
+				}
+			}
+			result.Albums = pageRef(href, albums, limit, offset)
+		case "track":
+			var tracks []spotify.Track
+			for _, id := range s.order.tracks {
+				track := s.tracks[id]
+				artists := artistNames(track.Artists)
+				fields := map[string]string{
+					"track":  track.Name,
+					"album":  track.Album.Name,
+					"artist": artists,
+					"isrc":   track.ExternalIDs.ISRC,
+				}
+				if query.matches(track.Name+" "+artists+" "+track.Album.Name, fields) {
+					tracks = append(tracks, *track)
+				}
+			}
+			result.Tracks = pageRef(href, tracks, limit, offset)
+		case "playlist":
+			var playlists []spotify.SimplifiedPlaylist
+			for _, id := range s.order.playlists {
+				p := s.playlists[id]
+				if p.Public != nil && *p.Public && query.matches(p.Name, nil) {
+					playlists = append(playlists, s.simplified(p))
+				}
+			}
+			result.Playlists = pageRef(href, playlists, limit, offset)
+		default:
+			apiError(w, http.StatusBadRequest, "Bad search type field "+kind)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// searchQuery is a parsed search query: free words, and field filters (i.e. isrc:USGRV2100001).
+type searchQuery struct {
+	words   []string
+	filters map[string]string
+}
+
+func parseQuery(q string) searchQuery {
+	var tokens []string
+	var token strings.Builder
+	quoted := false
+	for _, char := range q {
+		switch {
+		case char == '"':
+			quoted = !quoted
+		case char == ' ' && !quoted:
+			if token.Len() > 0 {
+				tokens = append(tokens, token.String())
+				token.Reset()
+			}
+		default:
+			token.WriteRune(char)
+		}
+	}
+	if token.Len() > 0 {
+		tokens = append(tokens, token.String())
+	}
+
+	query := searchQuery{filters: map[string]string{}}
+	for _, token := range tokens {
+		key, value, found := strings.Cut(token, ":")
+		switch {
+		case found && (key == "artist" || key == "album" || key == "track" || key == "isrc"):
+			query.filters[key] = strings.ToLower(value)
+		default:
+			query.words = append(query.words, strings.ToLower(token))
+		}
+	}
+	return query
+}
+
+// matches reports whether text contains every word, and fields satisfy every filter.
+// a filter on a field the object doesn't have never matches (i.e. isrc: when searching artists).
+func (q searchQuery) matches(text string, fields map[string]string) bool {
+	text = strings.ToLower(text)
+	for _, word := range q.words {
+		if !strings.Contains(text, word) {
+			return false
+		}
+	}
+	for key, value := range q.filters {
+		field, ok := fields[key]
+		if !ok {
+			return false
+		}
+		field = strings.ToLower(field)
+		if key == "isrc" && field != value || !strings.Contains(field, value) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Server) getMe(w http.ResponseWriter, grant *grant) {
+	user, ok := s.user(w, grant)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
+
+func (s *Server) getMyPlaylists(w http.ResponseWriter, r *http.Request, grant *grant) {
+	user, ok := s.user(w, grant)
+	if !ok {
+		return
+	}
+
+	var playlists []spotify.SimplifiedPlaylist
+	for _, id := range s.order.playlists {
+		if p := s.playlists[id]; p.followers[user.ID] {
+			playlists = append(playlists, s.simplified(p))
+		}
+	}
+	writePage(w, r, s.URL+r.URL.Path, playlists, 20, 50)
+}
+
+func (s *Server) getPlaylist(w http.ResponseWriter, grant *grant, id string) {
+	p, ok := s.playlist(w, grant, id)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.full(p))
+}
+
+func (s *Server) createPlaylist(w http.ResponseWriter, r *http.Request, grant *grant, userID string) {
+	user, ok := s.user(w, grant)
+	if !ok {
+		return
+	}
+	if user.ID != userID {
+		apiError(w, http.StatusForbidden, "You cannot create a playlist for another user.")
+		return
+	}
+
+	var body struct {
+		Name          string `json:"name"`
+		Description   string `json:"description"`
+		Public        *bool  `json:"public"`
+		Collaborative bool   `json:"collaborative"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		apiError(w, http.StatusBadRequest, "Error parsing JSON.")
+		return
+	}
+	if body.Name == "" {
+		apiError(w, http.StatusBadRequest, "Missing required field: name")
+		return
+	}
+	public := body.Public == nil || *body.Public
+	if public && body.Collaborative {
+		apiError(w, http.StatusBadRequest, "Collaborative playlists can only be private.")
+		return
+	}
+
+	p := &playlist{followers: map[string]bool{user.ID: true}}
+	p.ID = s.id("playlist")
+	s.link(&p.ExternalURLs, &p.Href, &p.Type, &p.URI, "playlist", p.ID)
+	p.Name = body.Name
+	p.Description = body.Description
+	p.Public = &public
+	p.Collaborative = body.Collaborative
+	p.Owner = s.publicUser(user.ID)
+	p.Images = []spotify.Image{}
+	p.SnapshotID = s.id("snapshot")
+	s.playlists[p.ID] = p
+	// like Spotify, new playlists are listed first.
+	s.order.playlists = append([]string{p.ID}, s.order.playlists...)
+	writeJSON(w, http.StatusCreated, s.full(p))
+}
+
+func (s *Server) changePlaylistDetails(w http.ResponseWriter, r *http.Request, grant *grant, id string) {
+	user, ok := s.user(w, grant)
+	if !ok {
+		return
+	}
+	p, ok := s.playlist(w, grant, id)
+	if !ok {
+		return
+	}
+	if p.Owner.ID != user.ID {
+		apiError(w, http.StatusForbidden, "You cannot change details of a playlist you don't own.")
+		return
+	}
+
+	var body spotify.PlaylistDetails
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		apiError(w, http.StatusBadRequest, "Error parsing JSON.")
+		return
+	}
+
+	public, collaborative := p.Public != nil && *p.Public, p.Collaborative
+	if body.Public != nil {
+		public = *body.Public
+	}
+	if body.Collaborative != nil {
+		collaborative = *body.Collaborative
+	}
+	if public && collaborative {
+		apiError(w, http.StatusBadRequest, "Collaborative playlists can only be private.")
+		return
+	}
+	if body.Name != nil && *body.Name == "" {
+		apiError(w, http.StatusBadRequest, "Playlist name cannot be empty.")
+		return
+	}
+
+	if body.Name != nil {
+		p.Name = *body.Name
+	}
+	if body.Description != nil {
+		p.Description = *body.Description
+	}
+	p.Public = &public
+	p.Collaborative = collaborative
+	p.SnapshotID = s.id("snapshot")
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) unfollowPlaylist(w http.ResponseWriter, grant *grant, id string) {
+	user, ok := s.user(w, grant)
+	if !ok {
+		return
+	}
+	p, ok := s.playlist(w, grant, id)
+	if !ok {
+		return
+	}
+
+	delete(p.followers, user.ID)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) getPlaylistTracks(w http.ResponseWriter, r *http.Request, grant *grant, id string) {
+	p, ok := s.playlist(w, grant, id)
+	if !ok {
+		return
+	}
+	writePage(w, r, s.URL+r.URL.Path, p.items, 100, 100)
+}
+
+func (s *Server) addPlaylistItems(w http.ResponseWriter, r *http.Request, grant *grant, id string) {
+	p, user, ok := s.modifiable(w, grant, id)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		URIs     []string `json:"uris"`
+		Position *int     `json:"position"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		apiError(w, http.StatusBadRequest, "Error parsing JSON.")
+		return
+	}
+	if len(body.URIs) == 0 {
+		apiError(w, http.StatusBadRequest, "No uris provided")
+		return
+	}
+	if len(body.URIs) > 100 {
+		apiError(w, http.StatusBadRequest, "Too many ids requested")
+		return
+	}
+
+	position := len(p.items)
+	if body.Position != nil {
+		if *body.Position < 0 || *body.Position > len(p.items) {
+			apiError(w, http.StatusBadRequest, "Index out of bounds")
+			return
+		}
+		position = *body.Position
+	}
+
+	items := make([]spotify.PlaylistTrack, len(body.URIs))
+	for i, uri := range body.URIs {
+		trackID, ok := s.trackURI(w, uri)
+		if !ok {
+			return
+		}
+		items[i] = s.playlistTrack(trackID, user.PublicUser)
+	}
+
+	p.items = append(p.items[:position:position], append(items, p.items[position:]...)...)
+	p.SnapshotID = s.id("snapshot")
+	writeJSON(w, http.StatusCreated, spotify.Snapshot{SnapshotID: p.SnapshotID})
+}
+
+func (s *Server) removePlaylistItems(w http.ResponseWriter, r *http.Request, grant *grant, id string) {
+	p, _, ok := s.modifiable(w, grant, id)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Tracks []struct {
+			URI       string `json:"uri"`
+			Positions []int  `json:"positions"`
+		} `json:"tracks"`
+		SnapshotID string `json:"snapshot_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		apiError(w, http.StatusBadRequest, "Error parsing JSON.")
+		return
+	}
+	if len(body.Tracks) == 0 {
+		apiError(w, http.StatusBadRequest, "No tracks provided")
+		return
+	}
+	if len(body.Tracks) > 100 {
+		apiError(w, http.StatusBadRequest, "Too many ids requested")
+		return
+	}
+
+	remove := map[int]bool{}
+	everywhere := map[string]bool{}
+	for _, track := range body.Tracks {
+		if _, ok := s.trackURI(w, track.URI); !ok {
+			return
+		}
+		if len(track.Positions) == 0 {
+			everywhere[track.URI] = true
+			continue
+		}
+		for _, position := range track.Positions {
+			if position < 0 || position >= len(p.items) || p.items[position].Track == nil || p.items[position].Track.URI != track.URI {
+				apiError(w, http.StatusBadRequest, "Could not remove tracks, please check parameters.")
+				return
+			}
+			remove[position] = true
+		}
+	}
+
+	var kept []spotify.PlaylistTrack
+	for i, item := range p.items {
+		if remove[i] || (item.Track != nil && everywhere[item.Track.URI]) {
+			continue
+		}
+		kept = append(kept, item)
+	}
+	p.items = kept
+	p.SnapshotID = s.id("snapshot")
+	writeJSON(w, http.StatusOK, spotify.Snapshot{SnapshotID: p.SnapshotID})
+}
+
+func (s *Server) reorderPlaylistItems(w http.ResponseWriter, r *http.Request, grant *grant, id string) {
+	p, _, ok := s.modifiable(w, grant, id)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		RangeStart   *int   `json:"range_start"`
+		InsertBefore *int   `json:"insert_before"`
+		RangeLength  *int   `json:"range_length"`
+		SnapshotID   string `json:"snapshot_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		apiError(w, http.StatusBadRequest, "Error parsing JSON.")
+		return
+	}
+	if body.RangeStart == nil || body.InsertBefore == nil {
+		apiError(w, http.StatusBadRequest, "Missing required field: range_start or insert_before")
+		return
+	}
+
+	start, before, length := *body.RangeStart, *body.InsertBefore, 1
+	if body.RangeLength != nil {
+		length = *body.RangeLength
+	}
+	if start < 0 || length < 1 || start+length > len(p.items) || before < 0 || before > len(p.items) {
+		apiError(w, http.StatusBadRequest, "Index out of bounds")
+		return
+	}
+
+	// inserting within the range moves nothing, as with Spotify.
+	if before < start || before > start+length {
+		moved := append([]spotify.PlaylistTrack(nil), p.items[start:start+length]...)
+		rest := append(append([]spotify.PlaylistTrack(nil), p.items[:start]...), p.items[start+length:]...)
+		if before > start {
+			before -= length
+		}
+		p.items = append(rest[:before:before], append(moved, rest[before:]...)...)
+	}
+	p.SnapshotID = s.id("snapshot")
+	writeJSON(w, http.StatusOK, spotify.Snapshot{SnapshotID: p.SnapshotID})
+}
+
+// user returns the user of a grant, answering 401 for app tokens.
+func (s *Server) user(w http.ResponseWriter, grant *grant) (*spotify.PrivateUser, bool) {
+	user, ok := s.users[grant.userID]
+	if !ok {
+		apiError(w, http.StatusUnauthorized, "Valid user authentication required")
+		return nil, false
+	}
+	return user, true
+}
+
+// playlist returns a playlist visible to the grant: public playlists, and private ones the user follows.
+func (s *Server) playlist(w http.ResponseWriter, grant *grant, id string) (*playlist, bool) {
+	p, ok := s.playlists[id]
+	if !ok || ((p.Public == nil || !*p.Public) && !p.followers[grant.userID]) {
+		apiError(w, http.StatusNotFound, "Resource not found")
+		return nil, false
+	}
+	return p, true
+}
+
+// modifiable returns a playlist the grant's user may modify: one they own, or a collaborative one they follow.
+func (s *Server) modifiable(w http.ResponseWriter, grant *grant, id string) (*playlist, *spotify.PrivateUser, bool) {
+	user, ok := s.user(w, grant)
+	if !ok {
+		return nil, nil, false
+	}
+	p, ok := s.playlist(w, grant, id)
+	if !ok {
+		return nil, nil, false
+	}
+	if p.Owner.ID != user.ID && !(p.Collaborative && p.followers[user.ID]) {
+		apiError(w, http.StatusForbidden, "You cannot add tracks to a playlist you don't own.")
+		return nil, nil, false
+	}
+	return p, user, true
+}
+
+// trackURI returns the id of a spotify:track:{id} uri of a fixture track, answering 400 otherwise.
+func (s *Server) trackURI(w http.ResponseWriter, uri string) (string, bool) {
+	id, found := strings.CutPrefix(uri, "spotify:track:")
+	if !found {
+		apiError(w, http.StatusBadRequest, "Invalid track uri: "+uri)
+		return "", false
+	}
+	if _, ok := s.tracks[id]; !ok {
+		apiError(w, http.StatusBadRequest, "Payload contains a non-existing ID")
+		return "", false
+	}
+	return id, true
+}
+
+func (s *Server) full(p *playlist) spotify.Playlist {
+	return spotify.Playlist{
+		Collaborative: p.Collaborative,
+		Description:   p.Description,
+		ExternalURLs:  p.ExternalURLs,
+		Followers:     spotify.Followers{Total: len(p.followers)},
+		Href:          p.Href,
+		ID:            p.ID,
+		Images:        p.Images,
+		Name:          p.Name,
+		Owner:         p.Owner,
+		Public:        p.Public,
+		SnapshotID:    p.SnapshotID,
+		Tracks:        page(p.Href+"/tracks", p.items, 100, 0),
+		Type:          p.Type,
+		URI:           p.URI,
+	}
+}
+
+func (s *Server) simplified(p *playlist) spotify.SimplifiedPlaylist {
+	simplified := p.SimplifiedPlaylist
+	simplified.Tracks = spotify.PlaylistTracksRef{Href: p.Href + "/tracks", Total: len(p.items)}
+	return simplified
+}
+
+// writePage writes the page of items selected by the limit and offset query parameters.
+func writePage[T any](w http.ResponseWriter, r *http.Request, href string, items []T, defaultLimit, maxLimit int) {
+	limit, offset, ok := pageParams(w, r, defaultLimit, maxLimit)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, page(href, items, limit, offset))
+}
+
+// pageParams parses the limit and offset query parameters, answering 400 when invalid.
+func pageParams(w http.ResponseWriter, r *http.Request, defaultLimit, maxLimit int) (int, int, bool) {
+	limit, offset := defaultLimit, 0
+	var err error
+	if value := r.URL.Query().Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > maxLimit {
+			apiError(w, http.StatusBadRequest, "Invalid limit")
+			return 0, 0, false
+		}
+	}
+	if value := r.URL.Query().Get("offset"); value != "" {
+		if offset, err = strconv.Atoi(value); err != nil || offset < 0 {
+			apiError(w, http.StatusBadRequest, "Invalid offset")
+			return 0, 0, false
+		}
+	}
+	return limit, offset, true
+}
+
+// page builds the Paging of items[offset:offset+limit], with next/previous urls relative to href.
+func page[T any](href string, items []T, limit, offset int) spotify.Paging[T] {
+	paging := spotify.Paging[T]{
+		Href:   pageURL(href, limit, offset),
+		Items:  []T{},
+		Limit:  limit,
+		Offset: offset,
+		Total:  len(items),
+	}
+	if offset < len(items) {
+		end := offset + limit
+		if end > len(items) {
+			end = len(items)
+		}
+		paging.Items = items[offset:end]
+	}
+	if offset+limit < len(items) {
+		next := pageURL(href, limit, offset+limit)
+		paging.Next = &next
+	}
+	if offset > 0 {
+		start := offset - limit
+		if start < 0 {
+			start = 0
+		}
+		previous := pageURL(href, limit, start)
+		paging.Previous = &previous
+	}
+	return paging
+}
+
+func pageRef[T any](href string, items []T, limit, offset int) *spotify.Paging[T] {
+	paging := page(href, items, limit, offset)
+	return &paging
+}
+
+func pageURL(href string, limit, offset int) string {
+	separator := "?"
+	if strings.Contains(href, "?") {
+		separator = "&"
+	}
+	return href + separator + "offset=" + strconv.Itoa(offset) + "&limit=" + strconv.Itoa(limit)
+}
+
+func hasArtist(artists []spotify.SimplifiedArtist, id string) bool {
+	for _, artist := range artists {
+		if artist.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func artistNames(artists []spotify.SimplifiedArtist) string {
+	names := make([]string, len(artists))
+	for i, artist := range artists {
+		names[i] = artist.Name
+	}
+	return strings.Join(names, " ")
+}
@@ -0,0 +1,51 @@
+package spotifytest
+
+import (
+	"embed"
+	"encoding/json"
+	"groove/pkgs/spotify"
+)
+
+//go:embed fixtures/catalog.json
+var fixtureFiles embed.FS
+
+// UserID is the id of the fixture user tokens are issued for by default.
+const UserID = "grooveuser"
+
+// Fixtures is the data served by a Server.
+// objects reference each other by id only (i.e. a track's album is {"id": "..."});
+// the server fills in the referenced objects along with hrefs, uris and types.
+type Fixtures struct {
+	Users   []spotify.PrivateUser `json:"users"`
+	Artists []spotify.Artist      `json:"artists"`
+	// Related maps an artist id to the ids of its related artists.
+	Related map[string][]string `json:"related"`
+	// Albums are served with the fixture tracks whose album matches.
+	Albums    []spotify.Album   `json:"albums"`
+	Tracks    []spotify.Track   `json:"tracks"`
+	Playlists []PlaylistFixture `json:"playlists"`
+}
+
+// PlaylistFixture is a playlist along with its items and followers.
+type PlaylistFixture struct {
+	spotify.SimplifiedPlaylist
+	// Items are the ids of the tracks in the playlist, in order.
+	Items []string `json:"items"`
+	// FollowedBy are the ids of the users following the playlist, besides its owner.
+	FollowedBy []string `json:"followed_by"`
+}
+
+// LoadFixtures returns a fresh copy of the default fixtures (fixtures/catalog.json),
+// i.e. to be extended before being passed to NewServerWith.
+func LoadFixtures() *Fixtures {
+	data, err := fixtureFiles.ReadFile("fixtures/catalog.json")
+	if err != nil {
+		panic("spotifytest: reading fixtures: " + err.Error())
+	}
+
+	fixtures := new(Fixtures)
+	if err = json.Unmarshal(data, fixtures); err != nil {
+		panic("spotifytest: parsing fixtures: " + err.Error())
+	}
+	return fixtures
+}
@@ -0,0 +1,404 @@
+package spotifytest
+
+import (
+	"encoding/json"
+	"groove/pkgs/spotify"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+ * Server is an in-process fake of the Spotify Web API (under /v1) and Accounts API (/authorize and /api/token),
+ * serving the fixtures in fixtures/ so the app can be exercised end-to-end without network access or a Spotify
+ * account. Playlists are mutable; everything else is read-only. Errors (4xx, 429 with Retry-After, 5xx) can be
+ * injected per endpoint with Fail.
+ *
+ *   fake := spotifytest.NewServer()
+ *   defer fake.Close()
+ *   client := spotify.New(fake.Config())
+ */
+
+const (
+	// ClientID and ClientSecret are the app credentials the server accepts.
+	ClientID     = "groove-test-client"
+	ClientSecret = "groove-test-secret"
+	// tokenTTL is the lifetime of issued access tokens, in seconds.
+	tokenTTL = 3600
+)
+
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	users     map[string]*spotify.PrivateUser
+	artists   map[string]*spotify.Artist
+	related   map[string][]string
+	albums    map[string]*spotify.Album
+	tracks    map[string]*spotify.Track
+	playlists map[string]*playlist
+	// order keeps fixture order for listings (maps are unordered).
+	order    order
+	codes    map[string]string
+	access   map[string]*grant
+	refresh  map[string]string
+	faults   []*Fault
+	calls    map[string]int
+	sequence int
+}
+
+type order struct {
+	artists, albums, tracks, playlists []string
+}
+
+// grant is an issued access token.
+type grant struct {
+	// userID is empty for app tokens (client-credentials grant).
+	userID     string
+	expiration time.Time
+}
+
+type playlist struct {
+	spotify.SimplifiedPlaylist
+	items     []spotify.PlaylistTrack
+	followers map[string]bool
+}
+
+// Fault makes the server answer matching requests with an error instead of serving them.
+type Fault struct {
+	// Method and Path select the failing requests: an empty Method matches any method,
+	// and Path matches every path starting with it (i.e. "/v1/artists/" or "/api/token").
+	Method string
+	Path   string
+	Status int
+	// Message defaults to the status text.
+	Message string
+	// Reason is the OAuth error code of Accounts API errors (i.e. invalid_grant).
+	Reason string
+	// RetryAfter sets the Retry-After header, rounded up to seconds.
+	RetryAfter time.Duration
+	// Times is how many matching requests fail; 0 fails all of them until ClearFaults.
+	Times int
+	// Applied serves the request before failing it, as Spotify can when it errors after committing a write.
+	Applied bool
+}
+
+// NewServer starts a server serving the default fixtures. it must be closed with Close.
+func NewServer() *Server {
+	return NewServerWith(LoadFixtures())
+}
+
+// NewServerWith starts a server serving the given fixtures. it must be closed with Close.
+func NewServerWith(fixtures *Fixtures) *Server {
+	s := &Server{
+		users:     map[string]*spotify.PrivateUser{},
+		artists:   map[string]*spotify.Artist{},
+		related:   fixtures.Related,
+		albums:    map[string]*spotify.Album{},
+		tracks:    map[string]*spotify.Track{},
+		playlists: map[string]*playlist{},
+		codes:     map[string]string{},
+		access:    map[string]*grant{},
+		refresh:   map[string]string{},
+		calls:     map[string]int{},
+	}
+	s.Server = httptest.NewServer(s)
+	s.load(fixtures)
+	return s
+}
+
+// Config returns a spotify.Config pointed at the server, with retries and throttling fast enough for tests.
+func (s *Server) Config() spotify.Config {
+	return spotify.Config{
+		APIURL:            s.APIURL(),
+		AccountsURL:       s.AccountsURL(),
+		ClientID:          ClientID,
+		ClientSecret:      ClientSecret,
+		RetryWait:         time.Millisecond,
+		MaxRetryWait:      100 * time.Millisecond,
+		RequestsPerSecond: 1000,
+		Burst:             1000,
+		SharedPerSecond:   1000,
+		SharedBurst:       1000,
+	}
+}
+
+// APIURL is the base url of the fake Web API (SPOTIFY_API_URL).
+func (s *Server) APIURL() string {
+	return s.URL + "/v1"
+}
+
+// AccountsURL is the root url of the fake Accounts service (SPOTIFY_ACCOUNTS_URL).
+func (s *Server) AccountsURL() string {
+	return s.URL
+}
+
+// Fail injects a fault; faults are matched in the order they were injected.
+func (s *Server) Fail(fault Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &fault)
+}
+
+// ClearFaults removes every injected fault.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// Calls returns how many requests were made with the method to the path (without query), faults included.
+func (s *Server) Calls(method, path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[method+" "+path]
+}
+
+// Authorize issues an authorization code for the user, as the Authorization page does once the user consents.
+func (s *Server) Authorize(userID string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	code := s.id("code")
+	s.codes[code] = userID
+	return code
+}
+
+// Link issues tokens for the user as if they went through the authorization flow, i.e. to seed a SpotifyLink.
+func (s *Server) Link(userID string) *spotify.Tokens {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.issue(userID, true)
+}
+
+// ExpireAccessToken makes the Web API reject the access token as expired.
+func (s *Server) ExpireAccessToken(access string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if grant, ok := s.access[access]; ok {
+		grant.expiration = time.Now().Add(-time.Second)
+	}
+}
+
+// RevokeRefreshToken makes the Accounts API answer invalid_grant to the refresh token,
+// as it does once the user removes the app from their account.
+func (s *Server) RevokeRefreshToken(refresh string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.refresh, refresh)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls[r.Method+" "+r.URL.Path]++
+	if fault := s.fault(r); fault != nil {
+		if fault.Applied {
+			s.serve(httptest.NewRecorder(), r)
+		}
+		s.writeFault(w, r, fault)
+		return
+	}
+	s.serve(w, r)
+}
+
+// serve routes a request to its endpoint; must be called with s.mu held.
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/authorize":
+		s.authorize(w, r)
+	case r.URL.Path == "/api/token":
+		s.token(w, r)
+	case strings.HasPrefix(r.URL.Path, "/v1/"):
+		s.api(w, r)
+	default:
+		apiError(w, http.StatusNotFound, "Service not found")
+	}
+}
+
+// fault returns the first fault matching the request, consuming one of its Times.
+func (s *Server) fault(r *http.Request) *Fault {
+	for i, fault := range s.faults {
+		if fault.Method != "" && fault.Method != r.Method {
+			continue
+		}
+		if !strings.HasPrefix(r.URL.Path, fault.Path) {
+			continue
+		}
+
+		if fault.Times > 0 {
+			fault.Times--
+			if fault.Times == 0 {
+				s.faults = append(s.faults[:i:i], s.faults[i+1:]...)
+			}
+		}
+		return fault
+	}
+	return nil
+}
+
+func (s *Server) writeFault(w http.ResponseWriter, r *http.Request, fault *Fault) {
+	if fault.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(fault.RetryAfter.Seconds()))))
+	}
+
+	message := fault.Message
+	if message == "" {
+		message = http.StatusText(fault.Status)
+	}
+
+	if strings.HasPrefix(r.URL.Path, "/v1/") {
+		apiError(w, fault.Status, message)
+		return
+	}
+	reason := fault.Reason
+	if reason == "" {
+		reason = "server_error"
+	}
+	accountsError(w, fault.Status, reason, message)
+}
+
+// authorize stands in for the Authorization page: it redirects straight back to the app with a code,
+// as if the user (UserID, or the "user" query parameter) had consented.
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("client_id") != ClientID {
+		accountsError(w, http.StatusBadRequest, "invalid_client", "Invalid client")
+		return
+	}
+	if query.Get("response_type") != "code" {
+		accountsError(w, http.StatusBadRequest, "unsupported_response_type", "response_type must be code")
+		return
+	}
+
+	redirect, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || redirect.Scheme == "" {
+		accountsError(w, http.StatusBadRequest, "invalid_request", "Invalid redirect URI")
+		return
+	}
+
+	userID := query.Get("user")
+	if userID == "" {
+		userID = UserID
+	}
+	code := s.id("code")
+	s.codes[code] = userID
+
+	params := redirect.Query()
+	params.Set("code", code)
+	params.Set("state", query.Get("state"))
+	redirect.RawQuery = params.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+// token implements the authorization_code, refresh_token and client_credentials grants.
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		accountsError(w, http.StatusMethodNotAllowed, "invalid_request", "Method not allowed")
+		return
+	}
+	id, secret, ok := r.BasicAuth()
+	if !ok || id != ClientID || secret != ClientSecret {
+		accountsError(w, http.StatusBadRequest, "invalid_client", "Invalid client")
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		accountsError(w, http.StatusBadRequest, "invalid_request", "Invalid form")
+		return
+	}
+
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		userID, ok := s.codes[r.PostForm.Get("code")]
+		if !ok {
+			accountsError(w, http.StatusBadRequest, "invalid_grant", "Invalid authorization code")
+			return
+		}
+		delete(s.codes, r.PostForm.Get("code"))
+		writeJSON(w, http.StatusOK, s.issue(userID, true))
+	case "refresh_token":
+		userID, ok := s.refresh[r.PostForm.Get("refresh_token")]
+		if !ok {
+			accountsError(w, http.StatusBadRequest, "invalid_grant", "Invalid refresh token")
+			return
+		}
+		// like Spotify, refresh tokens are not rotated.
+		writeJSON(w, http.StatusOK, s.issue(userID, false))
+	case "client_credentials":
+		writeJSON(w, http.StatusOK, s.issue("", false))
+	default:
+		accountsError(w, http.StatusBadRequest, "unsupported_grant_type", "grant_type must be authorization_code, refresh_token or client_credentials")
+	}
+}
+
+// issue issues an access token (and optionally a refresh token) for the user. must be called with s.mu held.
+func (s *Server) issue(userID string, withRefresh bool) *spotify.Tokens {
+	tokens := &spotify.Tokens{
+		AccessToken: s.id("access"),
+		TokenType:   "Bearer",
+		ExpiresIn:   tokenTTL,
+	}
+	s.access[tokens.AccessToken] = &grant{
+		userID:     userID,
+		expiration: time.Now().Add(tokenTTL * time.Second),
+	}
+
+	if withRefresh {
+		tokens.RefreshToken = s.id("refresh")
+		s.refresh[tokens.RefreshToken] = userID
+	}
+	return tokens
+}
+
+// authenticate returns the grant of the request's bearer token, answering 401 if there is none.
+func (s *Server) authenticate(w http.ResponseWriter, r *http.Request) (*grant, bool) {
+	access, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found || access == "" {
+		apiError(w, http.StatusUnauthorized, "No token provided")
+		return nil, false
+	}
+
+	grant, ok := s.access[access]
+	if !ok {
+		apiError(w, http.StatusUnauthorized, "Invalid access token")
+		return nil, false
+	}
+	if time.Now().After(grant.expiration) {
+		apiError(w, http.StatusUnauthorized, "The access token expired")
+		return nil, false
+	}
+	return grant, true
+}
+
+// id returns a new unique id with the given prefix. must be called with s.mu held.
+func (s *Server) id(prefix string) string {
+	s.sequence++
+	return prefix + "-" + strconv.Itoa(s.sequence)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// apiError writes a Web API error: {"error": {"status": 400, "message": "..."}}.
+func apiError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]any{
+		"error": map[string]any{"status": status, "message": message},
+	})
+}
+
+// accountsError writes an Accounts API error: {"error": "invalid_grant", "error_description": "..."}.
+func accountsError(w http.ResponseWriter, status int, reason, message string) {
+	writeJSON(w, status, map[string]string{
+		"error":             reason,
+		"error_description": message,
+	})
+}
@@ -0,0 +1,13 @@
+package spotify
+
+import (
+	"context"
+	"net/url"
+)
+
+// GetTrack returns the track with the given id.
+func (c *Client) GetTrack(ctx context.Context, access, trackID, market string) (*Track, error) {
+	track := new(Track)
+	err := c.get(ctx, access, "/tracks/"+url.PathEscape(trackID), query("market", market), track)
+	return track, err
+}
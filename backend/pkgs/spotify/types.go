@@ -0,0 +1,195 @@
+package spotify
+
+/*
+ * Response objects of the Spotify Web API. Only the fields Groove uses (or is likely to) are mapped;
+ * see https://developer.spotify.com/documentation/web-api/reference for the full objects.
+ */
+
+type ExternalURLs struct {
+	Spotify string `json:"spotify"`
+}
+
+type ExternalIDs struct {
+	ISRC string `json:"isrc,omitempty"`
+	EAN  string `json:"ean,omitempty"`
+	UPC  string `json:"upc,omitempty"`
+}
+
+type Followers struct {
+	Href  *string `json:"href"`
+	Total int     `json:"total"`
+}
+
+type Image struct {
+	URL    string `json:"url"`
+	Height *int   `json:"height"`
+	Width  *int   `json:"width"`
+}
+
+type Copyright struct {
+	Text string `json:"text"`
+	Type string `json:"type"`
+}
+
+// Paging is Spotify's wrapper for paginated lists of items.
+type Paging[T any] struct {
+	Href     string  `json:"href"`
+	Items    []T     `json:"items"`
+	Limit    int     `json:"limit"`
+	Next     *string `json:"next"`
+	Offset   int     `json:"offset"`
+	Previous *string `json:"previous"`
+	Total    int     `json:"total"`
+}
+
+type SimplifiedArtist struct {
+	ExternalURLs ExternalURLs `json:"external_urls"`
+	Href         string       `json:"href"`
+	ID           string       `json:"id"`
+	Name         string       `json:"name"`
+	Type         string       `json:"type"`
+	URI          string       `json:"uri"`
+}
+
+type Artist struct {
+	SimplifiedArtist
+	Followers  Followers `json:"followers"`
+	Genres     []string  `json:"genres"`
+	Images     []Image   `json:"images"`
+	Popularity int       `json:"popularity"`
+}
+
+type SimplifiedAlbum struct {
+	AlbumType            string             `json:"album_type"`
+	Artists              []SimplifiedArtist `json:"artists"`
+	ExternalURLs         ExternalURLs       `json:"external_urls"`
+	Href                 string             `json:"href"`
+	ID                   string             `json:"id"`
+	Images               []Image            `json:"images"`
+	IsPlayable           bool               `json:"is_playable"`
+	Name                 string             `json:"name"`
+	ReleaseDate          string             `json:"release_date"`
+	ReleaseDatePrecision string             `json:"release_date_precision"`
+	TotalTracks          int                `json:"total_tracks"`
+	Type                 string             `json:"type"`
+	URI                  string             `json:"uri"`
+}
+
+type Album struct {
+	SimplifiedAlbum
+	Copyrights  []Copyright             `json:"copyrights"`
+	ExternalIDs ExternalIDs             `json:"external_ids"`
+	Genres      []string                `json:"genres"`
+	Label       string                  `json:"label"`
+	Popularity  int                     `json:"popularity"`
+	Tracks      Paging[SimplifiedTrack] `json:"tracks"`
+}
+
+type SimplifiedTrack struct {
+	Artists      []SimplifiedArtist `json:"artists"`
+	DiscNumber   int                `json:"disc_number"`
+	DurationMs   int                `json:"duration_ms"`
+	Explicit     bool               `json:"explicit"`
+	ExternalURLs ExternalURLs       `json:"external_urls"`
+	Href         string             `json:"href"`
+	ID           string             `json:"id"`
+	IsLocal      bool               `json:"is_local"`
+	IsPlayable   bool               `json:"is_playable"`
+	Name         string             `json:"name"`
+	PreviewURL   *string            `json:"preview_url"`
+	TrackNumber  int                `json:"track_number"`
+	Type         string             `json:"type"`
+	URI          string             `json:"uri"`
+}
+
+type Track struct {
+	SimplifiedTrack
+	Album       SimplifiedAlbum `json:"album"`
+	ExternalIDs ExternalIDs     `json:"external_ids"`
+	Popularity  int             `json:"popularity"`
+}
+
+type PublicUser struct {
+	DisplayName  *string      `json:"display_name,omitempty"`
+	ExternalURLs ExternalURLs `json:"external_urls"`
+	Href         string       `json:"href"`
+	ID           string       `json:"id"`
+	Type         string       `json:"type"`
+	URI          string       `json:"uri"`
+}
+
+type PrivateUser struct {
+	PublicUser
+	Country string  `json:"country,omitempty"`
+	Email   string  `json:"email,omitempty"`
+	Images  []Image `json:"images"`
+	Product string  `json:"product,omitempty"`
+}
+
+type PlaylistTrack struct {
+	AddedAt string     `json:"added_at"`
+	AddedBy PublicUser `json:"added_by"`
+	IsLocal bool       `json:"is_local"`
+	// Track is nil if the track is no longer available.
+	Track *Track `json:"track"`
+}
+
+// PlaylistTracksRef is the reference to a playlist's tracks given by a SimplifiedPlaylist.
+type PlaylistTracksRef struct {
+	Href  string `json:"href"`
+	Total int    `json:"total"`
+}
+
+type SimplifiedPlaylist struct {
+	Collaborative bool              `json:"collaborative"`
+	Description   string            `json:"description"`
+	ExternalURLs  ExternalURLs      `json:"external_urls"`
+	Href          string            `json:"href"`
+	ID            string            `json:"id"`
+	Images        []Image           `json:"images"`
+	Name          string            `json:"name"`
+	Owner         PublicUser        `json:"owner"`
+	Public        *bool             `json:"public"`
+	SnapshotID    string            `json:"snapshot_id"`
+	Tracks        PlaylistTracksRef `json:"tracks"`
+	Type          string            `json:"type"`
+	URI           string            `json:"uri"`
+}
+
+type Playlist struct {
+	Collaborative bool                  `json:"collaborative"`
+	Description   string                `json:"description"`
+	ExternalURLs  ExternalURLs          `json:"external_urls"`
+	Followers     Followers             `json:"followers"`
+	Href          string                `json:"href"`
+	ID            string                `json:"id"`
+	Images        []Image               `json:"images"`
+	Name          string                `json:"name"`
+	Owner         PublicUser            `json:"owner"`
+	Public        *bool                 `json:"public"`
+	SnapshotID    string                `json:"snapshot_id"`
+	Tracks        Paging[PlaylistTrack] `json:"tracks"`
+	Type          string                `json:"type"`
+	URI           string                `json:"uri"`
+}
+
+// Snapshot is returned by endpoints that modify a playlist.
+type Snapshot struct {
+	SnapshotID string `json:"snapshot_id"`
+}
+
+type SearchResult struct {
+	Albums    *Paging[SimplifiedAlbum]    `json:"albums,omitempty"`
+	Artists   *Paging[Artist]             `json:"artists,omitempty"`
+	Playlists *Paging[SimplifiedPlaylist] `json:"playlists,omitempty"`
+	Tracks    *Paging[Track]              `json:"tracks,omitempty"`
+}
+
+// Tokens is the response of the Accounts API token endpoint.
+type Tokens struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	Scope        string `json:"scope"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
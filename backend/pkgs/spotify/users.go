@@ -0,0 +1,12 @@
+package spotify
+
+import (
+	"context"
+)
+
+// GetCurrentUser returns the Spotify profile of the owner of the access token.
+func (c *Client) GetCurrentUser(ctx context.Context, access string) (*PrivateUser, error) {
+	user := new(PrivateUser)
+	err := c.get(ctx, access, "/me", nil, user)
+	return user, err
+}
@@ -2,6 +2,8 @@ package util
 
 import (
 	"github.com/gofiber/fiber/v2"
+	"strconv"
+	"time"
 )
 
 func BadRequest(c *fiber.Ctx, msg string, code ...int) error {
@@ -39,3 +41,32 @@ func Forbidden(c *fiber.Ctx, msg string) error {
 		"message": msg,
 	})
 }
+
+func TooManyRequests(c *fiber.Ctx, msg string, retryAfter time.Duration) error {
+	seconds := retryAfterSeconds(retryAfter)
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+	return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+		"error":       "too many requests",
+		"message":     msg,
+		"retry_after": seconds,
+	})
+}
+
+func ServiceUnavailable(c *fiber.Ctx, msg string, retryAfter time.Duration) error {
+	seconds := retryAfterSeconds(retryAfter)
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+	return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+		"error":       "service unavailable",
+		"message":     msg,
+		"retry_after": seconds,
+	})
+}
+
+// retryAfterSeconds rounds a retry hint up to whole seconds (at least 1), as used by the Retry-After header.
+func retryAfterSeconds(d time.Duration) int {
+	seconds := int((d + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
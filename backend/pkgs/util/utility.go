@@ -1,6 +1,7 @@
 package util
 
 import (
+	"context"
 	"fmt"
 	"github.com/gofiber/fiber/v2"
 	"net/url"
@@ -14,9 +15,12 @@ const (
 	Time58Minutes = 58 * time.Minute
 )
 
+// HeaderCSRF is the header the Csrf token is sent in, as a copy of the Csrf cookie (double-submit).
+const HeaderCSRF = "X-CSRF-Token"
+
 const (
-	SpotifyAPI         = "https://api.spotify.com/v1"
-	SpotifyAccountsAPI = "https://accounts.spotify.com/api"
+	SpotifyAPI      = "https://api.spotify.com/v1"
+	SpotifyAccounts = "https://accounts.spotify.com"
 )
 
 type (
@@ -85,3 +89,18 @@ func URLSearchParams(params map[string]string) string {
 	}
 	return qParams.Encode()
 }
+
+// WithoutCancel returns a context that carries the values of ctx but is never cancelled with it,
+// for work shared by several callers or that must not be cut off halfway (context.WithoutCancel from Go 1.21).
+func WithoutCancel(ctx context.Context) context.Context {
+	return withoutCancel{ctx}
+}
+
+type withoutCancel struct {
+	parent context.Context
+}
+
+func (withoutCancel) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (withoutCancel) Done() <-chan struct{}       { return nil }
+func (withoutCancel) Err() error                  { return nil }
+func (c withoutCancel) Value(key any) any         { return c.parent.Value(key) }
@@ -0,0 +1,142 @@
+package actions
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"groove/pkgs/db"
+	"groove/pkgs/ent"
+	AccessToken "groove/pkgs/ent/accesstoken"
+	"groove/pkgs/secret"
+	. "groove/pkgs/util"
+	"net/http"
+	"time"
+)
+
+const (
+	// accessTokenPrefix marks personal access tokens, so they are recognizable (i.e. by secret scanners).
+	accessTokenPrefix = "groove_"
+	// maxAccessTokens is how many personal access tokens a user can have at once.
+	maxAccessTokens = 20
+	// maxAccessTokenDays is the longest a personal access token can be made to last.
+	maxAccessTokenDays = 365
+)
+
+// CreateAccessToken creates a personal access token for the user, which is sent back only this once.
+// a token expires after expiresInDays, or never if it is 0.
+// returns a 400 if the name, scopes or expiry are invalid, or the user has too many tokens.
+// returns a 201 with the token.
+func (a *Actions) CreateAccessToken(c *fiber.Ctx, name string, scopes []string, expiresInDays int) error {
+	session := c.Locals("session").(*ent.Session)
+	ctx := c.Context()
+
+	if len(name) == 0 || len(name) > 64 {
+		return BadRequest(c, "invalid name: must be between 1 and 64 characters")
+	}
+	if err := db.ValidateScopes(scopes); err != nil {
+		return BadRequest(c, err.Error())
+	}
+	if expiresInDays < 0 || expiresInDays > maxAccessTokenDays {
+		return BadRequest(c, "invalid expires_in_days: must be between 0 (never) and 365")
+	}
+
+	count, err := a.Client.AccessToken.
+		Query().
+		Where(AccessToken.UserIDEQ(session.UserID)).
+		Count(ctx)
+	if err != nil {
+		LogError("CreateAccessToken", "count tokens", err)
+		return InternalServerError(c, "error creating token")
+	} else if count >= maxAccessTokens {
+		return BadRequest(c, "too many tokens, revoke one first")
+	}
+
+	token, err := secret.NewToken()
+	if err != nil {
+		LogError("CreateAccessToken", "generate token", err)
+		return InternalServerError(c, "error creating token")
+	}
+	token = accessTokenPrefix + token
+
+	create := a.Client.AccessToken.Create().
+		SetUserID(session.UserID).
+		SetName(name).
+		SetTokenHash(secret.HashToken(token)).
+		SetScopes(scopes)
+	if expiresInDays > 0 {
+		create.SetExpiration(time.Now().AddDate(0, 0, expiresInDays))
+	}
+
+	accessToken, err := create.Save(ctx)
+	if err != nil {
+		LogError("CreateAccessToken", "create token", err)
+		return InternalServerError(c, "error creating token")
+	}
+
+	response := accessTokenResponse(accessToken)
+	response["token"] = token
+	return c.Status(http.StatusCreated).JSON(fiber.Map{
+		"acknowledged": true,
+		"message":      "token created, copy it now as it won't be shown again",
+		"token":        response,
+	})
+}
+
+// GetAccessTokens sends the personal access tokens of the user, without the tokens themselves.
+// returns a 200 with the tokens.
+func (a *Actions) GetAccessTokens(c *fiber.Ctx) error {
+	session := c.Locals("session").(*ent.Session)
+
+	tokens, err := a.Client.AccessToken.
+		Query().
+		Where(AccessToken.UserIDEQ(session.UserID)).
+		Order(ent.Desc(AccessToken.FieldCreatedAt)).
+		All(c.Context())
+	if err != nil {
+		LogError("GetAccessTokens", "query tokens", err)
+		return InternalServerError(c, "error getting tokens")
+	}
+
+	response := make([]fiber.Map, len(tokens))
+	for i, t := range tokens {
+		response[i] = accessTokenResponse(t)
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"tokens": response,
+	})
+}
+
+// RevokeAccessToken deletes a personal access token of the user; requests using it are rejected from then on.
+// returns a 404 if the user has no token with this id.
+// returns a 204 if the token is deleted.
+func (a *Actions) RevokeAccessToken(c *fiber.Ctx, id int) error {
+	session := c.Locals("session").(*ent.Session)
+
+	// scoped to the user, so tokens of others cannot be revoked.
+	affected, err := a.Client.AccessToken.
+		Delete().
+		Where(
+			AccessToken.IDEQ(id),
+			AccessToken.UserIDEQ(session.UserID),
+		).
+		Exec(c.Context())
+	if err != nil {
+		LogError("RevokeAccessToken", "delete token", err)
+		return InternalServerError(c, "error revoking token")
+	} else if affected == 0 {
+		return BadRequest(c, "token not found", http.StatusNotFound)
+	}
+
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// accessTokenResponse is a personal access token, as sent to the user.
+func accessTokenResponse(t *ent.AccessToken) fiber.Map {
+	return fiber.Map{
+		"id":         t.ID,
+		"name":       t.Name,
+		"scopes":     t.Scopes,
+		"created_at": t.CreatedAt,
+		"last_used":  t.LastUsed,
+		"expiration": t.Expiration,
+	}
+}
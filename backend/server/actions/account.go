@@ -0,0 +1,270 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+	"groove/pkgs/db"
+	"groove/pkgs/ent"
+	PasswordReset "groove/pkgs/ent/passwordreset"
+	Session "groove/pkgs/ent/session"
+	User "groove/pkgs/ent/user"
+	"groove/pkgs/mail"
+	. "groove/pkgs/util"
+	"net/http"
+)
+
+// confirmPassword checks the current password of the user, re-entered to confirm a sensitive change.
+// failures count towards the lockout of the account (see Login), so a stolen session cannot be used to guess it.
+// if it returns false, the response was already sent and is returned as the error.
+func (a *Actions) confirmPassword(c *fiber.Ctx, fn string, user *ent.User, password string) (bool, error) {
+	if user.Password == "" {
+		return false, BadRequest(c, "account has no password, set one first")
+	}
+
+	ctx := c.Context()
+	wait, err := a.locked(ctx, loginIPKey(c.IP()), loginAccountKey(user.Username))
+	if err != nil {
+		LogError(fn, "check lockout", err)
+		return false, InternalServerError(c, "error while authorizing")
+	} else if wait > 0 {
+		return false, TooManyRequests(c, "too many failed login attempts, try again later", wait)
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
+	if err != nil {
+		if !errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			LogError(fn, "check password", err)
+			return false, InternalServerError(c, "error while authorizing")
+		}
+		if wait = a.recordLoginFailure(ctx, c.IP(), user.Username); wait > 0 {
+			return false, TooManyRequests(c, "too many failed login attempts, try again later", wait)
+		}
+		return false, BadRequest(c, "incorrect password")
+	}
+	return true, nil
+}
+
+// ChangePassword changes the password of the user, then revokes every other session of the user.
+// returns a 400 if the current password is incorrect or the new password is invalid.
+// returns a 429 if the account is locked out; see Login.
+// returns a 200 if the password is changed.
+func (a *Actions) ChangePassword(c *fiber.Ctx, currentPassword, newPassword string) error {
+	session := c.Locals("session").(*ent.Session)
+	ctx := c.Context()
+
+	if err := db.ValidatePassword(newPassword); err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	user, err := a.Client.User.Get(ctx, session.UserID)
+	if err != nil {
+		LogError("ChangePassword", "check user", err)
+		return InternalServerError(c, "error getting account")
+	}
+	if ok, err := a.confirmPassword(c, "ChangePassword", user, currentPassword); !ok {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), 10)
+	if err != nil {
+		LogError("ChangePassword", "hash password", err)
+		return InternalServerError(c, "an error occurred")
+	}
+
+	tx, err := a.Client.Tx(ctx)
+	if err != nil {
+		LogError("ChangePassword", "begin transaction", err)
+		return InternalServerError(c, "error changing password")
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	err = tx.User.
+		UpdateOneID(user.ID).
+		SetPassword(string(hashedPassword)).
+		Exec(ctx)
+	if err != nil {
+		LogError("ChangePassword", "update password", err)
+		return InternalServerError(c, "error changing password")
+	}
+
+	// whoever knew the old password shouldn't stay logged in, nor be able to reset it.
+	_, err = tx.Session.
+		Delete().
+		Where(
+			Session.UserIDEQ(user.ID),
+			Session.IDNEQ(session.ID),
+		).
+		Exec(ctx)
+	if err != nil {
+		LogError("ChangePassword", "revoke sessions", err)
+		return InternalServerError(c, "error changing password")
+	}
+
+	_, err = tx.PasswordReset.
+		Delete().
+		Where(PasswordReset.UserIDEQ(user.ID)).
+		Exec(ctx)
+	if err != nil {
+		LogError("ChangePassword", "delete resets", err)
+		return InternalServerError(c, "error changing password")
+	}
+
+	if err = tx.Commit(); err != nil {
+		LogError("ChangePassword", "commit", err)
+		return InternalServerError(c, "error changing password")
+	}
+
+	// a change of privileges rotates the Csrf token of the session.
+	if err = a.rotateCSRF(c, session); err != nil {
+		LogError("ChangePassword", "rotate csrf", err)
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"acknowledged": true,
+		"message":      "password changed, other sessions were logged out",
+	})
+}
+
+// ChangeEmail emails a verification link to a new email; the email of the user only changes once it is verified.
+// the current email is notified, in case the change wasn't asked for by the user.
+// returns a 400 if the password is incorrect, or the email is invalid, unchanged or already taken.
+// returns a 429 if the account is locked out; see Login.
+// returns a 200 if the verification email is sent.
+func (a *Actions) ChangeEmail(c *fiber.Ctx, email, password string) error {
+	session := c.Locals("session").(*ent.Session)
+	ctx := c.Context()
+
+	if err := db.ValidateEmail(email); err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	user, err := a.Client.User.Get(ctx, session.UserID)
+	if err != nil {
+		LogError("ChangeEmail", "check user", err)
+		return InternalServerError(c, "error getting account")
+	}
+	if email == user.Email {
+		return BadRequest(c, "email unchanged")
+	}
+	if ok, err := a.confirmPassword(c, "ChangeEmail", user, password); !ok {
+		return err
+	}
+
+	exists, err := a.Client.User.
+		Query().
+		Where(User.EmailEQ(email)).
+		Exist(ctx)
+	if err != nil {
+		LogError("ChangeEmail", "email check", err)
+		return InternalServerError(c, "error checking email")
+	} else if exists {
+		return BadRequest(c, "email already exists")
+	}
+
+	if err = a.sendVerification(ctx, user, email); err != nil {
+		LogError("ChangeEmail", "send verification", err)
+		return InternalServerError(c, "error sending verification email")
+	}
+
+	msg := mail.Message{
+		To:      user.Email,
+		Subject: "Your Groove email is being changed",
+		Body: "Hi " + user.Username + ",\n\n" +
+			"Someone (hopefully you) asked to change the email of your Groove account to " + email + ".\n" +
+			"It will change once the new email is verified.\n\n" +
+			"If you didn't ask for this, change your password; the change can't complete without access to the new email.\n",
+	}
+	go func() {
+		if err := a.Mail.Send(context.Background(), msg); err != nil {
+			LogError("ChangeEmail", "send notice email", err)
+		}
+	}()
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"acknowledged": true,
+		"message":      "verification email sent to " + email + ", your email changes once it is verified",
+	})
+}
+
+// ChangeUsername changes the username of the user.
+// returns a 400 if the username is invalid, unchanged or already taken.
+// returns a 200 if the username is changed.
+func (a *Actions) ChangeUsername(c *fiber.Ctx, username string) error {
+	session := c.Locals("session").(*ent.Session)
+	ctx := c.Context()
+
+	if err := db.ValidateUsername(username); err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	exists, err := a.Client.User.
+		Query().
+		Where(User.UsernameEQ(username)).
+		Exist(ctx)
+	if err != nil {
+		LogError("ChangeUsername", "username check", err)
+		return InternalServerError(c, "error checking username")
+	} else if exists {
+		return BadRequest(c, "username already exists")
+	}
+
+	err = a.Client.User.
+		UpdateOneID(session.UserID).
+		SetUsername(username).
+		Exec(ctx)
+	if err != nil {
+		if ent.IsConstraintError(err) {
+			// taken by a concurrent request.
+			return BadRequest(c, "username already exists")
+		}
+		LogError("ChangeUsername", "update user", err)
+		return InternalServerError(c, "error changing username")
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"acknowledged": true,
+		"message":      "username changed to " + username,
+	})
+}
+
+// DeleteAccount permanently deletes the user, along with everything referencing it (sessions, spotify link, ...)
+// through the cascades of its edges; then clears the Authorization cookie.
+// Spotify has no endpoint to revoke a refresh token, so the link's tokens are destroyed with it instead;
+// the response points to where the user can remove Groove's access from their Spotify account.
+// returns a 400 if the password is incorrect.
+// returns a 429 if the account is locked out; see Login.
+// returns a 200 if the account is deleted.
+func (a *Actions) DeleteAccount(c *fiber.Ctx, password string) error {
+	session := c.Locals("session").(*ent.Session)
+	ctx := c.Context()
+
+	user, err := a.Client.User.
+		Query().
+		Where(User.IDEQ(session.UserID)).
+		WithSpotifyLink().
+		First(ctx)
+	if err != nil {
+		LogError("DeleteAccount", "check user", err)
+		return InternalServerError(c, "error getting account")
+	}
+	if ok, err := a.confirmPassword(c, "DeleteAccount", user, password); !ok {
+		return err
+	}
+
+	if err = a.Client.User.DeleteOneID(user.ID).Exec(ctx); err != nil {
+		LogError("DeleteAccount", "delete user", err)
+		return InternalServerError(c, "error deleting account")
+	}
+
+	ExpireSessionCookies(c, a.Env.SameSite, a.Env.Secure)
+	response := fiber.Map{
+		"acknowledged": true,
+		"message":      "account deleted",
+	}
+	if user.Edges.SpotifyLink != nil {
+		response["spotify_apps_url"] = spotifyAppsURL
+	}
+	return c.Status(http.StatusOK).JSON(response)
+}
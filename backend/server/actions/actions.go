@@ -1,11 +1,54 @@
 package actions
 
 import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"groove/pkgs/cache"
+	"groove/pkgs/db"
 	"groove/pkgs/ent"
 	"groove/pkgs/env"
+	"groove/pkgs/mail"
+	"groove/pkgs/spotify"
+	. "groove/pkgs/util"
+	"net/http"
 )
 
 type Actions struct {
-	Client *ent.Client
-	Env    *env.Env
+	Client    *ent.Client
+	Env       *env.Env
+	Spotify   *spotify.Client
+	Cache     *cache.Cache
+	Mail      mail.Sender
+	Throttles *db.Throttles
+}
+
+// Failures maps a Spotify error status to the message sent back to the client.
+type Failures map[int]string
+
+// SpotifyError responds to an error returned by the spotify client.
+// statuses found in failures are returned as bad requests with their message (404 stays not found);
+// rate limits and outages that outlasted the client's retries are returned as 429 and 503 with a retry hint;
+// any other error is logged and returned as an internal server error.
+func SpotifyError(c *fiber.Ctx, fn string, err error, failures Failures) error {
+	var spotifyErr *spotify.Error
+	if errors.As(err, &spotifyErr) {
+		switch {
+		case spotifyErr.Status == http.StatusTooManyRequests:
+			return TooManyRequests(c, "spotify rate limit reached, try again later", spotifyErr.RetryAfter)
+		case spotifyErr.Status >= http.StatusInternalServerError:
+			LogError(fn, "Requesting "+c.Path(), err)
+			return ServiceUnavailable(c, "spotify is unavailable, try again later", spotifyErr.RetryAfter)
+		}
+	}
+
+	status := spotify.StatusOf(err)
+	if msg, ok := failures[status]; ok {
+		if status == http.StatusNotFound {
+			return BadRequest(c, msg, http.StatusNotFound)
+		}
+		return BadRequest(c, msg)
+	}
+
+	LogError(fn, "Requesting "+c.Path(), err)
+	return InternalServerError(c, "error requesting "+c.Path())
 }
@@ -0,0 +1,351 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"groove/pkgs/ent"
+	AccessToken "groove/pkgs/ent/accesstoken"
+	LoginChallenge "groove/pkgs/ent/loginchallenge"
+	Session "groove/pkgs/ent/session"
+	SpotifyLink "groove/pkgs/ent/spotifylink"
+	User "groove/pkgs/ent/user"
+	. "groove/pkgs/util"
+	"net/http"
+	"time"
+)
+
+// adminPageSize is how many users are listed per page by the admin API.
+const adminPageSize = 50
+
+// ListUsers sends a page of users, optionally searched by username or email (case-insensitive).
+// returns a 200 with the users and the total matching the search.
+func (a *Actions) ListUsers(c *fiber.Ctx, search string, page int) error {
+	ctx := c.Context()
+
+	query := a.Client.User.Query()
+	if search != "" {
+		query.Where(User.Or(
+			User.UsernameContainsFold(search),
+			User.EmailContainsFold(search),
+		))
+	}
+
+	total, err := query.Clone().Count(ctx)
+	if err != nil {
+		LogError("ListUsers", "count users", err)
+		return InternalServerError(c, "error getting users")
+	}
+
+	users, err := query.
+		Order(ent.Asc(User.FieldID)).
+		Offset((page - 1) * adminPageSize).
+		Limit(adminPageSize).
+		All(ctx)
+	if err != nil {
+		LogError("ListUsers", "query users", err)
+		return InternalServerError(c, "error getting users")
+	}
+
+	response := make([]fiber.Map, len(users))
+	for i, user := range users {
+		response[i] = adminUserResponse(user)
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"users":     response,
+		"total":     total,
+		"page":      page,
+		"page_size": adminPageSize,
+	})
+}
+
+// GetUser sends a user, along with counts of what they use: sessions, access tokens and their spotify link.
+// returns a 404 if the user does not exist.
+// returns a 200 with the user.
+func (a *Actions) GetUser(c *fiber.Ctx, id int) error {
+	ctx := c.Context()
+	now := time.Now()
+
+	user, err := a.Client.User.
+		Query().
+		Where(User.IDEQ(id)).
+		WithSpotifyLink().
+		WithDataExport().
+		WithSession(func(q *ent.SessionQuery) {
+			q.Where(Session.ExpirationGT(now))
+		}).
+		WithAccessToken(func(q *ent.AccessTokenQuery) {
+			q.Where(AccessToken.Or(
+				AccessToken.ExpirationIsNil(),
+				AccessToken.ExpirationGT(now),
+			))
+		}).
+		First(ctx)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return BadRequest(c, "user not found", http.StatusNotFound)
+		}
+		LogError("GetUser", "check user", err)
+		return InternalServerError(c, "error getting user")
+	}
+
+	recoveryCodes, err := user.QueryRecoveryCode().Count(ctx)
+	if err != nil {
+		LogError("GetUser", "count recovery codes", err)
+		return InternalServerError(c, "error getting user")
+	}
+
+	var lastSeen, lastUsed *time.Time
+	for _, s := range user.Edges.Session {
+		if lastSeen == nil || s.LastSeen.After(*lastSeen) {
+			lastSeen = &s.LastSeen
+		}
+	}
+	for _, t := range user.Edges.AccessToken {
+		if t.LastUsed != nil && (lastUsed == nil || t.LastUsed.After(*lastUsed)) {
+			lastUsed = t.LastUsed
+		}
+	}
+
+	usage := fiber.Map{
+		"sessions":                 len(user.Edges.Session),
+		"last_seen":                lastSeen,
+		"access_tokens":            len(user.Edges.AccessToken),
+		"access_token_last_used":   lastUsed,
+		"recovery_codes_remaining": recoveryCodes,
+		"spotify_linked":           user.Edges.SpotifyLink != nil && !user.Edges.SpotifyLink.Revoked,
+		"spotify_revoked":          user.Edges.SpotifyLink != nil && user.Edges.SpotifyLink.Revoked,
+		"data_export":              nil,
+	}
+	if export := user.Edges.DataExport; export != nil {
+		usage["data_export"] = fiber.Map{"status": export.Status, "created_at": export.CreatedAt}
+	}
+
+	response := adminUserResponse(user)
+	response["usage"] = usage
+	return c.Status(http.StatusOK).JSON(fiber.Map{"user": response})
+}
+
+// DisableUser disables a user, who is logged out and cannot log in (nor use their access tokens) until enabled again.
+// returns a 400 if the user is the admin themselves or is already disabled.
+// returns a 404 if the user does not exist.
+// returns a 200 if the user is disabled.
+func (a *Actions) DisableUser(c *fiber.Ctx, id int) error {
+	session := c.Locals("session").(*ent.Session)
+	ctx := c.Context()
+
+	if id == session.UserID {
+		return BadRequest(c, "cannot disable your own account")
+	}
+
+	user, err := a.Client.User.Get(ctx, id)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return BadRequest(c, "user not found", http.StatusNotFound)
+		}
+		LogError("DisableUser", "check user", err)
+		return InternalServerError(c, "error getting user")
+	} else if user.DisabledAt != nil {
+		return BadRequest(c, "user already disabled")
+	}
+
+	tx, err := a.Client.Tx(ctx)
+	if err != nil {
+		LogError("DisableUser", "begin transaction", err)
+		return InternalServerError(c, "error disabling user")
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	err = tx.User.
+		UpdateOneID(id).
+		SetDisabledAt(time.Now()).
+		Exec(ctx)
+	if err != nil {
+		LogError("DisableUser", "update user", err)
+		return InternalServerError(c, "error disabling user")
+	}
+
+	if _, err = endSessions(ctx, tx, id); err != nil {
+		LogError("DisableUser", "end sessions", err)
+		return InternalServerError(c, "error disabling user")
+	}
+
+	if err = tx.Commit(); err != nil {
+		LogError("DisableUser", "commit", err)
+		return InternalServerError(c, "error disabling user")
+	}
+
+	logAdmin(session, "disabled", id)
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"acknowledged": true,
+		"message":      "user " + user.Username + " disabled",
+	})
+}
+
+// EnableUser enables a disabled user again.
+// returns a 400 if the user is not disabled.
+// returns a 404 if the user does not exist.
+// returns a 200 if the user is enabled.
+func (a *Actions) EnableUser(c *fiber.Ctx, id int) error {
+	session := c.Locals("session").(*ent.Session)
+	ctx := c.Context()
+
+	user, err := a.Client.User.Get(ctx, id)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return BadRequest(c, "user not found", http.StatusNotFound)
+		}
+		LogError("EnableUser", "check user", err)
+		return InternalServerError(c, "error getting user")
+	} else if user.DisabledAt == nil {
+		return BadRequest(c, "user not disabled")
+	}
+
+	if err = a.Client.User.UpdateOneID(id).ClearDisabledAt().Exec(ctx); err != nil {
+		LogError("EnableUser", "update user", err)
+		return InternalServerError(c, "error enabling user")
+	}
+
+	logAdmin(session, "enabled", id)
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"acknowledged": true,
+		"message":      "user " + user.Username + " enabled",
+	})
+}
+
+// LogoutUser logs a user out of every session, including logins waiting on two-factor authentication.
+// returns a 404 if the user does not exist.
+// returns a 200 with how many sessions were ended.
+func (a *Actions) LogoutUser(c *fiber.Ctx, id int) error {
+	session := c.Locals("session").(*ent.Session)
+	ctx := c.Context()
+
+	exists, err := a.Client.User.Query().Where(User.IDEQ(id)).Exist(ctx)
+	if err != nil {
+		LogError("LogoutUser", "check user", err)
+		return InternalServerError(c, "error getting user")
+	} else if !exists {
+		return BadRequest(c, "user not found", http.StatusNotFound)
+	}
+
+	tx, err := a.Client.Tx(ctx)
+	if err != nil {
+		LogError("LogoutUser", "begin transaction", err)
+		return InternalServerError(c, "error logging user out")
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	affected, err := endSessions(ctx, tx, id)
+	if err != nil {
+		LogError("LogoutUser", "end sessions", err)
+		return InternalServerError(c, "error logging user out")
+	}
+
+	if err = tx.Commit(); err != nil {
+		LogError("LogoutUser", "commit", err)
+		return InternalServerError(c, "error logging user out")
+	}
+
+	logAdmin(session, "logged out", id)
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"acknowledged": true,
+		"sessions":     affected,
+	})
+}
+
+// UnlinkUserSpotify deletes the spotify link of a user, i.e. when their tokens are being misused.
+// accounts with a password are also detached from their spotify user; accounts without one keep it,
+// as logging in with Spotify is their only way in (which links Spotify again).
+// returns a 404 if the user does not exist or is not linked.
+// returns a 200 if the link is deleted.
+func (a *Actions) UnlinkUserSpotify(c *fiber.Ctx, id int) error {
+	session := c.Locals("session").(*ent.Session)
+	ctx := c.Context()
+
+	user, err := a.Client.User.Get(ctx, id)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return BadRequest(c, "user not found", http.StatusNotFound)
+		}
+		LogError("UnlinkUserSpotify", "check user", err)
+		return InternalServerError(c, "error getting user")
+	}
+
+	tx, err := a.Client.Tx(ctx)
+	if err != nil {
+		LogError("UnlinkUserSpotify", "begin transaction", err)
+		return InternalServerError(c, "error unlinking spotify")
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	affected, err := tx.SpotifyLink.
+		Delete().
+		Where(SpotifyLink.UserIDEQ(id)).
+		Exec(ctx)
+	if err != nil {
+		LogError("UnlinkUserSpotify", "delete link", err)
+		return InternalServerError(c, "error unlinking spotify")
+	} else if affected == 0 {
+		return BadRequest(c, "user not linked to spotify", http.StatusNotFound)
+	}
+
+	if user.Password != "" {
+		if err = tx.User.UpdateOneID(id).ClearSpotifyID().Exec(ctx); err != nil {
+			LogError("UnlinkUserSpotify", "update user", err)
+			return InternalServerError(c, "error unlinking spotify")
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		LogError("UnlinkUserSpotify", "commit", err)
+		return InternalServerError(c, "error unlinking spotify")
+	}
+
+	logAdmin(session, "unlinked spotify of", id)
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"acknowledged": true,
+		"message":      "spotify unlinked from user " + user.Username,
+	})
+}
+
+// endSessions deletes every session of a user, and their logins waiting on two-factor authentication.
+func endSessions(ctx context.Context, tx *ent.Tx, userID int) (int, error) {
+	affected, err := tx.Session.
+		Delete().
+		Where(Session.UserIDEQ(userID)).
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.LoginChallenge.
+		Delete().
+		Where(LoginChallenge.UserIDEQ(userID)).
+		Exec(ctx)
+	return affected, err
+}
+
+// logAdmin prints what an admin did to a user, so it can be traced back later.
+func logAdmin(session *ent.Session, action string, userID int) {
+	fmt.Printf(
+		"%s [ADMIN] User %d %s user %d\n",
+		time.Now().Format("15:04:05"),
+		session.UserID, action, userID,
+	)
+}
+
+// adminUserResponse is a user, as sent to admins.
+func adminUserResponse(user *ent.User) fiber.Map {
+	return fiber.Map{
+		"id":             user.ID,
+		"username":       user.Username,
+		"email":          user.Email,
+		"email_verified": user.EmailVerified,
+		"role":           user.Role,
+		"disabled_at":    user.DisabledAt,
+		"has_password":   user.Password != "",
+		"two_factor":     user.TotpEnabled,
+		"spotify_id":     user.SpotifyID,
+	}
+}
@@ -2,23 +2,34 @@ package actions
 
 import (
 	"github.com/gofiber/fiber/v2"
-	. "groove/pkgs/util"
+	"groove/pkgs/cache"
+	"groove/pkgs/spotify"
+	"net/http"
 )
 
+var albumFailures = Failures{
+	http.StatusBadRequest: "invalid album-id",
+	http.StatusNotFound:   "album not found",
+}
+
 // GetAlbum returns the album with the given id.
-func (*Actions) GetAlbum(c *fiber.Ctx, albumID string) error {
-	proxy := &Proxy{
-		Endpoint: "/albums/" + albumID,
-		Access:   c.Locals("access").(string),
-	}
-	return proxy.AlbumRequest(c)
+func (a *Actions) GetAlbum(c *fiber.Ctx, albumID string) error {
+	access := c.Locals("access").(string)
+
+	return a.catalog(c, "GetAlbum", cache.Key("/albums/"+albumID, ""), albumFailures, func() (any, error) {
+		return a.Spotify.GetAlbum(c.Context(), access, albumID, "")
+	})
 }
 
 // GetAlbumTracks returns the tracks of the album with the given id.
-func (*Actions) GetAlbumTracks(c *fiber.Ctx, albumID string) error {
-	proxy := &Proxy{
-		Endpoint: "/albums/" + albumID + "/tracks?limit=50&market=US",
-		Access:   c.Locals("access").(string),
-	}
-	return proxy.AlbumRequest(c)
+func (a *Actions) GetAlbumTracks(c *fiber.Ctx, albumID string) error {
+	access := c.Locals("access").(string)
+
+	return a.catalog(c, "GetAlbumTracks", cache.Key("/albums/"+albumID+"/tracks", "US"), albumFailures, func() (any, error) {
+		return a.Spotify.GetAlbumTracks(c.Context(), access, spotify.AlbumTracksRequest{
+			AlbumID: albumID,
+			Market:  "US",
+			Limit:   50,
+		})
+	})
 }
@@ -2,41 +2,53 @@ package actions
 
 import (
 	"github.com/gofiber/fiber/v2"
-	. "groove/pkgs/util"
+	"groove/pkgs/cache"
+	"groove/pkgs/spotify"
+	"net/http"
 )
 
+var artistFailures = Failures{
+	http.StatusBadRequest: "invalid artist-id",
+	http.StatusNotFound:   "artist not found",
+}
+
 // GetArtist returns the artist with the given id.
-func (*Actions) GetArtist(c *fiber.Ctx, artistID string) error {
-	proxy := &Proxy{
-		Endpoint: "/artists/" + artistID,
-		Access:   c.Locals("access").(string),
-	}
-	return proxy.ArtistRequest(c)
+func (a *Actions) GetArtist(c *fiber.Ctx, artistID string) error {
+	access := c.Locals("access").(string)
+
+	return a.catalog(c, "GetArtist", cache.Key("/artists/"+artistID, ""), artistFailures, func() (any, error) {
+		return a.Spotify.GetArtist(c.Context(), access, artistID)
+	})
 }
 
 // GetRelatedArtists returns the artists related to the artist with the given id.
-func (*Actions) GetRelatedArtists(c *fiber.Ctx, artistID string) error {
-	proxy := &Proxy{
-		Endpoint: "/artists/" + artistID + "/related-artists",
-		Access:   c.Locals("access").(string),
-	}
-	return proxy.ArtistRequest(c)
+func (a *Actions) GetRelatedArtists(c *fiber.Ctx, artistID string) error {
+	access := c.Locals("access").(string)
+
+	return a.catalog(c, "GetRelatedArtists", cache.Key("/artists/"+artistID+"/related-artists", ""), artistFailures, func() (any, error) {
+		return a.Spotify.GetRelatedArtists(c.Context(), access, artistID)
+	})
 }
 
 // GetArtistTopTracks returns the top tracks of the artist with the given id.
-func (*Actions) GetArtistTopTracks(c *fiber.Ctx, artistID string) error {
-	proxy := &Proxy{
-		Endpoint: "/artists/" + artistID + "/top-tracks?market=US",
-		Access:   c.Locals("access").(string),
-	}
-	return proxy.ArtistRequest(c)
+func (a *Actions) GetArtistTopTracks(c *fiber.Ctx, artistID string) error {
+	access := c.Locals("access").(string)
+
+	return a.catalog(c, "GetArtistTopTracks", cache.Key("/artists/"+artistID+"/top-tracks", "US"), artistFailures, func() (any, error) {
+		return a.Spotify.GetArtistTopTracks(c.Context(), access, artistID, "US")
+	})
 }
 
 // GetArtistAlbums returns the albums of the artist with the given id.
-func (*Actions) GetArtistAlbums(c *fiber.Ctx, artistID string) error {
-	proxy := &Proxy{
-		Endpoint: "/artists/" + artistID + "/albums?market=US&limit=50&include_groups=album",
-		Access:   c.Locals("access").(string),
-	}
-	return proxy.ArtistRequest(c)
+func (a *Actions) GetArtistAlbums(c *fiber.Ctx, artistID string) error {
+	access := c.Locals("access").(string)
+
+	return a.catalog(c, "GetArtistAlbums", cache.Key("/artists/"+artistID+"/albums", "US"), artistFailures, func() (any, error) {
+		return a.Spotify.GetArtistAlbums(c.Context(), access, spotify.ArtistAlbumsRequest{
+			ArtistID:      artistID,
+			IncludeGroups: "album",
+			Market:        "US",
+			Limit:         50,
+		})
+	})
 }
@@ -0,0 +1,51 @@
+package actions
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	. "groove/pkgs/util"
+	"net/http"
+	"time"
+)
+
+const (
+	// catalogTTL is how long Spotify catalog objects are cached server-side.
+	catalogTTL = 12 * time.Hour
+	// catalogCacheControl lets the browser reuse catalog responses for an hour.
+	// (private since catalog endpoints require a session)
+	catalogCacheControl = "private, max-age=3600"
+)
+
+// catalog responds with a Spotify catalog object, serving it from the cache when possible.
+// fetch is only called on a cache miss, its result is then cached under key for catalogTTL.
+// responses carry an ETag; returns 304 if the client already has the current version.
+func (a *Actions) catalog(c *fiber.Ctx, fn, key string, failures Failures, fetch func() (any, error)) error {
+	ctx := c.Context()
+
+	body, ok := a.Cache.Get(ctx, key)
+	if !ok {
+		result, err := fetch()
+		if err != nil {
+			return SpotifyError(c, fn, err, failures)
+		}
+
+		body, err = json.Marshal(result)
+		if err != nil {
+			LogError(fn, "Marshalling "+key, err)
+			return InternalServerError(c, "error requesting "+c.Path())
+		}
+		a.Cache.Set(ctx, key, body, catalogTTL)
+	}
+
+	sum := sha1.Sum(body)
+	c.Set(fiber.HeaderETag, `"`+hex.EncodeToString(sum[:])+`"`)
+	c.Set(fiber.HeaderCacheControl, catalogCacheControl)
+	if c.Fresh() {
+		return c.SendStatus(http.StatusNotModified)
+	}
+
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	return c.Status(http.StatusOK).Send(body)
+}
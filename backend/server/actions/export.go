@@ -0,0 +1,145 @@
+package actions
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"groove/pkgs/ent"
+	DataExport "groove/pkgs/ent/dataexport"
+	"groove/pkgs/secret"
+	. "groove/pkgs/util"
+	"net/http"
+	"time"
+)
+
+// exportCooldown is how long a user waits between two data exports.
+const exportCooldown = time.Hour
+
+// RequestExport starts an export of the data of the user, replacing any previous export.
+// the export is built in the background by the scheduler, which emails a download link once it is ready.
+// returns a 400 if an export is already in progress.
+// returns a 429 if the last export was requested within the hour.
+// returns a 202 if the export is started.
+func (a *Actions) RequestExport(c *fiber.Ctx) error {
+	session := c.Locals("session").(*ent.Session)
+	ctx := c.Context()
+
+	previous, err := a.Client.DataExport.
+		Query().
+		Where(DataExport.UserIDEQ(session.UserID)).
+		First(ctx)
+	if err != nil && !ent.IsNotFound(err) {
+		LogError("RequestExport", "check export", err)
+		return InternalServerError(c, "error checking export")
+	}
+
+	if previous != nil {
+		switch {
+		case previous.Status == DataExport.StatusPending || previous.Status == DataExport.StatusRunning:
+			return BadRequest(c, "an export is already in progress")
+		case previous.Status == DataExport.StatusReady && time.Since(previous.CreatedAt) < exportCooldown:
+			return TooManyRequests(c, "an export was requested recently, try again later", exportCooldown-time.Since(previous.CreatedAt))
+		}
+	}
+
+	tx, err := a.Client.Tx(ctx)
+	if err != nil {
+		LogError("RequestExport", "begin transaction", err)
+		return InternalServerError(c, "error starting export")
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.DataExport.
+		Delete().
+		Where(DataExport.UserIDEQ(session.UserID)).
+		Exec(ctx)
+	if err != nil {
+		LogError("RequestExport", "delete previous export", err)
+		return InternalServerError(c, "error starting export")
+	}
+
+	export, err := tx.DataExport.Create().
+		SetUserID(session.UserID).
+		Save(ctx)
+	if err != nil {
+		LogError("RequestExport", "create export", err)
+		return InternalServerError(c, "error starting export")
+	}
+
+	if err = tx.Commit(); err != nil {
+		LogError("RequestExport", "commit", err)
+		return InternalServerError(c, "error starting export")
+	}
+
+	return c.Status(http.StatusAccepted).JSON(fiber.Map{
+		"acknowledged": true,
+		"message":      "export started, a download link will be emailed to you once it is ready",
+		"export":       exportStatus(export),
+	})
+}
+
+// GetExport sends the status of the last data export of the user.
+// returns a 404 if the user has no export.
+// returns a 200 with the status of the export.
+func (a *Actions) GetExport(c *fiber.Ctx) error {
+	session := c.Locals("session").(*ent.Session)
+	ctx := c.Context()
+
+	export, err := a.Client.DataExport.
+		Query().
+		Where(DataExport.UserIDEQ(session.UserID)).
+		Select(
+			DataExport.FieldStatus,
+			DataExport.FieldCreatedAt,
+			DataExport.FieldExpiration,
+		).
+		First(ctx)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return BadRequest(c, "no export requested", http.StatusNotFound)
+		}
+		LogError("GetExport", "check export", err)
+		return InternalServerError(c, "error checking export")
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{"export": exportStatus(export)})
+}
+
+// DownloadExport sends the bundle of a data export, using the token of its download link.
+// the link can be used until the export expires.
+// returns a 404 if the token is invalid or the export expired.
+// returns a 200 with the bundle, as a zip file.
+func (a *Actions) DownloadExport(c *fiber.Ctx, token string) error {
+	ctx := c.Context()
+
+	export, err := a.Client.DataExport.
+		Query().
+		Where(
+			DataExport.TokenHashEQ(secret.HashToken(token)),
+			DataExport.StatusEQ(DataExport.StatusReady),
+			DataExport.ExpirationGT(time.Now()),
+		).
+		First(ctx)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return BadRequest(c, "invalid or expired download link", http.StatusNotFound)
+		}
+		LogError("DownloadExport", "check export", err)
+		return InternalServerError(c, "error getting export")
+	}
+
+	c.Set(fiber.HeaderContentType, "application/zip")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="groove-export-`+export.CreatedAt.Format("2006-01-02")+`.zip"`)
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Status(http.StatusOK).Send(export.Bundle)
+}
+
+// exportStatus is the status of a data export, as sent to the user.
+func exportStatus(export *ent.DataExport) fiber.Map {
+	status := fiber.Map{
+		"status":     export.Status,
+		"created_at": export.CreatedAt,
+	}
+	if export.Status == DataExport.StatusReady {
+		status["expiration"] = export.Expiration
+	}
+	return status
+}
@@ -0,0 +1,179 @@
+package actions
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"groove/pkgs/spotify"
+	. "groove/pkgs/util"
+	"net/http"
+	"strconv"
+)
+
+const (
+	// headerNextCursor holds the continuation cursor of an NDJSON response, whose body has no room for it.
+	headerNextCursor = "X-Next-Cursor"
+	headerTotalCount = "X-Total-Count"
+	headerSnapshotID = "X-Snapshot-Id"
+)
+
+// Cursor is where a paginated response continues; it is handed out as an opaque token.
+type Cursor struct {
+	Offset int `json:"o"`
+	// SnapshotID is the version of the playlist the offset refers to, if the items are those of a playlist.
+	SnapshotID string `json:"s,omitempty"`
+}
+
+// Encode returns the token of the cursor.
+func (cur Cursor) Encode() string {
+	raw, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// DecodeCursor parses a token returned by Cursor.Encode; an empty token is the start of the items.
+func DecodeCursor(token string) (Cursor, error) {
+	var cur Cursor
+	if token == "" {
+		return cur, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return cur, errors.New("invalid cursor")
+	}
+	if err = json.Unmarshal(raw, &cur); err != nil || cur.Offset < 0 {
+		return cur, errors.New("invalid cursor")
+	}
+	return cur, nil
+}
+
+// Aggregate is how a paginated response is requested.
+type Aggregate struct {
+	Cursor Cursor
+	// Limit is the most items returned; 0 returns every item.
+	Limit int
+	// NDJSON streams the items one per line instead of responding with a single JSON object.
+	NDJSON bool
+}
+
+// respondAggregate responds with the items of pages (created at the cursor's offset) as described by agg.
+// a JSON response holds the items, their total and the cursor of the next items (null once all were returned);
+// an NDJSON response holds one item per line and sets the X-Next-Cursor and X-Total-Count headers.
+// the first page is fetched before responding so that failures are mapped as usual;
+// a failure midway through a stream can only end it early, which the client detects with X-Total-Count.
+func respondAggregate[T any](c *fiber.Ctx, fn string, pages *spotify.Paginator[T], agg Aggregate, failures Failures, fields fiber.Map) error {
+	ctx := c.Context()
+	if agg.Limit > 0 {
+		pages.Limit(agg.Limit)
+	}
+
+	first, err := pages.Next(ctx)
+	if err != nil {
+		return SpotifyError(c, fn, err, failures)
+	}
+
+	var next *string
+	if agg.Limit > 0 && agg.Cursor.Offset+agg.Limit < pages.Total() {
+		token := Cursor{Offset: agg.Cursor.Offset + agg.Limit, SnapshotID: agg.Cursor.SnapshotID}.Encode()
+		next = &token
+	}
+
+	if !agg.NDJSON {
+		items := first
+		for !pages.Done() {
+			page, err := pages.Next(ctx)
+			if err != nil {
+				return SpotifyError(c, fn, err, failures)
+			}
+			items = append(items, page...)
+		}
+		if items == nil {
+			items = []T{}
+		}
+
+		response := fiber.Map{
+			"items": items,
+			"total": pages.Total(),
+			"next":  next,
+		}
+		for key, value := range fields {
+			response[key] = value
+		}
+		return c.Status(http.StatusOK).JSON(response)
+	}
+
+	if next != nil {
+		c.Set(headerNextCursor, *next)
+	}
+	c.Set(headerTotalCount, strconv.Itoa(pages.Total()))
+	c.Set(fiber.HeaderContentType, "application/x-ndjson")
+	c.Status(http.StatusOK).Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		// the request's context ends with the handler; the stream ends when the client goes away instead.
+		ctx := context.Background()
+		page := first
+		for {
+			for _, item := range page {
+				line, err := json.Marshal(item)
+				if err != nil {
+					LogError(fn, "encode item", err)
+					return
+				}
+				_, _ = w.Write(append(line, '\n'))
+			}
+			if err := w.Flush(); err != nil || pages.Done() {
+				return
+			}
+
+			var err error
+			if page, err = pages.Next(ctx); err != nil {
+				LogError(fn, "stream page", err)
+				return
+			}
+		}
+	})
+	return nil
+}
+
+// GetAllPlaylistTracks returns every track of a playlist as described by agg; see respondAggregate.
+// the playlist's snapshot id is part of the cursors, so pages of different versions of it are never mixed.
+// returns 200 on success.
+// returns 409 if the playlist was modified since the cursor was handed out.
+// returns 404 if the playlist is not found.
+// returns 400 if the playlist-id is invalid.
+func (a *Actions) GetAllPlaylistTracks(c *fiber.Ctx, playlistID string, agg Aggregate) error {
+	access := c.Locals("access").(string)
+
+	current, err := a.Spotify.GetPlaylistSnapshot(c.Context(), access, playlistID)
+	if err != nil {
+		return SpotifyError(c, "GetAllPlaylistTracks", err, playlistFailures)
+	}
+	if agg.Cursor.SnapshotID != "" && agg.Cursor.SnapshotID != current.SnapshotID {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error":       "conflict",
+			"message":     "playlist was modified since the cursor was handed out, start over",
+			"snapshot_id": current.SnapshotID,
+		})
+	}
+	agg.Cursor.SnapshotID = current.SnapshotID
+
+	pages := a.Spotify.PaginatePlaylistTracks(access, playlistID, "US", agg.Cursor.Offset)
+	if agg.NDJSON {
+		c.Set(headerSnapshotID, current.SnapshotID)
+	}
+	return respondAggregate(c, "GetAllPlaylistTracks", pages, agg, playlistFailures, fiber.Map{
+		"snapshot_id": current.SnapshotID,
+	})
+}
+
+// GetMyPlaylists returns every playlist owned or followed by the user, past the 50 of GetAllPlaylists,
+// as described by agg; see respondAggregate.
+// returns 200 on success.
+func (a *Actions) GetMyPlaylists(c *fiber.Ctx, agg Aggregate) error {
+	access := c.Locals("access").(string)
+
+	pages := a.Spotify.PaginateMyPlaylists(access, agg.Cursor.Offset)
+	return respondAggregate(c, "GetMyPlaylists", pages, agg, myPlaylistsFailures, nil)
+}
@@ -0,0 +1,239 @@
+package actions
+
+import (
+	"context"
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+	"groove/pkgs/db"
+	"groove/pkgs/ent"
+	PasswordReset "groove/pkgs/ent/passwordreset"
+	Session "groove/pkgs/ent/session"
+	User "groove/pkgs/ent/user"
+	"groove/pkgs/mail"
+	"groove/pkgs/secret"
+	. "groove/pkgs/util"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// passwordResetTTL is how long a password reset link stays valid.
+const passwordResetTTL = 30 * time.Minute
+
+// ForgotPassword emails a single-use password reset link to the user with the given email.
+// returns a 200 with the same message whether or not the email has an account, so it cannot be used to find out
+// which emails have one.
+// returns a 429 if too many links were requested for the email, or from the IP.
+func (a *Actions) ForgotPassword(c *fiber.Ctx, email string) error {
+	ctx := c.Context()
+	response := fiber.Map{
+		"acknowledged": true,
+		"message":      "if an account with this email exists, a reset link was sent to it",
+	}
+
+	wait, err := a.locked(ctx, forgotIPKey(c.IP()), forgotEmailKey(email))
+	if err != nil {
+		LogError("ForgotPassword", "check lockout", err)
+		return InternalServerError(c, "error resetting password")
+	} else if wait > 0 {
+		return TooManyRequests(c, "too many reset links requested, try again later", wait)
+	}
+
+	// every request counts, whether or not the email has an account.
+	if _, err = a.Throttles.Record(ctx, forgotEmailKey(email), forgotEmailPolicy); err != nil {
+		LogError("ForgotPassword", "record email request", err)
+	}
+	if _, err = a.Throttles.Record(ctx, forgotIPKey(c.IP()), forgotIPPolicy); err != nil {
+		LogError("ForgotPassword", "record ip request", err)
+	}
+
+	user, err := a.Client.User.
+		Query().
+		Where(User.EmailEQ(email)).
+		First(ctx)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return c.Status(http.StatusOK).JSON(response)
+		}
+		LogError("ForgotPassword", "check user", err)
+		return InternalServerError(c, "error resetting password")
+	}
+
+	token, err := secret.NewToken()
+	if err != nil {
+		LogError("ForgotPassword", "generate token", err)
+		return InternalServerError(c, "error resetting password")
+	}
+
+	// a new link replaces any previous one.
+	_, err = a.Client.PasswordReset.
+		Delete().
+		Where(PasswordReset.UserIDEQ(user.ID)).
+		Exec(ctx)
+	if err != nil {
+		LogError("ForgotPassword", "delete previous resets", err)
+		return InternalServerError(c, "error resetting password")
+	}
+
+	_, err = a.Client.PasswordReset.Create().
+		SetTokenHash(secret.HashToken(token)).
+		SetExpiration(time.Now().Add(passwordResetTTL)).
+		SetUser(user).
+		Save(ctx)
+	if err != nil {
+		LogError("ForgotPassword", "create reset", err)
+		return InternalServerError(c, "error resetting password")
+	}
+
+	msg := mail.Message{
+		To:      user.Email,
+		Subject: "Reset your Groove password",
+		Body: "Hi " + user.Username + ",\n\n" +
+			"Someone (hopefully you) asked to reset the password of your Groove account.\n" +
+			"Follow this link within 30 minutes to choose a new password:\n\n" +
+			a.Env.AppURL() + "/reset-password?" + url.Values{"token": {token}}.Encode() + "\n\n" +
+			"If you didn't ask for this, you can ignore this email; your password won't change.\n",
+	}
+	// sent in the background, so the response time doesn't tell whether the account exists.
+	go func() {
+		if err := a.Mail.Send(context.Background(), msg); err != nil {
+			LogError("ForgotPassword", "send reset email", err)
+		}
+	}()
+
+	return c.Status(http.StatusOK).JSON(response)
+}
+
+// ResetPassword sets a new password using the token of a reset link, then revokes every session of the user.
+// returns a 400 if the token is invalid, expired or already used, or the password is invalid.
+// returns a 200 if the password is reset.
+func (a *Actions) ResetPassword(c *fiber.Ctx, token, password string) error {
+	if err := db.ValidatePassword(password); err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	ctx := c.Context()
+	reset, err := a.Client.PasswordReset.
+		Query().
+		Where(
+			PasswordReset.TokenHashEQ(secret.HashToken(token)),
+			PasswordReset.ExpirationGT(time.Now()),
+		).
+		First(ctx)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return BadRequest(c, "invalid or expired reset link")
+		}
+		LogError("ResetPassword", "check reset", err)
+		return InternalServerError(c, "error resetting password")
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 10)
+	if err != nil {
+		LogError("ResetPassword", "hash password", err)
+		return InternalServerError(c, "an error occurred")
+	}
+
+	tx, err := a.Client.Tx(ctx)
+	if err != nil {
+		LogError("ResetPassword", "begin transaction", err)
+		return InternalServerError(c, "error resetting password")
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// use up the reset; if nothing is deleted, a concurrent request used it first.
+	affected, err := tx.PasswordReset.
+		Delete().
+		Where(PasswordReset.IDEQ(reset.ID)).
+		Exec(ctx)
+	if err != nil {
+		LogError("ResetPassword", "delete reset", err)
+		return InternalServerError(c, "error resetting password")
+	} else if affected == 0 {
+		return BadRequest(c, "invalid or expired reset link")
+	}
+
+	// along with any other pending reset of the user.
+	_, err = tx.PasswordReset.
+		Delete().
+		Where(PasswordReset.UserIDEQ(reset.UserID)).
+		Exec(ctx)
+	if err != nil {
+		LogError("ResetPassword", "delete resets", err)
+		return InternalServerError(c, "error resetting password")
+	}
+
+	err = tx.User.
+		UpdateOneID(reset.UserID).
+		SetPassword(string(hashedPassword)).
+		Exec(ctx)
+	if err != nil {
+		LogError("ResetPassword", "update password", err)
+		return InternalServerError(c, "error resetting password")
+	}
+
+	// whoever knew the old password shouldn't stay logged in.
+	_, err = tx.Session.
+		Delete().
+		Where(Session.UserIDEQ(reset.UserID)).
+		Exec(ctx)
+	if err != nil {
+		LogError("ResetPassword", "revoke sessions", err)
+		return InternalServerError(c, "error resetting password")
+	}
+
+	if err = tx.Commit(); err != nil {
+		LogError("ResetPassword", "commit", err)
+		return InternalServerError(c, "error resetting password")
+	}
+
+	ExpireSessionCookies(c, a.Env.SameSite, a.Env.Secure)
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"acknowledged": true,
+		"message":      "password reset, log in with your new password",
+	})
+}
+
+// SetPassword sets a first password for an account created by logging in with Spotify,
+// so it can also log in with its username and unlink Spotify.
+// returns a 400 if the account already has a password or the password is invalid.
+// returns a 200 if the password is set.
+func (a *Actions) SetPassword(c *fiber.Ctx, password string) error {
+	session := c.Locals("session").(*ent.Session)
+
+	if err := db.ValidatePassword(password); err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 10)
+	if err != nil {
+		LogError("SetPassword", "hash password", err)
+		return InternalServerError(c, "an error occurred")
+	}
+
+	// only accounts without a password; others have to know the current one to change it.
+	affected, err := a.Client.User.
+		Update().
+		Where(
+			User.IDEQ(session.UserID),
+			User.PasswordEQ(""),
+		).
+		SetPassword(string(hashedPassword)).
+		Save(c.Context())
+	if err != nil {
+		LogError("SetPassword", "update password", err)
+		return InternalServerError(c, "error setting password")
+	} else if affected == 0 {
+		return BadRequest(c, "account already has a password")
+	}
+
+	// a change of privileges rotates the Csrf token of the session.
+	if err = a.rotateCSRF(c, session); err != nil {
+		LogError("SetPassword", "rotate csrf", err)
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"acknowledged": true,
+		"message":      "password set, you can now log in with your username",
+	})
+}
@@ -0,0 +1,99 @@
+package actions
+
+import (
+	"bytes"
+	"github.com/gofiber/fiber/v2"
+	"groove/pkgs/playlistfile"
+	"groove/pkgs/spotify"
+	. "groove/pkgs/util"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ExportPlaylist returns every track of a playlist with the given id as a file of the given format.
+// unavailable tracks are left out.
+// returns 200 with the file as an attachment on success.
+// returns 404 if the playlist is not found.
+// returns 400 if the playlist-id is invalid.
+func (a *Actions) ExportPlaylist(c *fiber.Ctx, playlistID string, format playlistfile.Format) error {
+	access := c.Locals("access").(string)
+	ctx := c.Context()
+
+	playlist, err := a.Spotify.GetPlaylist(ctx, access, playlistID, "US")
+	if err != nil {
+		return SpotifyError(c, "ExportPlaylist", err, playlistFailures)
+	}
+
+	// the playlist comes with its first page of tracks; the rest is paginated from there.
+	items := playlist.Tracks.Items
+	if len(items) < playlist.Tracks.Total {
+		rest, err := a.Spotify.PaginatePlaylistTracks(access, playlistID, "US", len(items)).All(ctx)
+		if err != nil {
+			return SpotifyError(c, "ExportPlaylist", err, playlistFailures)
+		}
+		items = append(items, rest...)
+	}
+
+	file := &playlistfile.Playlist{
+		Title:       playlist.Name,
+		Description: playlist.Description,
+		Creator:     playlist.Owner.ID,
+		Location:    playlist.ExternalURLs.Spotify,
+	}
+	if playlist.Owner.DisplayName != nil {
+		file.Creator = *playlist.Owner.DisplayName
+	}
+	for _, item := range items {
+		if item.Track == nil {
+			continue
+		}
+		file.Entries = append(file.Entries, exportEntry(item))
+	}
+
+	var buf bytes.Buffer
+	if err = playlistfile.Write(&buf, format, file); err != nil {
+		LogError("ExportPlaylist", "write "+string(format), err)
+		return InternalServerError(c, "error exporting playlist")
+	}
+
+	c.Set(fiber.HeaderContentType, format.ContentType())
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+exportFilename(playlist.Name)+format.Extension()+`"`)
+	return c.Status(http.StatusOK).Send(buf.Bytes())
+}
+
+func exportEntry(item spotify.PlaylistTrack) playlistfile.Entry {
+	track := item.Track
+	entry := playlistfile.Entry{
+		Title:    track.Name,
+		Album:    track.Album.Name,
+		ISRC:     track.ExternalIDs.ISRC,
+		Duration: time.Duration(track.DurationMs) * time.Millisecond,
+		Location: track.ExternalURLs.Spotify,
+		URI:      track.URI,
+	}
+	for _, artist := range track.Artists {
+		entry.Artists = append(entry.Artists, artist.Name)
+	}
+	// Spotify leaves added_at out of very old playlists.
+	if addedAt, err := time.Parse(time.RFC3339, item.AddedAt); err == nil {
+		entry.AddedAt = addedAt
+	}
+	return entry
+}
+
+// exportFilename keeps the letters, digits, spaces, dashes and underscores of a playlist's name,
+// so that it is safe within a Content-Disposition header and on any file system.
+func exportFilename(name string) string {
+	filename := strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == ' ' || r == '-' || r == '_' {
+			return r
+		}
+		return -1
+	}, name))
+	if filename == "" {
+		return "playlist"
+	}
+	return filename
+}
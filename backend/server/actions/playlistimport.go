@@ -0,0 +1,311 @@
+package actions
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"groove/pkgs/db"
+	"groove/pkgs/ent"
+	PlaylistImport "groove/pkgs/ent/playlistimport"
+	"groove/pkgs/ent/schema"
+	"groove/pkgs/playlistfile"
+	. "groove/pkgs/util"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxRunningImports is how many unfinished imports a user can have at once.
+const maxRunningImports = 3
+
+// ImportPlaylist starts an import of a playlist file; its entries are matched to Spotify tracks in the background
+// by the scheduler, then left for the user to review and confirm.
+// returns 202 with the import on success.
+// returns 400 if the file cannot be read, or if the user has too many unfinished imports.
+func (a *Actions) ImportPlaylist(c *fiber.Ctx, file io.Reader, format playlistfile.Format, name string) error {
+	session := c.Locals("session").(*ent.Session)
+	ctx := c.Context()
+
+	running, err := a.Client.PlaylistImport.
+		Query().
+		Where(
+			PlaylistImport.UserIDEQ(session.UserID),
+			PlaylistImport.StatusNotIn(PlaylistImport.StatusDone, PlaylistImport.StatusFailed),
+		).
+		Count(ctx)
+	if err != nil {
+		LogError("ImportPlaylist", "count imports", err)
+		return InternalServerError(c, "error starting import")
+	}
+	if running >= maxRunningImports {
+		return BadRequest(c, "too many unfinished imports, confirm or delete one first")
+	}
+
+	playlist, err := playlistfile.Read(file, format)
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	if name = strings.TrimSpace(name); name == "" {
+		name = strings.TrimSpace(playlist.Title)
+	}
+	if name == "" {
+		name = "Imported playlist"
+	}
+	if len([]rune(name)) > maxPlaylistName {
+		name = string([]rune(name)[:maxPlaylistName])
+	}
+	description := playlist.Description
+	if len([]rune(description)) > maxPlaylistDescription {
+		description = string([]rune(description)[:maxPlaylistDescription])
+	}
+
+	entries := make([]schema.ImportEntry, len(playlist.Entries))
+	for i, entry := range playlist.Entries {
+		entries[i] = schema.ImportEntry{Entry: entry}
+	}
+
+	imp, err := a.Client.PlaylistImport.
+		Create().
+		SetUserID(session.UserID).
+		SetFormat(string(format)).
+		SetName(name).
+		SetDescription(description).
+		SetEntries(entries).
+		Save(ctx)
+	if err != nil {
+		LogError("ImportPlaylist", "create import", err)
+		return InternalServerError(c, "error starting import")
+	}
+
+	return c.Status(http.StatusAccepted).JSON(fiber.Map{
+		"acknowledged": true,
+		"message":      "import started, its tracks are being matched",
+		"import":       importStatus(imp),
+	})
+}
+
+// GetImports sends the status of every import of the user, newest first.
+// returns 200 with the imports.
+func (a *Actions) GetImports(c *fiber.Ctx) error {
+	session := c.Locals("session").(*ent.Session)
+	ctx := c.Context()
+
+	imports, err := a.Client.PlaylistImport.
+		Query().
+		Where(PlaylistImport.UserIDEQ(session.UserID)).
+		Order(ent.Desc(PlaylistImport.FieldCreatedAt)).
+		All(ctx)
+	if err != nil {
+		LogError("GetImports", "query imports", err)
+		return InternalServerError(c, "error getting imports")
+	}
+
+	statuses := make([]fiber.Map, len(imports))
+	for i, imp := range imports {
+		statuses[i] = importStatus(imp)
+	}
+	return c.Status(http.StatusOK).JSON(fiber.Map{"imports": statuses})
+}
+
+// GetImport sends the status of an import, to poll its progress; once matched, along with its match report:
+// every entry of the file with the track it matched, if any, and the confidence of the match.
+// returns 200 with the import.
+// returns 404 if the import is not found.
+func (a *Actions) GetImport(c *fiber.Ctx, importID int) error {
+	imp, err := a.userImport(c, importID)
+	if err != nil {
+		return importError(c, "GetImport", err)
+	}
+
+	status := importStatus(imp)
+	if imp.Status != PlaylistImport.StatusPending && imp.Status != PlaylistImport.StatusMatching {
+		report := make([]fiber.Map, len(imp.Entries))
+		for i, entry := range imp.Entries {
+			report[i] = importReportEntry(i, entry)
+		}
+		status["report"] = report
+		status["min_confidence"] = db.MinMatchConfidence
+	}
+	return c.Status(http.StatusOK).JSON(fiber.Map{"import": status})
+}
+
+// ConfirmImport confirms an import once its matches are reviewed: the scheduler then creates the playlist
+// with the given details, and adds its matched tracks in batches.
+// matches of excluded entries (by index), or under the given confidence, are left out.
+// returns 202 with the import on success.
+// returns 404 if the import is not found.
+// returns 400 if the import is not waiting for review, if the details are invalid, or if no track is left.
+func (a *Actions) ConfirmImport(c *fiber.Ctx, importID int, name, description string, public bool, exclude []int, minConfidence float64) error {
+	ctx := c.Context()
+
+	imp, err := a.userImport(c, importID)
+	if err != nil {
+		return importError(c, "ConfirmImport", err)
+	}
+	if imp.Status != PlaylistImport.StatusReview {
+		return BadRequest(c, "import is not waiting for review")
+	}
+
+	if err = validatePlaylistDetails(&name, &description, public, false); err != nil {
+		return BadRequest(c, err.Error())
+	}
+	if minConfidence < 0 || minConfidence > 1 {
+		return BadRequest(c, "min_confidence must be between 0 and 1")
+	}
+
+	entries := imp.Entries
+	for _, i := range exclude {
+		if i < 0 || i >= len(entries) {
+			return BadRequest(c, "invalid entry index in exclude")
+		}
+		entries[i].Match = nil
+	}
+	for i := range entries {
+		if entries[i].Match != nil && entries[i].Match.Confidence < minConfidence {
+			entries[i].Match = nil
+		}
+	}
+	if len(db.ImportURIs(entries)) == 0 {
+		return BadRequest(c, "no matched tracks left to import")
+	}
+
+	// only claims the import if it is still in review, so that a double confirmation doesn't create two playlists.
+	confirmed, err := a.Client.PlaylistImport.
+		Update().
+		Where(
+			PlaylistImport.IDEQ(imp.ID),
+			PlaylistImport.StatusEQ(PlaylistImport.StatusReview),
+		).
+		SetName(name).
+		SetDescription(description).
+		SetPublic(public).
+		SetEntries(entries).
+		SetProgress(0).
+		SetStatus(PlaylistImport.StatusConfirmed).
+		Save(ctx)
+	if err != nil {
+		LogError("ConfirmImport", "update import", err)
+		return InternalServerError(c, "error confirming import")
+	}
+	if confirmed == 0 {
+		return BadRequest(c, "import is not waiting for review")
+	}
+
+	imp.Name, imp.Description, imp.Public = name, description, public
+	imp.Entries, imp.Progress, imp.Status = entries, 0, PlaylistImport.StatusConfirmed
+	return c.Status(http.StatusAccepted).JSON(fiber.Map{
+		"acknowledged": true,
+		"message":      "import confirmed, the playlist is being created",
+		"import":       importStatus(imp),
+	})
+}
+
+// DeleteImport deletes an import; one running in the background stops at its next save.
+// the playlist of an import already created is kept.
+// returns 200 on success.
+// returns 404 if the import is not found.
+func (a *Actions) DeleteImport(c *fiber.Ctx, importID int) error {
+	session := c.Locals("session").(*ent.Session)
+	ctx := c.Context()
+
+	deleted, err := a.Client.PlaylistImport.
+		Delete().
+		Where(
+			PlaylistImport.IDEQ(importID),
+			PlaylistImport.UserIDEQ(session.UserID),
+		).
+		Exec(ctx)
+	if err != nil {
+		LogError("DeleteImport", "delete import", err)
+		return InternalServerError(c, "error deleting import")
+	}
+	if deleted == 0 {
+		return BadRequest(c, "import not found", http.StatusNotFound)
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{"acknowledged": true})
+}
+
+// userImport returns the import with the given id if it belongs to the user.
+func (a *Actions) userImport(c *fiber.Ctx, importID int) (*ent.PlaylistImport, error) {
+	session := c.Locals("session").(*ent.Session)
+	return a.Client.PlaylistImport.
+		Query().
+		Where(
+			PlaylistImport.IDEQ(importID),
+			PlaylistImport.UserIDEQ(session.UserID),
+		).
+		Only(c.Context())
+}
+
+func importError(c *fiber.Ctx, fn string, err error) error {
+	if ent.IsNotFound(err) {
+		return BadRequest(c, "import not found", http.StatusNotFound)
+	}
+	LogError(fn, "query import", err)
+	return InternalServerError(c, "error getting import")
+}
+
+// importStatus is the status of an import, as sent to the user; progress is out of total entries while matching,
+// and out of total matched tracks while creating the playlist.
+func importStatus(imp *ent.PlaylistImport) fiber.Map {
+	total := len(imp.Entries)
+	var matched int
+	for _, entry := range imp.Entries {
+		if entry.Match != nil {
+			matched++
+		}
+	}
+	switch imp.Status {
+	case PlaylistImport.StatusConfirmed, PlaylistImport.StatusCreating, PlaylistImport.StatusDone:
+		total = matched
+	}
+
+	status := fiber.Map{
+		"id":          imp.ID,
+		"status":      imp.Status,
+		"format":      imp.Format,
+		"name":        imp.Name,
+		"description": imp.Description,
+		"public":      imp.Public,
+		"progress":    imp.Progress,
+		"total":       total,
+		"entries":     len(imp.Entries),
+		"created_at":  imp.CreatedAt,
+		"updated_at":  imp.UpdatedAt,
+	}
+	if imp.Status != PlaylistImport.StatusPending && imp.Status != PlaylistImport.StatusMatching {
+		status["matched"] = matched
+		status["unmatched"] = len(imp.Entries) - matched
+	}
+	if imp.PlaylistID != nil {
+		status["playlist_id"] = *imp.PlaylistID
+	}
+	if imp.Error != nil {
+		status["error"] = *imp.Error
+	}
+	return status
+}
+
+// importReportEntry is an entry of the match report of an import, as sent to the user.
+func importReportEntry(index int, entry schema.ImportEntry) fiber.Map {
+	report := fiber.Map{
+		"index":   index,
+		"title":   entry.Title,
+		"artists": entry.Artists,
+		"album":   entry.Album,
+		"match":   entry.Match,
+	}
+	if entry.Artists == nil {
+		report["artists"] = []string{}
+	}
+	if entry.ISRC != "" {
+		report["isrc"] = entry.ISRC
+	}
+	if entry.Duration > 0 {
+		report["duration_ms"] = entry.Duration.Milliseconds()
+	}
+	if entry.URI != "" {
+		report["uri"] = entry.URI
+	}
+	return report
+}
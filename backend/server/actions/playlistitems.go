@@ -0,0 +1,216 @@
+package actions
+
+import (
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"groove/pkgs/spotify"
+	. "groove/pkgs/util"
+	"net/http"
+	"regexp"
+	"sort"
+)
+
+// maxBatchItems is the most items a single batch request may add or remove;
+// batches are split into requests of spotify.MaxItemsPerRequest items.
+const maxBatchItems = 500
+
+var itemURIRegex = regexp.MustCompile(`^spotify:(track|episode):[0-9A-Za-z]+$`)
+
+var playlistBatchFailures = Failures{
+	http.StatusBadRequest: "invalid items or positions",
+	http.StatusForbidden:  "playlist is not collaborative",
+	http.StatusNotFound:   "playlist not found",
+}
+
+// validateItemURIs checks the uris of a batch are spotify:track:{id} or spotify:episode:{id} uris.
+func validateItemURIs(uris []string) error {
+	for _, uri := range uris {
+		if !itemURIRegex.MatchString(uri) {
+			return fmt.Errorf("invalid uri %q: must be a spotify track or episode uri", uri)
+		}
+	}
+	return nil
+}
+
+// checkSnapshot compares the snapshot id the user last saw of a playlist with its current one,
+// so an edit made against a stale playlist is refused instead of clobbering concurrent changes.
+// an empty snapshot id skips the check.
+// if it returns false, the response was already sent and is returned as the error.
+func (a *Actions) checkSnapshot(c *fiber.Ctx, fn, playlistID, snapshotID string) (bool, error) {
+	if snapshotID == "" {
+		return true, nil
+	}
+	access := c.Locals("access").(string)
+
+	current, err := a.Spotify.GetPlaylistSnapshot(c.Context(), access, playlistID)
+	if err != nil {
+		return false, SpotifyError(c, fn, err, playlistFailures)
+	}
+	if current.SnapshotID != snapshotID {
+		return false, c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error":       "conflict",
+			"message":     "playlist was modified since the given snapshot, reload it and try again",
+			"snapshot_id": current.SnapshotID,
+		})
+	}
+	return true, nil
+}
+
+// AddPlaylistItems adds up to maxBatchItems items to a playlist, at position or at the end if position is nil.
+// items are sent in chunks; the chunks sent before a failure stay added.
+// returns 201 with the new snapshot id on success.
+// returns 409 if the playlist was modified since snapshotID.
+// returns 404 if the playlist is not found.
+// returns 400 if the uris or position are invalid, or the playlist is not collaborative.
+func (a *Actions) AddPlaylistItems(c *fiber.Ctx, playlistID string, uris []string, position *int, snapshotID string) error {
+	access := c.Locals("access").(string)
+	ctx := c.Context()
+
+	if len(uris) == 0 || len(uris) > maxBatchItems {
+		return BadRequest(c, fmt.Sprintf("uris must contain between 1 and %d items", maxBatchItems))
+	}
+	if err := validateItemURIs(uris); err != nil {
+		return BadRequest(c, err.Error())
+	}
+	if position != nil && *position < 0 {
+		return BadRequest(c, "position must not be negative")
+	}
+	if ok, err := a.checkSnapshot(c, "AddPlaylistItems", playlistID, snapshotID); !ok {
+		return err
+	}
+
+	var snapshot *spotify.Snapshot
+	for start := 0; start < len(uris); start += spotify.MaxItemsPerRequest {
+		end := start + spotify.MaxItemsPerRequest
+		if end > len(uris) {
+			end = len(uris)
+		}
+
+		req := spotify.AddItemsRequest{PlaylistID: playlistID, URIs: uris[start:end]}
+		if position != nil {
+			// each chunk goes right after the previous one.
+			at := *position + start
+			req.Position = &at
+		}
+
+		var err error
+		snapshot, err = a.Spotify.AddPlaylistItems(ctx, access, req)
+		if err != nil {
+			return SpotifyError(c, "AddPlaylistItems", err, playlistBatchFailures)
+		}
+	}
+
+	return c.Status(http.StatusCreated).JSON(fiber.Map{
+		"snapshot_id": snapshot.SnapshotID,
+		"added":       len(uris),
+	})
+}
+
+// RemovePlaylistItems removes up to maxBatchItems items from a playlist:
+// uris are removed everywhere they occur, items only at their positions in the playlist as of snapshotID.
+// items are sent in chunks, highest positions first so that every chunk leaves the positions of the next intact;
+// the chunks sent before a failure stay removed, and a chunk Spotify failed with 5xx may have been applied.
+// returns 200 with the new snapshot id on success.
+// returns 409 if the playlist was modified since snapshotID.
+// returns 404 if the playlist is not found.
+// returns 400 if the uris or positions are invalid, or the playlist is not collaborative.
+func (a *Actions) RemovePlaylistItems(c *fiber.Ctx, playlistID string, uris []string, items []spotify.PositionedItem, snapshotID string) error {
+	access := c.Locals("access").(string)
+	ctx := c.Context()
+
+	// every occurrence is sent on its own, so positional removals can be ordered and chunked freely.
+	var occurrences []spotify.PositionedItem
+	for _, item := range items {
+		if err := validateItemURIs([]string{item.URI}); err != nil {
+			return BadRequest(c, err.Error())
+		}
+		if len(item.Positions) == 0 {
+			return BadRequest(c, "items must have at least one position")
+		}
+		for _, position := range item.Positions {
+			if position < 0 {
+				return BadRequest(c, "positions must not be negative")
+			}
+			occurrences = append(occurrences, spotify.PositionedItem{URI: item.URI, Positions: []int{position}})
+		}
+	}
+
+	total := len(uris) + len(occurrences)
+	if total == 0 || total > maxBatchItems {
+		return BadRequest(c, fmt.Sprintf("uris and item positions must total between 1 and %d", maxBatchItems))
+	}
+	if err := validateItemURIs(uris); err != nil {
+		return BadRequest(c, err.Error())
+	}
+	if ok, err := a.checkSnapshot(c, "RemovePlaylistItems", playlistID, snapshotID); !ok {
+		return err
+	}
+
+	sort.SliceStable(occurrences, func(i, j int) bool {
+		return occurrences[i].Positions[0] > occurrences[j].Positions[0]
+	})
+
+	snapshot := &spotify.Snapshot{SnapshotID: snapshotID}
+	for start := 0; start < total; start += spotify.MaxItemsPerRequest {
+		end := start + spotify.MaxItemsPerRequest
+		if end > total {
+			end = total
+		}
+
+		// positional removals come first, as removing uris everywhere shifts the positions.
+		req := spotify.RemoveItemsRequest{PlaylistID: playlistID, SnapshotID: snapshot.SnapshotID}
+		for i := start; i < end; i++ {
+			if i < len(occurrences) {
+				req.Items = append(req.Items, occurrences[i])
+			} else {
+				req.URIs = append(req.URIs, uris[i-len(occurrences)])
+			}
+		}
+
+		var err error
+		snapshot, err = a.Spotify.RemovePlaylistItems(ctx, access, req)
+		if err != nil {
+			return SpotifyError(c, "RemovePlaylistItems", err, playlistBatchFailures)
+		}
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"snapshot_id": snapshot.SnapshotID,
+		"removed":     total,
+	})
+}
+
+// ReorderPlaylistItems moves rangeLength items of a playlist starting at rangeStart before the item at insertBefore.
+// a move Spotify failed with 5xx may have been applied, so it is never retried here; clients reload the playlist.
+// returns 200 with the new snapshot id on success.
+// returns 409 if the playlist was modified since snapshotID.
+// returns 404 if the playlist is not found.
+// returns 400 if the range is invalid, or the playlist is not collaborative.
+func (a *Actions) ReorderPlaylistItems(c *fiber.Ctx, playlistID string, rangeStart, insertBefore, rangeLength int, snapshotID string) error {
+	access := c.Locals("access").(string)
+
+	if rangeStart < 0 || insertBefore < 0 {
+		return BadRequest(c, "range_start and insert_before must not be negative")
+	}
+	if rangeLength < 1 {
+		return BadRequest(c, "range_length must be at least 1")
+	}
+	if ok, err := a.checkSnapshot(c, "ReorderPlaylistItems", playlistID, snapshotID); !ok {
+		return err
+	}
+
+	snapshot, err := a.Spotify.ReorderPlaylistItems(c.Context(), access, spotify.ReorderItemsRequest{
+		PlaylistID:   playlistID,
+		RangeStart:   rangeStart,
+		RangeLength:  rangeLength,
+		InsertBefore: insertBefore,
+		SnapshotID:   snapshotID,
+	})
+	if err != nil {
+		return SpotifyError(c, "ReorderPlaylistItems", err, playlistBatchFailures)
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"snapshot_id": snapshot.SnapshotID,
+	})
+}
@@ -2,38 +2,69 @@ package actions
 
 import (
 	"errors"
-	"github.com/go-resty/resty/v2"
+	"fmt"
 	"github.com/gofiber/fiber/v2"
+	"groove/pkgs/spotify"
 	. "groove/pkgs/util"
 	"net/http"
-	"strconv"
+	"strings"
 )
 
+var playlistFailures = Failures{
+	http.StatusBadRequest: "invalid playlist-id",
+	http.StatusNotFound:   "playlist not found",
+}
+
+// myPlaylistsFailures are the failures of listing the user's playlists, which takes no playlist-id.
+var myPlaylistsFailures = Failures{
+	http.StatusBadRequest: "invalid request",
+}
+
+var playlistItemFailures = Failures{
+	http.StatusBadRequest: "invalid track-id",
+	http.StatusForbidden:  "playlist is not collaborative",
+	http.StatusNotFound:   "playlist not found",
+}
+
 // GetAllPlaylists returns all playlists for the current user.
-func (*Actions) GetAllPlaylists(c *fiber.Ctx) error {
-	proxy := &Proxy{
-		Endpoint: "/me/playlists?limit=50",
-		Access:   c.Locals("access").(string),
+func (a *Actions) GetAllPlaylists(c *fiber.Ctx) error {
+	access := c.Locals("access").(string)
+
+	playlists, err := a.Spotify.GetMyPlaylists(c.Context(), access, 50, 0)
+	if err != nil {
+		return SpotifyError(c, "GetAllPlaylists", err, myPlaylistsFailures)
 	}
-	return proxy.PlaylistRequest(c)
+
+	return c.Status(http.StatusOK).JSON(playlists)
 }
 
 // GetPlaylist returns a playlist with the first 100 tracks with the given id.
-func (*Actions) GetPlaylist(c *fiber.Ctx, playlistID string) error {
-	proxy := &Proxy{
-		Endpoint: "/playlists/" + playlistID + "?market=US&limit=100",
-		Access:   c.Locals("access").(string),
+func (a *Actions) GetPlaylist(c *fiber.Ctx, playlistID string) error {
+	access := c.Locals("access").(string)
+
+	playlist, err := a.Spotify.GetPlaylist(c.Context(), access, playlistID, "US")
+	if err != nil {
+		return SpotifyError(c, "GetPlaylist", err, playlistFailures)
 	}
-	return proxy.PlaylistRequest(c)
+
+	return c.Status(http.StatusOK).JSON(playlist)
 }
 
 // GetMorePlaylistTracks returns a playlist with the next 100 tracks with the given id.
-func (*Actions) GetMorePlaylistTracks(c *fiber.Ctx, playlistID, offset string) error {
-	proxy := &Proxy{
-		Endpoint: "/playlists/" + playlistID + "/tracks?market=US&limit=100&offset=" + offset,
-		Access:   c.Locals("access").(string),
+func (a *Actions) GetMorePlaylistTracks(c *fiber.Ctx, playlistID string, offset int) error {
+	access := c.Locals("access").(string)
+
+	tracks, err := a.Spotify.GetPlaylistTracks(c.Context(), access, spotify.PlaylistTracksRequest{
+		PlaylistID: playlistID,
+		Market:     "US",
+		Limit:      100,
+		Offset:     offset,
+	})
+	if err != nil {
+		return SpotifyError(c, "GetMorePlaylistTracks", err, playlistFailures)
 	}
-	return proxy.PlaylistRequest(c)
+
+	return c.Status(http.StatusOK).JSON(tracks)
 }
 
 // AddTrackToPlaylist adds a track to a playlist with the given ids.
@@ -41,39 +72,18 @@ func (*Actions) GetMorePlaylistTracks(c *fiber.Ctx, playlistID, offset string) e
 // returns 404 if the playlist is not found.
 // returns 403 if the playlist is not collaborative.
 // returns 400 if the playlist-id is invalid.
-func (*Actions) AddTrackToPlaylist(c *fiber.Ctx, playlistID, trackID string) error {
+func (a *Actions) AddTrackToPlaylist(c *fiber.Ctx, playlistID, trackID string) error {
 	access := c.Locals("access").(string)
-	endpoint := "/playlists/" + playlistID + "/tracks"
-
-	resp, err := resty.New().R().
-		SetHeaders(Headers{
-			"Authorization": "Bearer " + access,
-			"Accept":        "application/json",
-		}).
-		SetBody(`{"uris":["spotify:track:` + trackID + `"]}`).
-		Post(SpotifyAPI + endpoint)
+
+	_, err := a.Spotify.AddPlaylistItems(c.Context(), access, spotify.AddItemsRequest{
+		PlaylistID: playlistID,
+		URIs:       []string{"spotify:track:" + trackID},
+	})
 	if err != nil {
-		LogError("AddTrackToPlaylist", "Requesting "+endpoint, err)
-		return InternalServerError(c, "error requesting "+c.Path())
+		return SpotifyError(c, "AddTrackToPlaylist", err, playlistItemFailures)
 	}
 
-	switch resp.StatusCode() {
-	case 200:
-		return c.Status(http.StatusCreated).SendString("track added to playlist")
-	case 400:
-		return BadRequest(c, "invalid track-id")
-	case 403:
-		return BadRequest(c, "playlist is not collaborative")
-	case 404:
-		return BadRequest(c, "playlist not found", http.StatusNotFound)
-	default:
-		LogError(
-			"AddTrackToPlaylist",
-			"Requesting "+c.Path(),
-			errors.New(strconv.Itoa(resp.StatusCode())+": "+string(resp.Body())),
-		)
-		return InternalServerError(c, "error requesting "+c.Path())
-	}
+	return c.Status(http.StatusCreated).SendString("track added to playlist")
 }
 
 // RemoveTrackFromPlaylist removes a track from a playlist with the given ids.
@@ -81,37 +91,129 @@ func (*Actions) AddTrackToPlaylist(c *fiber.Ctx, playlistID, trackID string) err
 // returns 404 if the playlist is not found.
 // returns 403 if the playlist is not collaborative.
 // returns 400 if the playlist-id is invalid.
-func (*Actions) RemoveTrackFromPlaylist(c *fiber.Ctx, playlistID, trackID string) error {
+func (a *Actions) RemoveTrackFromPlaylist(c *fiber.Ctx, playlistID, trackID string) error {
+	access := c.Locals("access").(string)
+
+	_, err := a.Spotify.RemovePlaylistItems(c.Context(), access, spotify.RemoveItemsRequest{
+		PlaylistID: playlistID,
+		URIs:       []string{"spotify:track:" + trackID},
+	})
+	if err != nil {
+		return SpotifyError(c, "RemoveTrackFromPlaylist", err, playlistItemFailures)
+	}
+
+	return c.Status(http.StatusOK).SendString("track removed from playlist")
+}
+
+var playlistDetailsFailures = Failures{
+	http.StatusBadRequest: "invalid playlist details",
+	http.StatusForbidden:  "playlist is not owned by user",
+	http.StatusNotFound:   "playlist not found",
+}
+
+const (
+	maxPlaylistName        = 100
+	maxPlaylistDescription = 300
+)
+
+// validatePlaylistDetails checks the details of a playlist before they are sent to spotify; nil fields are left unchecked.
+// collaborative and public are the flags the playlist will end up with.
+func validatePlaylistDetails(name, description *string, public, collaborative bool) error {
+	switch {
+	case name != nil && strings.TrimSpace(*name) == "":
+		return errors.New("playlist name is required")
+	case name != nil && len([]rune(*name)) > maxPlaylistName:
+		return fmt.Errorf("playlist name must be at most %d characters", maxPlaylistName)
+	case description != nil && len([]rune(*description)) > maxPlaylistDescription:
+		return fmt.Errorf("playlist description must be at most %d characters", maxPlaylistDescription)
+	case public && collaborative:
+		return errors.New("collaborative playlists must be private")
+	}
+	return nil
+}
+
+// CreatePlaylist creates a playlist for the linked spotify account of the user.
+// returns 201 with the playlist on success.
+// returns 400 if the details are invalid.
+func (a *Actions) CreatePlaylist(c *fiber.Ctx, name, description string, public, collaborative bool) error {
+	access := c.Locals("access").(string)
+	ctx := c.Context()
+
+	if err := validatePlaylistDetails(&name, &description, public, collaborative); err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	user, err := a.Spotify.GetCurrentUser(ctx, access)
+	if err != nil {
+		return SpotifyError(c, "CreatePlaylist", err, nil)
+	}
+
+	playlist, err := a.Spotify.CreatePlaylist(ctx, access, spotify.CreatePlaylistRequest{
+		UserID:        user.ID,
+		Name:          name,
+		Description:   description,
+		Public:        public,
+		Collaborative: collaborative,
+	})
+	if err != nil {
+		return SpotifyError(c, "CreatePlaylist", err, playlistDetailsFailures)
+	}
+
+	return c.Status(http.StatusCreated).JSON(playlist)
+}
+
+// UpdatePlaylist changes the name, description, public or collaborative flags of a playlist with the given id;
+// nil fields are left unchanged.
+// returns 200 on success.
+// returns 404 if the playlist is not found.
+// returns 400 if the details are invalid or the playlist is not owned by the user.
+func (a *Actions) UpdatePlaylist(c *fiber.Ctx, playlistID string, details spotify.PlaylistDetails) error {
 	access := c.Locals("access").(string)
-	endpoint := "/playlists/" + playlistID + "/tracks"
-
-	resp, err := resty.New().R().
-		SetHeaders(Headers{
-			"Authorization": "Bearer " + access,
-			"Accept":        "application/json",
-		}).
-		SetBody(`{"tracks":[{ "uri":"spotify:track:` + trackID + `"}]}`).
-		Delete(SpotifyAPI + endpoint)
+	ctx := c.Context()
+
+	if details.Name == nil && details.Description == nil && details.Public == nil && details.Collaborative == nil {
+		return BadRequest(c, "nothing to update")
+	}
+
+	// the flags are checked against the current ones when only one of them changes.
+	public, collaborative := details.Public, details.Collaborative
+	if public == nil || collaborative == nil {
+		playlist, err := a.Spotify.GetPlaylist(ctx, access, playlistID, "US")
+		if err != nil {
+			return SpotifyError(c, "UpdatePlaylist", err, playlistDetailsFailures)
+		}
+		if public == nil {
+			public = playlist.Public
+		}
+		if collaborative == nil {
+			collaborative = &playlist.Collaborative
+		}
+	}
+	err := validatePlaylistDetails(details.Name, details.Description, public != nil && *public, *collaborative)
 	if err != nil {
-		LogError("RemoveTrackFromPlaylist", "Requesting "+endpoint, err)
-		return InternalServerError(c, "error requesting "+c.Path())
+		return BadRequest(c, err.Error())
 	}
 
-	switch resp.StatusCode() {
-	case 200:
-		return c.Status(http.StatusOK).SendString("track removed from playlist")
-	case 400:
-		return BadRequest(c, "invalid track-id")
-	case 403:
-		return BadRequest(c, "playlist is not collaborative")
-	case 404:
-		return BadRequest(c, "playlist not found", http.StatusNotFound)
-	default:
-		LogError(
-			"RemoveTrackFromPlaylist",
-			"Requesting "+c.Path(),
-			errors.New(strconv.Itoa(resp.StatusCode())+": "+string(resp.Body())),
-		)
-		return InternalServerError(c, "error requesting "+c.Path())
+	err = a.Spotify.ChangePlaylistDetails(ctx, access, playlistID, details)
+	if err != nil {
+		return SpotifyError(c, "UpdatePlaylist", err, playlistDetailsFailures)
+	}
+
+	return c.Status(http.StatusOK).SendString("playlist updated")
+}
+
+// UnfollowPlaylist removes a playlist with the given id from the library of the user;
+// for a playlist the user owns, this is how spotify deletes it.
+// returns 200 on success.
+// returns 404 if the playlist is not found.
+// returns 400 if the playlist-id is invalid.
+func (a *Actions) UnfollowPlaylist(c *fiber.Ctx, playlistID string) error {
+	access := c.Locals("access").(string)
+
+	err := a.Spotify.UnfollowPlaylist(c.Context(), access, playlistID)
+	if err != nil {
+		return SpotifyError(c, "UnfollowPlaylist", err, playlistFailures)
 	}
+
+	return c.Status(http.StatusOK).SendString("playlist unfollowed")
 }
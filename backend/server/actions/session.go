@@ -7,6 +7,7 @@ import (
 	"golang.org/x/crypto/bcrypt"
 	"groove/pkgs/db"
 	"groove/pkgs/ent"
+	Session "groove/pkgs/ent/session"
 	SpotifyLink "groove/pkgs/ent/spotifylink"
 	User "groove/pkgs/ent/user"
 	. "groove/pkgs/util"
@@ -16,6 +17,7 @@ import (
 
 // Register creates a new user and session and sets Authorization cookie.
 // returns a 400 if the username or email is already taken/invalid.
+// returns a 429 if too many accounts were created from the IP.
 // returns a 201 if the user and session are created.
 func (a *Actions) Register(c *fiber.Ctx, password, username, email string) error {
 	// validate user input in order to prevent unnecessary database calls.
@@ -24,6 +26,14 @@ func (a *Actions) Register(c *fiber.Ctx, password, username, email string) error
 	}
 
 	ctx := c.Context()
+	wait, err := a.Throttles.Locked(ctx, registerIPKey(c.IP()))
+	if err != nil {
+		LogError("Register", "check lockout", err)
+		return InternalServerError(c, "error creating account")
+	} else if wait > 0 {
+		return TooManyRequests(c, "too many accounts created, try again later", wait)
+	}
+
 	exists, err := a.Client.User.
 		Query().
 		Where(User.UsernameEQ(username)).
@@ -64,77 +74,95 @@ func (a *Actions) Register(c *fiber.Ctx, password, username, email string) error
 		return InternalServerError(c, "error creating account")
 	}
 
-	// manage session creation and cookie.
-	token := uuid.New().String()
-	csrf := uuid.New().String()
-	expiration := time.Now().Add(TimeWeek)
+	// only accounts actually created count towards the limit of the IP.
+	if _, err = a.Throttles.Record(ctx, registerIPKey(c.IP()), registerIPPolicy); err != nil {
+		LogError("Register", "record registration", err)
+	}
 
-	_, err = a.Client.Session.Create().
-		SetToken(token).
-		SetUser(user).
-		SetCsrf(csrf).
-		SetExpiration(expiration).
-		Save(ctx)
-	if err != nil {
+	if err = a.startSession(c, user); err != nil {
 		LogError("Register", "create session", err)
 		return InternalServerError(c, "error creating session")
 	}
-	SetSessionCookies(c, token, csrf, expiration, a.Env.SameSite, a.Env.Secure)
+
+	// the account works right away, but linking spotify and modifying playlists wait for the email to be verified.
+	if err = a.sendVerification(ctx, user, user.Email); err != nil {
+		LogError("Register", "send verification", err)
+		// no need to fail the registration, the user can ask for another email.
+	}
 
 	return c.Status(http.StatusCreated).JSON(fiber.Map{
 		"acknowledged": true,
-		"message":      "user " + username + " created",
+		"message":      "user " + username + " created, check your email to verify it",
 		"user": fiber.Map{
-			"username": user.Username,
-			"email":    user.Email,
+			"username":       user.Username,
+			"email":          user.Email,
+			"email_verified": user.EmailVerified,
 		},
 	})
 }
 
 // Login creates a new session and sets Authorization cookie.
-// returns a 400 if the username does not exist or the password is incorrect.
+// failures are counted per IP and per account, which are locked out for longer and longer as they keep failing.
+// returns a 400 if the username does not exist or the password is incorrect, with the same message for both.
+// returns a 429 if the IP or the account is locked out.
+// returns a 403 if the account is disabled by an admin.
+// returns a 200 with a challenge if the user has two-factor authentication enabled; see LoginTwoFactor.
 // returns a 201 if the session is created.
 func (a *Actions) Login(c *fiber.Ctx, username, password string) error {
 	ctx := c.Context()
+
+	wait, err := a.locked(ctx, loginIPKey(c.IP()), loginAccountKey(username))
+	if err != nil {
+		LogError("Login", "check lockout", err)
+		return InternalServerError(c, "error while authorizing")
+	} else if wait > 0 {
+		return TooManyRequests(c, "too many failed login attempts, try again later", wait)
+	}
+
 	// check if username exists.
 	user, err := a.Client.User.
 		Query().
 		Where(User.UsernameEQ(username)).
 		First(ctx)
-	if err != nil {
-		if ent.IsNotFound(err) {
-			return BadRequest(c, "username does not exist")
-		}
+	if err != nil && !ent.IsNotFound(err) {
 		LogError("Login", "check user", err)
 		return InternalServerError(c, "error getting account")
 	}
 
-	// check against stored password.
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
-	if err != nil {
-		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
-			return BadRequest(c, "incorrect password")
-		}
+	// check against stored password; unknown users and accounts without a password (created by logging in
+	// with spotify) are checked against a dummy hash, so the response time doesn't tell them apart.
+	hash := dummyHash
+	if user != nil && user.Password != "" {
+		hash = []byte(user.Password)
+	}
+	err = bcrypt.CompareHashAndPassword(hash, []byte(password))
+	if err != nil && !errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
 		LogError("Login", "check password", err)
-		return BadRequest(c, "error while authorizing")
+	}
+	if err != nil || user == nil || user.Password == "" {
+		if wait = a.recordLoginFailure(ctx, c.IP(), username); wait > 0 {
+			return TooManyRequests(c, "too many failed login attempts, try again later", wait)
+		}
+		return BadRequest(c, "incorrect username or password")
+	}
+	if user.DisabledAt != nil {
+		return Forbidden(c, "account disabled")
 	}
 
-	// manage session creation and cookie.
-	token := uuid.New().String()
-	csrf := uuid.New().String()
-	expiration := time.Now().Add(TimeWeek)
+	// with two-factor authentication, the session is only created once a code is given for the challenge.
+	// (the lockout is only reset then, so the password alone cannot be used to keep guessing codes)
+	if user.TotpEnabled {
+		return a.startLoginChallenge(c, user)
+	}
 
-	_, err = a.Client.Session.Create().
-		SetToken(token).
-		SetUser(user).
-		SetCsrf(csrf).
-		SetExpiration(expiration).
-		Save(ctx)
-	if err != nil {
+	if err = a.Throttles.Reset(ctx, loginAccountKey(username)); err != nil {
+		LogError("Login", "reset lockout", err)
+	}
+
+	if err = a.startSession(c, user); err != nil {
 		LogError("Login", "create session", err)
 		return InternalServerError(c, "error creating session")
 	}
-	SetSessionCookies(c, token, csrf, expiration, a.Env.SameSite, a.Env.Secure)
 
 	return c.Status(http.StatusCreated).JSON(fiber.Map{
 		"acknowledged": true,
@@ -145,6 +173,51 @@ func (a *Actions) Login(c *fiber.Ctx, username, password string) error {
 	})
 }
 
+// startSession creates a new session for the user and sets the Authorization and Csrf cookies.
+// every session gets its own Csrf token, so logging in always rotates it.
+func (a *Actions) startSession(c *fiber.Ctx, user *ent.User) error {
+	token := uuid.New().String()
+	csrf := uuid.New().String()
+	expiration := time.Now().Add(TimeWeek)
+
+	userAgent := c.Get(fiber.HeaderUserAgent)
+	if len(userAgent) > 512 {
+		userAgent = userAgent[:512]
+	}
+
+	_, err := a.Client.Session.Create().
+		SetToken(token).
+		SetUser(user).
+		SetCsrf(csrf).
+		SetExpiration(expiration).
+		SetUserAgent(userAgent).
+		SetIP(c.IP()).
+		Save(c.Context())
+	if err != nil {
+		return err
+	}
+
+	SetSessionCookies(c, token, csrf, expiration, a.Env.SameSite, a.Env.Secure)
+	return nil
+}
+
+// rotateCSRF gives the session a new Csrf token and sets the Csrf cookie, i.e. after a change of privileges;
+// so a token leaked before the change cannot be used after it.
+func (a *Actions) rotateCSRF(c *fiber.Ctx, session *ent.Session) error {
+	csrf := uuid.New().String()
+
+	err := a.Client.Session.
+		UpdateOneID(session.ID).
+		SetCsrf(csrf).
+		Exec(c.Context())
+	if err != nil {
+		return err
+	}
+
+	SetSessionCookies(c, session.Token, csrf, session.Expiration, a.Env.SameSite, a.Env.Secure)
+	return nil
+}
+
 // Logout deletes the session and clears the Authorization cookie.
 // returns a 204 if the session is deleted.
 func (a *Actions) Logout(c *fiber.Ctx) error {
@@ -186,20 +259,114 @@ func (a *Actions) Authenticate(c *fiber.Ctx) error {
 	SetSessionCookies(c, session.Token, session.Csrf, expiration, a.Env.SameSite, a.Env.Secure)
 
 	// check for spotify link.
-	exists, err := a.Client.SpotifyLink.
+	link, err := a.Client.SpotifyLink.
 		Query().
 		Where(SpotifyLink.UserIDEQ(session.UserID)).
-		Exist(ctx)
-	if err != nil {
+		First(ctx)
+	if err != nil && !ent.IsNotFound(err) {
 		LogError("Authenticate", "check spotify link", err)
 		return InternalServerError(c, "error checking spotify account")
 	}
 
 	return c.Status(http.StatusOK).JSON(fiber.Map{
 		"user": fiber.Map{
-			"username": user.Username,
-			"email":    user.Email,
-			"spotify":  exists,
+			"username":       user.Username,
+			"email":          user.Email,
+			"email_verified": user.EmailVerified,
+			"two_factor":     user.TotpEnabled,
+			"has_password":   user.Password != "",
+			"role":           user.Role,
+			"spotify":        link != nil && !link.Revoked,
+			// revoked links have to be linked again.
+			"spotify_revoked": link != nil && link.Revoked,
 		},
 	})
 }
+
+// GetSessions lists the active sessions of the user, most recently used first.
+// returns a 200 with the sessions; the one making the request is marked as current.
+func (a *Actions) GetSessions(c *fiber.Ctx) error {
+	session := c.Locals("session").(*ent.Session)
+
+	sessions, err := a.Client.Session.
+		Query().
+		Where(
+			Session.UserIDEQ(session.UserID),
+			Session.ExpirationGT(time.Now()),
+		).
+		Order(ent.Desc(Session.FieldLastSeen)).
+		All(c.Context())
+	if err != nil {
+		LogError("GetSessions", "query sessions", err)
+		return InternalServerError(c, "error getting sessions")
+	}
+
+	// tokens are never sent back; sessions are referred to by id.
+	response := make([]fiber.Map, len(sessions))
+	for i, s := range sessions {
+		response[i] = fiber.Map{
+			"id":         s.ID,
+			"user_agent": s.UserAgent,
+			"ip":         s.IP,
+			"created_at": s.CreatedAt,
+			"last_seen":  s.LastSeen,
+			"expiration": s.Expiration,
+			"current":    s.ID == session.ID,
+		}
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"sessions": response,
+	})
+}
+
+// RevokeSession deletes a session of the user, i.e. one left open on a shared computer.
+// revoking the current session logs the user out.
+// returns a 404 if the user has no session with this id.
+// returns a 204 if the session is deleted.
+func (a *Actions) RevokeSession(c *fiber.Ctx, id int) error {
+	session := c.Locals("session").(*ent.Session)
+
+	// scoped to the user, so sessions of others cannot be revoked.
+	affected, err := a.Client.Session.
+		Delete().
+		Where(
+			Session.IDEQ(id),
+			Session.UserIDEQ(session.UserID),
+		).
+		Exec(c.Context())
+	if err != nil {
+		LogError("RevokeSession", "delete session", err)
+		return InternalServerError(c, "error revoking session")
+	} else if affected == 0 {
+		return BadRequest(c, "session not found", http.StatusNotFound)
+	}
+
+	if id == session.ID {
+		ExpireSessionCookies(c, a.Env.SameSite, a.Env.Secure)
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// RevokeOtherSessions deletes every session of the user except the one making the request.
+// returns a 200 with the number of revoked sessions.
+func (a *Actions) RevokeOtherSessions(c *fiber.Ctx) error {
+	session := c.Locals("session").(*ent.Session)
+
+	affected, err := a.Client.Session.
+		Delete().
+		Where(
+			Session.UserIDEQ(session.UserID),
+			Session.IDNEQ(session.ID),
+		).
+		Exec(c.Context())
+	if err != nil {
+		LogError("RevokeOtherSessions", "delete sessions", err)
+		return InternalServerError(c, "error revoking sessions")
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"acknowledged": true,
+		"revoked":      affected,
+	})
+}
@@ -1,18 +1,15 @@
 package actions
 
 import (
-	"encoding/base64"
-	"encoding/json"
 	"errors"
-	"github.com/go-resty/resty/v2"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"groove/pkgs/ent"
 	OAuthState "groove/pkgs/ent/oauthstate"
 	SpotifyLink "groove/pkgs/ent/spotifylink"
+	User "groove/pkgs/ent/user"
 	. "groove/pkgs/util"
 	"net/http"
-	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -28,6 +25,14 @@ var (
 		"user-library-read",
 		"user-library-modify",
 	}
+	// spotifyAppsURL is where users remove the access of Groove to their Spotify account;
+	// Spotify has no endpoint for an app to revoke its own tokens.
+	spotifyAppsURL = "https://www.spotify.com/account/apps/"
+	// codeFailures are the failures of exchanging the authorization code of a callback;
+	// Spotify answers invalid_grant (400) to a code that expired or was already used.
+	codeFailures = Failures{
+		http.StatusBadRequest: "invalid or expired authorization code",
+	}
 )
 
 // LinkSpotify creates a SpotifyLink and sends Spotify
@@ -61,8 +66,7 @@ func (a *Actions) LinkSpotify(c *fiber.Ctx) error {
 		return InternalServerError(c, "error linking spotify")
 	}
 
-	baseURL, _ := url.Parse("https://accounts.spotify.com/authorize")
-	baseURL.RawQuery = URLSearchParams(Params{
+	authorizeURL := a.Spotify.AuthorizeURL(Params{
 		"response_type": "code",
 		"client_id":     a.Env.SpotifyClient,
 		"scope":         strings.Join(scopes, " "),
@@ -71,7 +75,7 @@ func (a *Actions) LinkSpotify(c *fiber.Ctx) error {
 		"access_type":   accessType,
 	})
 
-	return c.Status(http.StatusOK).SendString(baseURL.String())
+	return c.Status(http.StatusOK).SendString(authorizeURL)
 }
 
 // SpotifyCallback handles the redirect from the Spotify Authorization page.
@@ -116,44 +120,60 @@ func (a *Actions) SpotifyCallback(c *fiber.Ctx, code, state string) error {
 		// no need to alert Client background worker will handle it.
 	}
 
-	// create base64 encoded string of Client id and secret. (as per spotify docs)
-	credentials := a.Env.SpotifyClient + ":" + a.Env.SpotifySecret
-	encodedCredentials := base64.StdEncoding.EncodeToString([]byte(credentials))
-
 	// retrieve access token and refresh token from spotify.
-	resp, err := resty.New().R().
-		SetHeaders(Headers{
-			"Content-Type":  "application/x-www-Form-urlencoded",
-			"Authorization": "Basic " + encodedCredentials,
-		}).
-		SetFormData(Form{
-			"code":         code,
-			"redirect_uri": a.Env.BackendURL + "/api/spotify/callback",
-			"grant_type":   "authorization_code",
-		}).
-		Post(SpotifyAccountsAPI + "/token")
+	tokens, err := a.Spotify.ExchangeCode(ctx, code, a.Env.BackendURL+"/api/spotify/callback")
+	if err != nil {
+		return SpotifyError(c, "SpotifyCallback", err, codeFailures)
+	}
+
+	// the spotify user is remembered, so the account can also log in with spotify.
+	profile, err := a.Spotify.GetCurrentUser(ctx, tokens.AccessToken)
+	if err != nil {
+		return SpotifyError(c, "SpotifyCallback", err, nil)
+	}
+
+	taken, err := a.Client.User.
+		Query().
+		Where(
+			User.SpotifyIDEQ(profile.ID),
+			User.IDNEQ(session.UserID),
+		).
+		Exist(ctx)
 	if err != nil {
-		LogError("SpotifyCallback", "Requesting token", err)
-		return InternalServerError(c, "error requesting token")
+		LogError("SpotifyCallback", "Checking spotify user", err)
+		return InternalServerError(c, "error linking spotify")
+	} else if taken {
+		return BadRequest(c, "spotify account already linked to another account")
 	}
 
-	type TokenResponse struct {
-		AccessToken  string `json:"access_token"`
-		RefreshToken string `json:"refresh_token"`
+	err = a.Client.User.
+		UpdateOneID(session.UserID).
+		SetSpotifyID(profile.ID).
+		Exec(ctx)
+	if err != nil {
+		LogError("SpotifyCallback", "Updating user", err)
+		return InternalServerError(c, "error linking spotify")
 	}
 
-	payload := new(TokenResponse)
-	if json.Unmarshal(resp.Body(), payload) != nil {
-		LogError("SpotifyCallback", "Unmarshalling token", err)
-		return InternalServerError(c, "error unmarshalling token")
+	// replace a revoked link, if the user is linking their account again.
+	_, err = a.Client.SpotifyLink.
+		Delete().
+		Where(
+			SpotifyLink.UserIDEQ(session.UserID),
+			SpotifyLink.RevokedEQ(true),
+		).
+		Exec(ctx)
+	if err != nil {
+		LogError("SpotifyCallback", "Deleting revoked spotify link", err)
+		return InternalServerError(c, "error linking spotify")
 	}
 
 	// save access token and refresh token as SpotifyLink.
 	_, err = a.Client.SpotifyLink.Create().
-		SetAccessToken(payload.AccessToken).
+		SetAccessToken(tokens.AccessToken).
 		// Spotify's Access-Token expire after 1 hour, so we set the expiration to 58 minutes to be safe.
 		SetAccessTokenExpiration(time.Now().Add(Time58Minutes)).
-		SetRefreshToken(payload.RefreshToken).
+		SetRefreshToken(tokens.RefreshToken).
 		SetUserID(session.UserID).
 		Save(ctx)
 	if err != nil {
@@ -165,11 +185,20 @@ func (a *Actions) SpotifyCallback(c *fiber.Ctx, code, state string) error {
 }
 
 // UnlinkSpotify deletes the SpotifyLink for the user.
+// Returns 400 if the account has no password, as it could not be logged in to anymore.
 // Returns 204 no content if successful.
 func (a *Actions) UnlinkSpotify(c *fiber.Ctx) error {
 	session := c.Locals("session").(*ent.Session)
 	ctx := c.Context()
 
+	user, err := a.Client.User.Get(ctx, session.UserID)
+	if err != nil {
+		LogError("UnlinkSpotify", "Checking user", err)
+		return InternalServerError(c, "error getting account")
+	} else if user.Password == "" {
+		return BadRequest(c, "set a password before unlinking spotify")
+	}
+
 	// ensure user has a linked spotify account.
 	link, err := a.Client.SpotifyLink.
 		Query().
@@ -189,6 +218,12 @@ func (a *Actions) UnlinkSpotify(c *fiber.Ctx) error {
 		return InternalServerError(c, "error unlinking spotify")
 	}
 
+	// the account can no longer log in with spotify.
+	if err = user.Update().ClearSpotifyID().Exec(ctx); err != nil {
+		LogError("UnlinkSpotify", "Updating user", err)
+		return InternalServerError(c, "error unlinking spotify")
+	}
+
 	return c.SendStatus(http.StatusNoContent)
 }
 
@@ -199,25 +234,12 @@ func (a *Actions) GetCurrentUser(c *fiber.Ctx) error {
 	access := c.Locals("access").(string)
 
 	// grab current user's ID from Spotify.
-	resp, err := resty.New().R().
-		SetHeaders(Headers{
-			"Authorization": "Bearer " + access,
-		}).
-		Get(SpotifyAPI + "/me")
+	user, err := a.Spotify.GetCurrentUser(c.Context(), access)
 	if err != nil {
-		LogError("GetCurrentUser", "Requesting current user", err)
-		return InternalServerError(c, "error getting current user")
-	}
-
-	type CurrentUserResponse struct {
-		ID string `json:"id"`
+		return SpotifyError(c, "GetCurrentUser", err, nil)
 	}
 
-	payload := new(CurrentUserResponse)
-	if json.Unmarshal(resp.Body(), payload) != nil {
-		LogError("GetCurrentUser", "Unmarshalling current user", err)
-		return InternalServerError(c, "error getting current user")
-	}
-
-	return c.Status(http.StatusOK).JSON(payload)
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"id": user.ID,
+	})
 }
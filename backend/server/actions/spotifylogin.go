@@ -0,0 +1,291 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"groove/pkgs/ent"
+	OAuthState "groove/pkgs/ent/oauthstate"
+	SpotifyLink "groove/pkgs/ent/spotifylink"
+	User "groove/pkgs/ent/user"
+	"groove/pkgs/spotify"
+	. "groove/pkgs/util"
+	"math/rand"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// spotifyStateCookie binds the state of a login with Spotify to the browser that started it,
+// since there is no session yet to bind it to; otherwise anyone could log a victim into their own account.
+const spotifyStateCookie = "SpotifyState"
+
+// loginScopes are the scopes asked for when logging in with Spotify; the email is needed to create the account.
+var loginScopes = append([]string{"user-read-email"}, scopes...)
+
+// usernameUnsafe matches what is stripped from Spotify display names to make a username.
+var usernameUnsafe = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// LoginWithSpotify starts a login with Spotify, for users with or without a Groove account,
+// and sends the Spotify Authorization page that the Client will redirect the user to.
+// Returns 200 if successful.
+func (a *Actions) LoginWithSpotify(c *fiber.Ctx) error {
+	ctx := c.Context()
+
+	// generate a random 16 character string for the state parameter.
+	state := strings.ReplaceAll(uuid.New().String(), "-", "")[:16]
+	expiration := time.Now().Add(30 * time.Minute)
+
+	// set state in OAuth-Store for later verification.
+	_, err := a.Client.OAuthState.Create().
+		SetState(state).
+		SetExpiration(expiration).
+		Save(ctx)
+	if err != nil {
+		LogError("LoginWithSpotify", "Creating OAuthState", err)
+		return InternalServerError(c, "error logging in with spotify")
+	}
+
+	c.Cookie(&fiber.Cookie{
+		Name:     spotifyStateCookie,
+		Value:    state,
+		Path:     "/api/spotify/login",
+		Expires:  expiration,
+		HTTPOnly: true,
+		// Lax, as the cookie has to be sent along the redirect from Spotify back to the callback.
+		SameSite: fiber.CookieSameSiteLaxMode,
+		Secure:   a.Env.Secure,
+	})
+
+	authorizeURL := a.Spotify.AuthorizeURL(Params{
+		"response_type": "code",
+		"client_id":     a.Env.SpotifyClient,
+		"scope":         strings.Join(loginScopes, " "),
+		"redirect_uri":  a.Env.BackendURL + "/api/spotify/login/callback",
+		"state":         state,
+		"access_type":   accessType,
+	})
+
+	return c.Status(http.StatusOK).SendString(authorizeURL)
+}
+
+// SpotifyLoginCallback handles the redirect from the Spotify Authorization page of a login with Spotify.
+// It finds the Groove account of the Spotify user, or creates one, saves the tokens as its SpotifyLink and
+// creates a new session; then redirects to the dashboard.
+// Users with two-factor authentication are redirected to the login page instead, with the challenge in an
+// HttpOnly cookie (see LoginTwoFactor); the tokens are kept with the challenge until it is completed.
+// Returns 302 if successful.
+func (a *Actions) SpotifyLoginCallback(c *fiber.Ctx, code, state string) error {
+	ctx := c.Context()
+
+	// verify state to prevent CSRF.
+	if c.Cookies(spotifyStateCookie) != state {
+		LogError(
+			"SpotifyLoginCallback",
+			"Potential CSRF Attempt",
+			errors.New("state mismatch for login with spotify"),
+		)
+		return Forbidden(c, "state mismatch")
+	}
+	c.Cookie(&fiber.Cookie{
+		Name:     spotifyStateCookie,
+		Path:     "/api/spotify/login",
+		Expires:  time.Now().Add(-1 * time.Hour),
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+		Secure:   a.Env.Secure,
+	})
+
+	// use up the state; if nothing is deleted, it is unknown, expired or was already used.
+	affected, err := a.Client.OAuthState.
+		Delete().
+		Where(
+			OAuthState.StateEQ(state),
+			OAuthState.UserIDIsNil(),
+			OAuthState.ExpirationGT(time.Now()),
+		).
+		Exec(ctx)
+	if err != nil {
+		LogError("SpotifyLoginCallback", "Deleting state", err)
+		return InternalServerError(c, "error logging in with spotify")
+	} else if affected == 0 {
+		return Unauthorized(c, "unidentified or expired state")
+	}
+
+	// retrieve access token and refresh token from spotify.
+	tokens, err := a.Spotify.ExchangeCode(ctx, code, a.Env.BackendURL+"/api/spotify/login/callback")
+	if err != nil {
+		return SpotifyError(c, "SpotifyLoginCallback", err, codeFailures)
+	}
+
+	profile, err := a.Spotify.GetCurrentUser(ctx, tokens.AccessToken)
+	if err != nil {
+		return SpotifyError(c, "SpotifyLoginCallback", err, nil)
+	}
+
+	user, err := a.Client.User.
+		Query().
+		Where(User.SpotifyIDEQ(profile.ID)).
+		First(ctx)
+	if err != nil && !ent.IsNotFound(err) {
+		LogError("SpotifyLoginCallback", "Checking user", err)
+		return InternalServerError(c, "error getting account")
+	}
+	if user != nil && user.DisabledAt != nil {
+		return Forbidden(c, "account disabled")
+	}
+
+	// with two-factor authentication, nothing is saved nor a session created until a code is given.
+	if user != nil && user.TotpEnabled {
+		challenge, err := a.newLoginChallenge(ctx, user, tokens)
+		if err != nil {
+			LogError("SpotifyLoginCallback", "Creating challenge", err)
+			return InternalServerError(c, "error creating session")
+		}
+		// the challenge is kept out of the url, which would leak it to the history, referers and logs.
+		c.Cookie(&fiber.Cookie{
+			Name:     loginChallengeCookie,
+			Value:    challenge,
+			Path:     "/api/login/2fa",
+			Expires:  time.Now().Add(loginChallengeTTL),
+			HTTPOnly: true,
+			SameSite: a.Env.SameSite,
+			Secure:   a.Env.Secure,
+		})
+		return c.Redirect(a.Env.FrontendURL+"/login?two_factor=true", http.StatusFound)
+	}
+
+	// a new account is checked before the transaction, which only has to create it.
+	created := user == nil
+	var email, username string
+	if created {
+		email = strings.ToLower(profile.Email)
+		if err = User.EmailValidator(email); err != nil {
+			return BadRequest(c, "spotify account has no valid email")
+		}
+
+		// accounts are not merged by email, as Spotify doesn't guarantee it is verified.
+		exists, err := a.Client.User.
+			Query().
+			Where(User.EmailEQ(email)).
+			Exist(ctx)
+		if err != nil {
+			LogError("SpotifyLoginCallback", "Checking email", err)
+			return InternalServerError(c, "error checking email")
+		} else if exists {
+			return BadRequest(c, "an account with this email already exists, log in and link spotify instead")
+		}
+
+		if username, err = a.spotifyUsername(ctx, profile); err != nil {
+			LogError("SpotifyLoginCallback", "Choosing username", err)
+			return InternalServerError(c, "error creating account")
+		}
+	}
+
+	// the account and its link are created together, so a failure doesn't leave an account without a way in.
+	tx, err := a.Client.Tx(ctx)
+	if err != nil {
+		LogError("SpotifyLoginCallback", "Beginning transaction", err)
+		return InternalServerError(c, "error linking spotify")
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if created {
+		// the account has no password until the user sets one.
+		user, err = tx.User.Create().
+			SetEmail(email).
+			SetPassword("").
+			SetUsername(username).
+			SetSpotifyID(profile.ID).
+			Save(ctx)
+		if err != nil {
+			LogError("SpotifyLoginCallback", "Creating user", err)
+			return InternalServerError(c, "error creating account")
+		}
+	}
+
+	if err = replaceSpotifyLink(ctx, tx, user.ID, tokens.AccessToken, tokens.RefreshToken); err != nil {
+		LogError("SpotifyLoginCallback", "Replacing spotify link", err)
+		return InternalServerError(c, "error linking spotify")
+	}
+
+	if err = tx.Commit(); err != nil {
+		LogError("SpotifyLoginCallback", "Committing", err)
+		return InternalServerError(c, "error linking spotify")
+	}
+
+	if created {
+		if err = a.sendVerification(ctx, user, user.Email); err != nil {
+			LogError("SpotifyLoginCallback", "Sending verification", err)
+			// no need to fail the login, the user can ask for another email.
+		}
+	}
+
+	if err = a.startSession(c, user); err != nil {
+		LogError("SpotifyLoginCallback", "Creating session", err)
+		return InternalServerError(c, "error creating session")
+	}
+
+	return c.Redirect(a.Env.FrontendURL+"/dashboard", http.StatusFound)
+}
+
+// replaceSpotifyLink saves the tokens of a login with Spotify as the SpotifyLink of the user,
+// replacing the current link, revoked or not.
+func replaceSpotifyLink(ctx context.Context, tx *ent.Tx, userID int, access, refresh string) error {
+	_, err := tx.SpotifyLink.
+		Delete().
+		Where(SpotifyLink.UserIDEQ(userID)).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	return tx.SpotifyLink.Create().
+		SetAccessToken(access).
+		// Spotify's Access-Token expire after 1 hour, so we set the expiration to 58 minutes to be safe.
+		SetAccessTokenExpiration(time.Now().Add(Time58Minutes)).
+		SetRefreshToken(refresh).
+		SetUserID(userID).
+		Exec(ctx)
+}
+
+// spotifyUsername picks an available username for an account created by logging in with Spotify,
+// based on the display name (or id) of the Spotify user. it can be changed later on.
+func (a *Actions) spotifyUsername(ctx context.Context, profile *spotify.PrivateUser) (string, error) {
+	var base string
+	if profile.DisplayName != nil {
+		base = usernameUnsafe.ReplaceAllString(*profile.DisplayName, "")
+	}
+	if len(base) < 4 {
+		base = usernameUnsafe.ReplaceAllString(profile.ID, "")
+	}
+	if len(base) < 4 {
+		base = "listener"
+	}
+	if len(base) > 16 {
+		base = base[:16]
+	}
+
+	username := base
+	for attempt := 0; attempt < 10; attempt++ {
+		exists, err := a.Client.User.
+			Query().
+			Where(User.UsernameEQ(username)).
+			Exist(ctx)
+		if err != nil {
+			return "", err
+		} else if !exists {
+			return username, nil
+		}
+
+		// make room for a random suffix within the 16 characters.
+		if len(base) > 12 {
+			base = base[:12]
+		}
+		username = fmt.Sprintf("%s%04d", base, rand.Intn(10000))
+	}
+	return "", errors.New("no available username for " + profile.ID)
+}
@@ -0,0 +1,82 @@
+package actions
+
+import (
+	"context"
+	"golang.org/x/crypto/bcrypt"
+	"groove/pkgs/db"
+	. "groove/pkgs/util"
+	"time"
+)
+
+var (
+	// loginAccountPolicy locks out an account after a few failed logins, whoever they come from.
+	loginAccountPolicy = db.Policy{Free: 5, Base: 30 * time.Second, Max: time.Hour, Window: db.ThrottleWindow}
+	// loginIPPolicy locks out an IP trying many accounts; it is more lenient, as an IP may be shared by many users.
+	loginIPPolicy = db.Policy{Free: 20, Base: 30 * time.Second, Max: time.Hour, Window: db.ThrottleWindow}
+	// registerIPPolicy limits how many accounts can be created from an IP.
+	registerIPPolicy = db.Policy{Free: 5, Base: time.Hour, Max: db.ThrottleWindow, Window: db.ThrottleWindow}
+	// forgotEmailPolicy limits how many reset links can be emailed to an address.
+	forgotEmailPolicy = db.Policy{Free: 3, Base: 15 * time.Minute, Max: db.ThrottleWindow, Window: db.ThrottleWindow}
+	// forgotIPPolicy limits how many reset links an IP can have emailed, whichever the addresses.
+	forgotIPPolicy = db.Policy{Free: 10, Base: 15 * time.Minute, Max: db.ThrottleWindow, Window: db.ThrottleWindow}
+)
+
+// dummyHash is compared against when logging in to an account that doesn't exist (or has no password),
+// so it takes as long as logging in to one that does.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not the password of any account"), 10)
+
+func loginIPKey(ip string) string {
+	return "login:ip:" + ip
+}
+
+// loginAccountKey is keyed by username rather than user, so unknown usernames are locked out alike
+// and lockouts don't tell which usernames exist.
+func loginAccountKey(username string) string {
+	return "login:account:" + username
+}
+
+func registerIPKey(ip string) string {
+	return "register:ip:" + ip
+}
+
+func forgotIPKey(ip string) string {
+	return "forgot:ip:" + ip
+}
+
+// forgotEmailKey is keyed by email whether or not it has an account, so lockouts don't tell which emails exist.
+func forgotEmailKey(email string) string {
+	return "forgot:email:" + email
+}
+
+// locked returns how long the longest lockout of the keys remains, or 0 if none is locked out.
+func (a *Actions) locked(ctx context.Context, keys ...string) (time.Duration, error) {
+	var longest time.Duration
+	for _, key := range keys {
+		wait, err := a.Throttles.Locked(ctx, key)
+		if err != nil {
+			return 0, err
+		}
+		if wait > longest {
+			longest = wait
+		}
+	}
+	return longest, nil
+}
+
+// recordLoginFailure records a failed login of the account from the IP,
+// returning how long either is now locked out for, or 0 if neither is.
+func (a *Actions) recordLoginFailure(ctx context.Context, ip, username string) time.Duration {
+	accountLock, err := a.Throttles.Record(ctx, loginAccountKey(username), loginAccountPolicy)
+	if err != nil {
+		LogError("recordLoginFailure", "record account attempt", err)
+	}
+	ipLock, err := a.Throttles.Record(ctx, loginIPKey(ip), loginIPPolicy)
+	if err != nil {
+		LogError("recordLoginFailure", "record ip attempt", err)
+	}
+
+	if ipLock > accountLock {
+		return ipLock
+	}
+	return accountLock
+}
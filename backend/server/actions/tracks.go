@@ -2,14 +2,20 @@ package actions
 
 import (
 	"github.com/gofiber/fiber/v2"
-	. "groove/pkgs/util"
+	"groove/pkgs/cache"
+	"net/http"
 )
 
+var trackFailures = Failures{
+	http.StatusBadRequest: "invalid track-id",
+	http.StatusNotFound:   "track not found",
+}
+
 // GetTrack returns a track object from the Spotify API.
 func (a *Actions) GetTrack(c *fiber.Ctx, trackID string) error {
-	proxy := Proxy{
-		Endpoint: "/tracks/" + trackID + "?market=US",
-		Access:   c.Locals("access").(string),
-	}
-	return proxy.TrackRequest(c)
+	access := c.Locals("access").(string)
+
+	return a.catalog(c, "GetTrack", cache.Key("/tracks/"+trackID, "US"), trackFailures, func() (any, error) {
+		return a.Spotify.GetTrack(c.Context(), access, trackID, "US")
+	})
 }
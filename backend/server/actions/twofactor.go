@@ -0,0 +1,484 @@
+package actions
+
+import (
+	"context"
+	"github.com/gofiber/fiber/v2"
+	"groove/pkgs/ent"
+	LoginChallenge "groove/pkgs/ent/loginchallenge"
+	RecoveryCode "groove/pkgs/ent/recoverycode"
+	User "groove/pkgs/ent/user"
+	"groove/pkgs/secret"
+	"groove/pkgs/spotify"
+	. "groove/pkgs/util"
+	"net/http"
+	"time"
+)
+
+const (
+	// loginChallengeTTL is how long a user has to give a code after their password was accepted.
+	loginChallengeTTL = 5 * time.Minute
+	// loginChallengeAttempts is how many codes can be tried per challenge before having to log in again.
+	loginChallengeAttempts = 5
+	// recoveryCodeCount is how many recovery codes are issued at once.
+	recoveryCodeCount = 10
+	// totpIssuer is the name authenticator apps show for the account.
+	totpIssuer = "Groove"
+	// loginChallengeCookie holds the challenge of a login with Spotify, which ends with a redirect
+	// rather than a response the Client could read it from.
+	loginChallengeCookie = "LoginChallenge"
+)
+
+// newLoginChallenge creates a login challenge for a user with two-factor authentication, returning its token.
+// the tokens of a login with Spotify are kept with it, to be saved once it is completed; nil otherwise.
+func (a *Actions) newLoginChallenge(ctx context.Context, user *ent.User, tokens *spotify.Tokens) (string, error) {
+	token, err := secret.NewToken()
+	if err != nil {
+		return "", err
+	}
+
+	create := a.Client.LoginChallenge.Create().
+		SetUserID(user.ID).
+		SetTokenHash(secret.HashToken(token)).
+		SetExpiration(time.Now().Add(loginChallengeTTL))
+	if tokens != nil {
+		create.
+			SetSpotifyAccessToken(tokens.AccessToken).
+			SetSpotifyRefreshToken(tokens.RefreshToken)
+	}
+	err = create.Exec(ctx)
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// startLoginChallenge creates a login challenge for a user with two-factor authentication, in place of a session.
+// returns a 200 with the challenge token, to be sent to LoginTwoFactor along with a code.
+func (a *Actions) startLoginChallenge(c *fiber.Ctx, user *ent.User) error {
+	token, err := a.newLoginChallenge(c.Context(), user, nil)
+	if err != nil {
+		LogError("Login", "create challenge", err)
+		return InternalServerError(c, "error creating session")
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"acknowledged": true,
+		"two_factor":   true,
+		"challenge":    token,
+		"message":      "enter the code from your authenticator app or a recovery code",
+	})
+}
+
+// LoginTwoFactor completes the login of a user with two-factor authentication, using the challenge returned
+// by Login and either a TOTP code or a recovery code; then creates a new session and sets Authorization cookie.
+// without a challenge, the one set as a cookie by SpotifyLoginCallback is used, whose Spotify tokens are
+// only saved as the SpotifyLink of the user at this point.
+// returns a 400 if the challenge is invalid, expired or out of attempts, or the code is incorrect.
+// returns a 429 if the IP or the account is locked out; see Login.
+// returns a 403 if the account is disabled by an admin.
+// returns a 201 if the session is created.
+func (a *Actions) LoginTwoFactor(c *fiber.Ctx, challenge, code string) error {
+	ctx := c.Context()
+	if challenge == "" {
+		challenge = c.Cookies(loginChallengeCookie)
+	}
+	pending, err := a.Client.LoginChallenge.
+		Query().
+		Where(
+			LoginChallenge.TokenHashEQ(secret.HashToken(challenge)),
+			LoginChallenge.ExpirationGT(time.Now()),
+		).
+		WithUser().
+		First(ctx)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return BadRequest(c, "invalid or expired login, log in again")
+		}
+		LogError("LoginTwoFactor", "check challenge", err)
+		return InternalServerError(c, "error while authorizing")
+	}
+
+	// use up an attempt first, so concurrent requests cannot try more codes than allowed.
+	affected, err := a.Client.LoginChallenge.
+		Update().
+		Where(
+			LoginChallenge.IDEQ(pending.ID),
+			LoginChallenge.AttemptsLT(loginChallengeAttempts),
+		).
+		AddAttempts(1).
+		Save(ctx)
+	if err != nil {
+		LogError("LoginTwoFactor", "update challenge", err)
+		return InternalServerError(c, "error while authorizing")
+	} else if affected == 0 {
+		_ = a.Client.LoginChallenge.DeleteOneID(pending.ID).Exec(ctx)
+		return BadRequest(c, "too many incorrect codes, log in again")
+	}
+
+	user := pending.Edges.User
+	if !user.TotpEnabled {
+		// two-factor authentication was disabled since the challenge was issued.
+		return BadRequest(c, "invalid or expired login, log in again")
+	}
+	if user.DisabledAt != nil {
+		return Forbidden(c, "account disabled")
+	}
+
+	// codes count as login failures too; otherwise the password would get unlimited challenges to guess them.
+	wait, err := a.locked(ctx, loginIPKey(c.IP()), loginAccountKey(user.Username))
+	if err != nil {
+		LogError("LoginTwoFactor", "check lockout", err)
+		return InternalServerError(c, "error while authorizing")
+	} else if wait > 0 {
+		return TooManyRequests(c, "too many failed login attempts, try again later", wait)
+	}
+
+	ok, err := a.checkSecondFactor(ctx, user, code)
+	if err != nil {
+		LogError("LoginTwoFactor", "check code", err)
+		return InternalServerError(c, "error while authorizing")
+	} else if !ok {
+		if wait = a.recordLoginFailure(ctx, c.IP(), user.Username); wait > 0 {
+			return TooManyRequests(c, "too many failed login attempts, try again later", wait)
+		}
+		return BadRequest(c, "incorrect code")
+	}
+
+	if err = a.Client.LoginChallenge.DeleteOneID(pending.ID).Exec(ctx); err != nil {
+		if ent.IsNotFound(err) {
+			// completed by a concurrent request.
+			return BadRequest(c, "invalid or expired login, log in again")
+		}
+		LogError("LoginTwoFactor", "delete challenge", err)
+		return InternalServerError(c, "error while authorizing")
+	}
+
+	if err = a.Throttles.Reset(ctx, loginAccountKey(user.Username)); err != nil {
+		LogError("LoginTwoFactor", "reset lockout", err)
+	}
+
+	if pending.SpotifyAccessToken != "" {
+		if err = a.saveLoginLink(ctx, pending); err != nil {
+			LogError("LoginTwoFactor", "replace spotify link", err)
+			return InternalServerError(c, "error linking spotify")
+		}
+		c.Cookie(&fiber.Cookie{
+			Name:     loginChallengeCookie,
+			Path:     "/api/login/2fa",
+			Expires:  time.Now().Add(-1 * time.Hour),
+			HTTPOnly: true,
+			SameSite: a.Env.SameSite,
+			Secure:   a.Env.Secure,
+		})
+	}
+
+	if err = a.startSession(c, user); err != nil {
+		LogError("LoginTwoFactor", "create session", err)
+		return InternalServerError(c, "error creating session")
+	}
+
+	return c.Status(http.StatusCreated).JSON(fiber.Map{
+		"acknowledged": true,
+		"user": fiber.Map{
+			"username": user.Username,
+			"email":    user.Email,
+		},
+	})
+}
+
+// saveLoginLink saves the Spotify tokens of a completed login challenge as the SpotifyLink of its user.
+func (a *Actions) saveLoginLink(ctx context.Context, pending *ent.LoginChallenge) error {
+	tx, err := a.Client.Tx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	err = replaceSpotifyLink(ctx, tx, pending.UserID, pending.SpotifyAccessToken, pending.SpotifyRefreshToken)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// checkSecondFactor checks a TOTP code or a recovery code of the user, using it up if it is correct.
+func (a *Actions) checkSecondFactor(ctx context.Context, user *ent.User, code string) (bool, error) {
+	if len(code) == 6 {
+		step, ok := secret.ValidateTOTP(user.TotpSecret, code, time.Now())
+		if !ok {
+			return false, nil
+		}
+		// only accept codes newer than the last one used, so an intercepted code cannot be replayed.
+		affected, err := a.Client.User.
+			Update().
+			Where(
+				User.IDEQ(user.ID),
+				User.TotpLastStepLT(step),
+			).
+			SetTotpLastStep(step).
+			Save(ctx)
+		return affected > 0, err
+	}
+
+	affected, err := a.Client.RecoveryCode.
+		Delete().
+		Where(
+			RecoveryCode.UserIDEQ(user.ID),
+			RecoveryCode.CodeHashEQ(secret.HashToken(secret.NormalizeRecoveryCode(code))),
+		).
+		Exec(ctx)
+	return affected > 0, err
+}
+
+// EnrollTwoFactor generates a new TOTP secret for the user, which is only used once confirmed with ConfirmTwoFactor.
+// returns a 400 if two-factor authentication is already enabled.
+// returns a 200 with the secret and its otpauth:// URI.
+func (a *Actions) EnrollTwoFactor(c *fiber.Ctx) error {
+	session := c.Locals("session").(*ent.Session)
+	ctx := c.Context()
+
+	user, err := a.Client.User.Get(ctx, session.UserID)
+	if err != nil {
+		LogError("EnrollTwoFactor", "check user", err)
+		return InternalServerError(c, "error getting account")
+	}
+	if user.TotpEnabled {
+		return BadRequest(c, "two-factor authentication already enabled")
+	}
+
+	totpSecret, err := secret.NewTOTPSecret()
+	if err != nil {
+		LogError("EnrollTwoFactor", "generate secret", err)
+		return InternalServerError(c, "error enabling two-factor authentication")
+	}
+
+	// enrolling again replaces the secret of an unconfirmed enrollment.
+	if err = user.Update().SetTotpSecret(totpSecret).Exec(ctx); err != nil {
+		LogError("EnrollTwoFactor", "update user", err)
+		return InternalServerError(c, "error enabling two-factor authentication")
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"acknowledged": true,
+		"message":      "add the account to your authenticator app, then confirm with a code",
+		"secret":       totpSecret,
+		"uri":          secret.TOTPURI(totpIssuer, user.Username, totpSecret),
+	})
+}
+
+// ConfirmTwoFactor enables two-factor authentication using a first code of the enrolled secret.
+// returns a 400 if two-factor authentication is already enabled or not enrolled, or the code is incorrect.
+// returns a 200 with the recovery codes, which are not shown again.
+func (a *Actions) ConfirmTwoFactor(c *fiber.Ctx, code string) error {
+	session := c.Locals("session").(*ent.Session)
+	ctx := c.Context()
+
+	user, err := a.Client.User.Get(ctx, session.UserID)
+	if err != nil {
+		LogError("ConfirmTwoFactor", "check user", err)
+		return InternalServerError(c, "error getting account")
+	}
+	if user.TotpEnabled {
+		return BadRequest(c, "two-factor authentication already enabled")
+	} else if user.TotpSecret == "" {
+		return BadRequest(c, "two-factor authentication not enrolled")
+	}
+
+	step, ok := secret.ValidateTOTP(user.TotpSecret, code, time.Now())
+	if !ok {
+		return BadRequest(c, "incorrect code")
+	}
+
+	tx, err := a.Client.Tx(ctx)
+	if err != nil {
+		LogError("ConfirmTwoFactor", "begin transaction", err)
+		return InternalServerError(c, "error enabling two-factor authentication")
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// if nothing is updated, a concurrent request enabled it first.
+	affected, err := tx.User.
+		Update().
+		Where(
+			User.IDEQ(user.ID),
+			User.TotpEnabledEQ(false),
+		).
+		SetTotpEnabled(true).
+		SetTotpLastStep(step).
+		Save(ctx)
+	if err != nil {
+		LogError("ConfirmTwoFactor", "update user", err)
+		return InternalServerError(c, "error enabling two-factor authentication")
+	} else if affected == 0 {
+		return BadRequest(c, "two-factor authentication already enabled")
+	}
+
+	codes, err := replaceRecoveryCodes(ctx, tx, user.ID)
+	if err != nil {
+		LogError("ConfirmTwoFactor", "create recovery codes", err)
+		return InternalServerError(c, "error enabling two-factor authentication")
+	}
+
+	if err = tx.Commit(); err != nil {
+		LogError("ConfirmTwoFactor", "commit", err)
+		return InternalServerError(c, "error enabling two-factor authentication")
+	}
+
+	// a change of privileges rotates the Csrf token of the session.
+	if err = a.rotateCSRF(c, session); err != nil {
+		LogError("ConfirmTwoFactor", "rotate csrf", err)
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"acknowledged":   true,
+		"message":        "two-factor authentication enabled, keep your recovery codes somewhere safe",
+		"recovery_codes": codes,
+	})
+}
+
+// DisableTwoFactor disables two-factor authentication, deleting the secret and recovery codes of the user.
+// returns a 400 if two-factor authentication is not enabled or the password is incorrect.
+// returns a 429 if the account is locked out; see Login.
+// returns a 200 if two-factor authentication is disabled.
+func (a *Actions) DisableTwoFactor(c *fiber.Ctx, password string) error {
+	session := c.Locals("session").(*ent.Session)
+	ctx := c.Context()
+
+	user, err := a.Client.User.Get(ctx, session.UserID)
+	if err != nil {
+		LogError("DisableTwoFactor", "check user", err)
+		return InternalServerError(c, "error getting account")
+	}
+	if !user.TotpEnabled {
+		return BadRequest(c, "two-factor authentication not enabled")
+	}
+
+	// a stolen session alone should not be enough to turn it off.
+	if ok, err := a.confirmPassword(c, "DisableTwoFactor", user, password); !ok {
+		return err
+	}
+
+	tx, err := a.Client.Tx(ctx)
+	if err != nil {
+		LogError("DisableTwoFactor", "begin transaction", err)
+		return InternalServerError(c, "error disabling two-factor authentication")
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	err = tx.User.
+		UpdateOneID(user.ID).
+		ClearTotpSecret().
+		SetTotpEnabled(false).
+		SetTotpLastStep(0).
+		Exec(ctx)
+	if err != nil {
+		LogError("DisableTwoFactor", "update user", err)
+		return InternalServerError(c, "error disabling two-factor authentication")
+	}
+
+	_, err = tx.RecoveryCode.
+		Delete().
+		Where(RecoveryCode.UserIDEQ(user.ID)).
+		Exec(ctx)
+	if err != nil {
+		LogError("DisableTwoFactor", "delete recovery codes", err)
+		return InternalServerError(c, "error disabling two-factor authentication")
+	}
+
+	_, err = tx.LoginChallenge.
+		Delete().
+		Where(LoginChallenge.UserIDEQ(user.ID)).
+		Exec(ctx)
+	if err != nil {
+		LogError("DisableTwoFactor", "delete challenges", err)
+		return InternalServerError(c, "error disabling two-factor authentication")
+	}
+
+	if err = tx.Commit(); err != nil {
+		LogError("DisableTwoFactor", "commit", err)
+		return InternalServerError(c, "error disabling two-factor authentication")
+	}
+
+	// a change of privileges rotates the Csrf token of the session.
+	if err = a.rotateCSRF(c, session); err != nil {
+		LogError("DisableTwoFactor", "rotate csrf", err)
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"acknowledged": true,
+		"message":      "two-factor authentication disabled",
+	})
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the user, i.e. once most of them are used.
+// returns a 400 if two-factor authentication is not enabled or the password is incorrect.
+// returns a 429 if the account is locked out; see Login.
+// returns a 200 with the new recovery codes, which are not shown again.
+func (a *Actions) RegenerateRecoveryCodes(c *fiber.Ctx, password string) error {
+	session := c.Locals("session").(*ent.Session)
+	ctx := c.Context()
+
+	user, err := a.Client.User.Get(ctx, session.UserID)
+	if err != nil {
+		LogError("RegenerateRecoveryCodes", "check user", err)
+		return InternalServerError(c, "error getting account")
+	}
+	if !user.TotpEnabled {
+		return BadRequest(c, "two-factor authentication not enabled")
+	}
+
+	if ok, err := a.confirmPassword(c, "RegenerateRecoveryCodes", user, password); !ok {
+		return err
+	}
+
+	tx, err := a.Client.Tx(ctx)
+	if err != nil {
+		LogError("RegenerateRecoveryCodes", "begin transaction", err)
+		return InternalServerError(c, "error creating recovery codes")
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	codes, err := replaceRecoveryCodes(ctx, tx, user.ID)
+	if err != nil {
+		LogError("RegenerateRecoveryCodes", "create recovery codes", err)
+		return InternalServerError(c, "error creating recovery codes")
+	}
+
+	if err = tx.Commit(); err != nil {
+		LogError("RegenerateRecoveryCodes", "commit", err)
+		return InternalServerError(c, "error creating recovery codes")
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"acknowledged":   true,
+		"message":        "recovery codes replaced, the previous ones no longer work",
+		"recovery_codes": codes,
+	})
+}
+
+// replaceRecoveryCodes deletes the recovery codes of the user and creates new ones, returning them in plain text.
+func replaceRecoveryCodes(ctx context.Context, tx *ent.Tx, userID int) ([]string, error) {
+	_, err := tx.RecoveryCode.
+		Delete().
+		Where(RecoveryCode.UserIDEQ(userID)).
+		Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	builders := make([]*ent.RecoveryCodeCreate, recoveryCodeCount)
+	for i := range codes {
+		if codes[i], err = secret.NewRecoveryCode(); err != nil {
+			return nil, err
+		}
+		builders[i] = tx.RecoveryCode.Create().
+			SetUserID(userID).
+			SetCodeHash(secret.HashToken(secret.NormalizeRecoveryCode(codes[i])))
+	}
+
+	if err = tx.RecoveryCode.CreateBulk(builders...).Exec(ctx); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
@@ -0,0 +1,174 @@
+package actions
+
+import (
+	"context"
+	"github.com/gofiber/fiber/v2"
+	"groove/pkgs/ent"
+	EmailVerification "groove/pkgs/ent/emailverification"
+	"groove/pkgs/mail"
+	"groove/pkgs/secret"
+	. "groove/pkgs/util"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	// emailVerificationTTL is how long a verification link stays valid.
+	emailVerificationTTL = 24 * time.Hour
+	// verificationCooldown is how long a user has to wait between verification emails.
+	verificationCooldown = time.Minute
+)
+
+// sendVerification emails a verification link to an email of the user, replacing any pending one.
+// the email is either the current one, or a new one that replaces it once verified (see ChangeEmail).
+func (a *Actions) sendVerification(ctx context.Context, user *ent.User, email string) error {
+	token, err := secret.NewToken()
+	if err != nil {
+		return err
+	}
+
+	// a new link replaces any previous one.
+	_, err = a.Client.EmailVerification.
+		Delete().
+		Where(EmailVerification.UserIDEQ(user.ID)).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	err = a.Client.EmailVerification.Create().
+		SetUserID(user.ID).
+		SetEmail(email).
+		SetTokenHash(secret.HashToken(token)).
+		SetExpiration(now.Add(emailVerificationTTL)).
+		SetSentAt(now).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	msg := mail.Message{
+		To:      email,
+		Subject: "Verify your Groove email",
+		Body: "Hi " + user.Username + ",\n\n" +
+			"Follow this link within 24 hours to verify this email for your Groove account:\n\n" +
+			a.Env.AppURL() + "/verify-email?" + url.Values{"token": {token}}.Encode() + "\n\n" +
+			"If you didn't ask for this, you can ignore this email.\n",
+	}
+	go func() {
+		if err := a.Mail.Send(context.Background(), msg); err != nil {
+			LogError("sendVerification", "send verification email", err)
+		}
+	}()
+	return nil
+}
+
+// VerifyEmail marks the email of the user as verified using the token of a verification link;
+// if the link was sent to a new email (see ChangeEmail), it also becomes the email of the user.
+// returns a 400 if the token is invalid or expired, or the new email was taken in the meantime.
+// returns a 200 if the email is verified.
+func (a *Actions) VerifyEmail(c *fiber.Ctx, token string) error {
+	ctx := c.Context()
+	verification, err := a.Client.EmailVerification.
+		Query().
+		Where(
+			EmailVerification.TokenHashEQ(secret.HashToken(token)),
+			EmailVerification.ExpirationGT(time.Now()),
+		).
+		First(ctx)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return BadRequest(c, "invalid or expired verification link")
+		}
+		LogError("VerifyEmail", "check verification", err)
+		return InternalServerError(c, "error verifying email")
+	}
+
+	tx, err := a.Client.Tx(ctx)
+	if err != nil {
+		LogError("VerifyEmail", "begin transaction", err)
+		return InternalServerError(c, "error verifying email")
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err = tx.EmailVerification.DeleteOneID(verification.ID).Exec(ctx); err != nil {
+		if ent.IsNotFound(err) {
+			// used by a concurrent request.
+			return BadRequest(c, "invalid or expired verification link")
+		}
+		LogError("VerifyEmail", "delete verification", err)
+		return InternalServerError(c, "error verifying email")
+	}
+
+	// only one link is pending per user, so the email it was sent to is always the one to verify.
+	err = tx.User.
+		UpdateOneID(verification.UserID).
+		SetEmail(verification.Email).
+		SetEmailVerified(true).
+		Exec(ctx)
+	if err != nil {
+		if ent.IsConstraintError(err) {
+			return BadRequest(c, "email already exists")
+		}
+		LogError("VerifyEmail", "update user", err)
+		return InternalServerError(c, "error verifying email")
+	}
+
+	if err = tx.Commit(); err != nil {
+		LogError("VerifyEmail", "commit", err)
+		return InternalServerError(c, "error verifying email")
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"acknowledged": true,
+		"message":      "email verified",
+	})
+}
+
+// ResendVerification emails a new verification link to the user, invalidating the previous one.
+// the link goes to the pending new email if the user is changing it, to their current email otherwise.
+// returns a 400 if the email is already verified and no change is pending.
+// returns a 429 if the last email was sent less than a minute ago.
+// returns a 200 if the email is sent.
+func (a *Actions) ResendVerification(c *fiber.Ctx) error {
+	session := c.Locals("session").(*ent.Session)
+	ctx := c.Context()
+
+	user, err := a.Client.User.Get(ctx, session.UserID)
+	if err != nil {
+		LogError("ResendVerification", "check user", err)
+		return InternalServerError(c, "error getting account")
+	}
+
+	pending, err := a.Client.EmailVerification.
+		Query().
+		Where(EmailVerification.UserIDEQ(user.ID)).
+		First(ctx)
+	if err != nil && !ent.IsNotFound(err) {
+		LogError("ResendVerification", "check verification", err)
+		return InternalServerError(c, "error sending verification email")
+	}
+
+	email := user.Email
+	if pending != nil {
+		if wait := time.Until(pending.SentAt.Add(verificationCooldown)); wait > 0 {
+			return TooManyRequests(c, "verification email already sent, try again later", wait)
+		}
+		email = pending.Email
+	}
+	if email == user.Email && user.EmailVerified {
+		return BadRequest(c, "email already verified")
+	}
+
+	if err = a.sendVerification(ctx, user, email); err != nil {
+		LogError("ResendVerification", "send verification", err)
+		return InternalServerError(c, "error sending verification email")
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"acknowledged": true,
+		"message":      "verification email sent to " + email,
+	})
+}
@@ -1,5 +1,7 @@
 package server
 
+import "groove/pkgs/db"
+
 func (s *Server) SetupEndpoints() {
 	app, mw, handlers := s.app, s.middleware, s.handlers
 
@@ -10,41 +12,109 @@ func (s *Server) SetupEndpoints() {
 	/** session endpoints **/
 	api.Post("/register", mw.RedirectAuthorized, handlers.Register)
 	api.Post("/login", mw.RedirectAuthorized, handlers.Login)
+	api.Post("/login/2fa", mw.RedirectAuthorized, handlers.LoginTwoFactor)
 	api.Post("/logout", mw.CheckCSRF, handlers.Logout)
 	api.Post("/authenticate", mw.CheckCSRF, handlers.Authenticate)
 
+	/** session management endpoints **/
+	sessions := api.Group("/sessions")
+	sessions.Get("/", mw.AuthorizeAny, handlers.GetSessions)
+	sessions.Post("/revoke-others", mw.CheckCSRF, handlers.RevokeOtherSessions)
+	sessions.Delete("/:id", mw.CheckCSRF, handlers.RevokeSession)
+
+	/** personal access token endpoints **/
+	tokens := api.Group("/tokens")
+	tokens.Get("/", mw.AuthorizeAny, handlers.GetAccessTokens)
+	tokens.Post("/", mw.CheckCSRF, handlers.CreateAccessToken)
+	tokens.Delete("/:id", mw.CheckCSRF, handlers.RevokeAccessToken)
+
+	/** email verification endpoints **/
+	api.Post("/verify-email", handlers.VerifyEmail)
+	api.Post("/verify-email/resend", mw.CheckCSRF, handlers.ResendVerification)
+
+	/** password endpoints **/
+	password := api.Group("/password")
+	password.Post("/forgot", handlers.ForgotPassword)
+	password.Post("/reset", handlers.ResetPassword)
+	password.Post("/set", mw.CheckCSRF, handlers.SetPassword)
+
+	/** account settings endpoints **/
+	account := api.Group("/account")
+	account.Post("/password", mw.CheckCSRF, handlers.ChangePassword)
+	account.Post("/email", mw.CheckCSRF, handlers.ChangeEmail)
+	account.Post("/username", mw.CheckCSRF, handlers.ChangeUsername)
+	account.Delete("/", mw.CheckCSRF, handlers.DeleteAccount)
+	account.Post("/export", mw.CheckCSRF, handlers.RequestExport)
+	account.Get("/export", mw.AuthorizeAny, handlers.GetExport)
+	account.Get("/export/download", handlers.DownloadExport)
+
+	/** two-factor authentication endpoints **/
+	twoFactor := api.Group("/2fa")
+	twoFactor.Post("/enroll", mw.CheckCSRF, handlers.EnrollTwoFactor)
+	twoFactor.Post("/confirm", mw.CheckCSRF, handlers.ConfirmTwoFactor)
+	twoFactor.Post("/disable", mw.CheckCSRF, handlers.DisableTwoFactor)
+	twoFactor.Post("/recovery-codes", mw.CheckCSRF, handlers.RegenerateRecoveryCodes)
+
+	/** admin endpoints **/
+	admin := api.Group("/admin")
+	admin.Get("/users", mw.AuthorizeAny, mw.AuthorizeAdmin, handlers.ListUsers)
+	admin.Get("/users/:id", mw.AuthorizeAny, mw.AuthorizeAdmin, handlers.GetUser)
+	admin.Post("/users/:id/disable", mw.CheckCSRF, mw.AuthorizeAdmin, handlers.DisableUser)
+	admin.Post("/users/:id/enable", mw.CheckCSRF, mw.AuthorizeAdmin, handlers.EnableUser)
+	admin.Post("/users/:id/logout", mw.CheckCSRF, mw.AuthorizeAdmin, handlers.LogoutUser)
+	admin.Post("/users/:id/unlink-spotify", mw.CheckCSRF, mw.AuthorizeAdmin, handlers.UnlinkUserSpotify)
+
 	/** spotify-link endpoints **/
 	spotify := api.Group("/spotify")
-	spotify.Post("/link", mw.CheckCSRF, mw.RedirectLinked, handlers.LinkSpotify)
+	spotify.Post("/link", mw.CheckCSRF, mw.AuthorizeVerified, mw.RedirectLinked, handlers.LinkSpotify)
 	spotify.Get("/callback", mw.AuthorizeAny, handlers.SpotifyCallback)
 	spotify.Post("/unlink", mw.CheckCSRF, handlers.UnlinkSpotify)
-	spotify.Get("/me", mw.AuthorizeLinked, mw.SetAccess, handlers.GetCurrentUser)
+	spotify.Post("/login", mw.RedirectAuthorized, handlers.LoginWithSpotify)
+	spotify.Get("/login/callback", mw.RedirectAuthorized, handlers.SpotifyLoginCallback)
+	spotify.Get("/me", mw.AllowToken(db.ScopeProfileRead), mw.AuthorizeLinked, mw.SetAccess, handlers.GetCurrentUser)
+	spotify.Get("/me/playlists", mw.AllowToken(db.ScopePlaylistsRead), mw.AuthorizeLinked, mw.SetAccess, handlers.GetMyPlaylists)
 
 	/** spotify-artist endpoints **/
 	artists := spotify.Group("/artists")
-	artists.Get("/:id", mw.AuthorizeAny, mw.SetAccess, handlers.GetArtist)
-	artists.Get("/:id/related-artists", mw.AuthorizeAny, mw.SetAccess, handlers.GetRelatedArtists)
-	artists.Get("/:id/top-tracks", mw.AuthorizeAny, mw.SetAccess, handlers.GetArtistTopTracks)
-	artists.Get("/:id/albums", mw.AuthorizeAny, mw.SetAccess, handlers.GetArtistAlbums)
+	artists.Get("/:id", mw.AllowToken(db.ScopeCatalogRead), mw.AuthorizeAny, mw.SetAccess, handlers.GetArtist)
+	artists.Get("/:id/related-artists", mw.AllowToken(db.ScopeCatalogRead), mw.AuthorizeAny, mw.SetAccess, handlers.GetRelatedArtists)
+	artists.Get("/:id/top-tracks", mw.AllowToken(db.ScopeCatalogRead), mw.AuthorizeAny, mw.SetAccess, handlers.GetArtistTopTracks)
+	artists.Get("/:id/albums", mw.AllowToken(db.ScopeCatalogRead), mw.AuthorizeAny, mw.SetAccess, handlers.GetArtistAlbums)
 
 	/** spotify-album endpoints **/
 	albums := spotify.Group("/albums")
-	albums.Get("/:id", mw.AuthorizeAny, mw.SetAccess, handlers.GetAlbum)
-	albums.Get("/:id/tracks", mw.AuthorizeAny, mw.SetAccess, handlers.GetAlbumTracks)
+	albums.Get("/:id", mw.AllowToken(db.ScopeCatalogRead), mw.AuthorizeAny, mw.SetAccess, handlers.GetAlbum)
+	albums.Get("/:id/tracks", mw.AllowToken(db.ScopeCatalogRead), mw.AuthorizeAny, mw.SetAccess, handlers.GetAlbumTracks)
 
 	/** spotify-tracks endpoints **/
 	tracks := spotify.Group("/tracks")
-	tracks.Get("/:id", mw.AuthorizeAny, mw.SetAccess, handlers.GetTrack)
+	tracks.Get("/:id", mw.AllowToken(db.ScopeCatalogRead), mw.AuthorizeAny, mw.SetAccess, handlers.GetTrack)
 
 	/** spotify-playlist endpoints **/
 	playlists := spotify.Group("/playlists")
-	playlists.Get("/", mw.AuthorizeLinked, mw.SetAccess, handlers.GetAllPlaylists)
-	playlists.Get("/:id", mw.AuthorizeLinked, mw.SetAccess, handlers.GetPlaylistWithTracks)
-	playlists.Get("/:id/load-more", mw.AuthorizeLinked, mw.SetAccess, handlers.GetMorePlaylistTracks)
-	playlists.Post("/:id/track", mw.CheckCSRF, mw.AuthorizeLinked, mw.SetAccess, handlers.AddTrackToPlaylist)
-	playlists.Delete("/:id/track", mw.CheckCSRF, mw.AuthorizeLinked, mw.SetAccess, handlers.RemoveTrackFromPlaylist)
+	playlists.Get("/", mw.AllowToken(db.ScopePlaylistsRead), mw.AuthorizeLinked, mw.SetAccess, handlers.GetAllPlaylists)
+	playlists.Post("/", mw.AllowToken(db.ScopePlaylistsWrite), mw.CheckCSRF, mw.AuthorizeVerified, mw.AuthorizeLinked, mw.SetAccess, handlers.CreatePlaylist)
+	playlists.Get("/:id", mw.AllowToken(db.ScopePlaylistsRead), mw.AuthorizeLinked, mw.SetAccess, handlers.GetPlaylistWithTracks)
+	playlists.Patch("/:id", mw.AllowToken(db.ScopePlaylistsWrite), mw.CheckCSRF, mw.AuthorizeVerified, mw.AuthorizeLinked, mw.SetAccess, handlers.UpdatePlaylist)
+	playlists.Delete("/:id", mw.AllowToken(db.ScopePlaylistsWrite), mw.CheckCSRF, mw.AuthorizeVerified, mw.AuthorizeLinked, mw.SetAccess, handlers.UnfollowPlaylist)
+	playlists.Get("/:id/export", mw.AllowToken(db.ScopePlaylistsRead), mw.AuthorizeLinked, mw.SetAccess, handlers.ExportPlaylist)
+	playlists.Get("/:id/load-more", mw.AllowToken(db.ScopePlaylistsRead), mw.AuthorizeLinked, mw.SetAccess, handlers.GetMorePlaylistTracks)
+	playlists.Post("/:id/track", mw.AllowToken(db.ScopePlaylistsWrite), mw.CheckCSRF, mw.AuthorizeVerified, mw.AuthorizeLinked, mw.SetAccess, handlers.AddTrackToPlaylist)
+	playlists.Delete("/:id/track", mw.AllowToken(db.ScopePlaylistsWrite), mw.CheckCSRF, mw.AuthorizeVerified, mw.AuthorizeLinked, mw.SetAccess, handlers.RemoveTrackFromPlaylist)
+	playlists.Get("/:id/tracks", mw.AllowToken(db.ScopePlaylistsRead), mw.AuthorizeLinked, mw.SetAccess, handlers.GetAllPlaylistTracks)
+	playlists.Post("/:id/tracks", mw.AllowToken(db.ScopePlaylistsWrite), mw.CheckCSRF, mw.AuthorizeVerified, mw.AuthorizeLinked, mw.SetAccess, handlers.AddPlaylistItems)
+	playlists.Delete("/:id/tracks", mw.AllowToken(db.ScopePlaylistsWrite), mw.CheckCSRF, mw.AuthorizeVerified, mw.AuthorizeLinked, mw.SetAccess, handlers.RemovePlaylistItems)
+	playlists.Put("/:id/tracks", mw.AllowToken(db.ScopePlaylistsWrite), mw.CheckCSRF, mw.AuthorizeVerified, mw.AuthorizeLinked, mw.SetAccess, handlers.ReorderPlaylistItems)
+
+	/** spotify-playlist-import endpoints **/
+	imports := spotify.Group("/imports")
+	imports.Get("/", mw.AllowToken(db.ScopePlaylistsRead), mw.AuthorizeLinked, handlers.GetImports)
+	imports.Post("/", mw.AllowToken(db.ScopePlaylistsWrite), mw.CheckCSRF, mw.AuthorizeVerified, mw.AuthorizeLinked, handlers.ImportPlaylist)
+	imports.Get("/:id", mw.AllowToken(db.ScopePlaylistsRead), mw.AuthorizeLinked, handlers.GetImport)
+	imports.Delete("/:id", mw.AllowToken(db.ScopePlaylistsWrite), mw.CheckCSRF, mw.AuthorizeVerified, mw.AuthorizeLinked, handlers.DeleteImport)
+	imports.Post("/:id/confirm", mw.AllowToken(db.ScopePlaylistsWrite), mw.CheckCSRF, mw.AuthorizeVerified, mw.AuthorizeLinked, handlers.ConfirmImport)
 
 	/** spotify-search endpoints **/
 	search := spotify.Group("/search")
-	search.Get("/:query", mw.AuthorizeAny, mw.SetAccess, handlers.Search)
+	search.Get("/:query", mw.AllowToken(db.ScopeCatalogRead), mw.AuthorizeAny, mw.SetAccess, handlers.Search)
 }
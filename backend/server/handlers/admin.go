@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	. "groove/pkgs/util"
+)
+
+func (h *Handlers) ListUsers(c *fiber.Ctx) error {
+	page := c.QueryInt("page", 1)
+	if page < 1 {
+		return BadRequest(c, "invalid page")
+	}
+
+	return h.Actions.ListUsers(c, c.Query("q"), page)
+}
+
+func (h *Handlers) GetUser(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return BadRequest(c, "invalid user id")
+	}
+
+	return h.Actions.GetUser(c, id)
+}
+
+func (h *Handlers) DisableUser(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return BadRequest(c, "invalid user id")
+	}
+
+	return h.Actions.DisableUser(c, id)
+}
+
+func (h *Handlers) EnableUser(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return BadRequest(c, "invalid user id")
+	}
+
+	return h.Actions.EnableUser(c, id)
+}
+
+func (h *Handlers) LogoutUser(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return BadRequest(c, "invalid user id")
+	}
+
+	return h.Actions.LogoutUser(c, id)
+}
+
+func (h *Handlers) UnlinkUserSpotify(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return BadRequest(c, "invalid user id")
+	}
+
+	return h.Actions.UnlinkUserSpotify(c, id)
+}
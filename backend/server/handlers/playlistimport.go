@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"github.com/MarcusSanchez/go-parse"
+	"github.com/gofiber/fiber/v2"
+	"groove/pkgs/db"
+	"groove/pkgs/playlistfile"
+	. "groove/pkgs/util"
+)
+
+// maxImportFile is the largest playlist file that can be imported, in bytes.
+const maxImportFile = 2 << 20
+
+func (h *Handlers) ImportPlaylist(c *fiber.Ctx) error {
+	header, err := c.FormFile("file")
+	if err != nil {
+		return BadRequest(c, "file is required")
+	}
+	if header.Size > maxImportFile {
+		return BadRequest(c, "file must be at most 2MB")
+	}
+
+	// the format is guessed from the file's extension unless given.
+	var format playlistfile.Format
+	if name := c.FormValue("format"); name != "" {
+		if format, err = playlistfile.ParseFormat(name); err != nil {
+			return BadRequest(c, err.Error())
+		}
+	} else {
+		var ok bool
+		if format, ok = playlistfile.FormatOf(header.Filename); !ok {
+			return BadRequest(c, "unknown file type, format is required")
+		}
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return BadRequest(c, "invalid file")
+	}
+	defer func() { _ = file.Close() }()
+
+	return h.Actions.ImportPlaylist(c, file, format, c.FormValue("name"))
+}
+
+func (h *Handlers) GetImports(c *fiber.Ctx) error {
+	return h.Actions.GetImports(c)
+}
+
+func (h *Handlers) GetImport(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return BadRequest(c, "invalid import id")
+	}
+
+	return h.Actions.GetImport(c, id)
+}
+
+func (h *Handlers) ConfirmImport(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return BadRequest(c, "invalid import id")
+	}
+
+	type Payload struct {
+		Name          string   `json:"name"`
+		Description   string   `json:"description,optional"`
+		Public        bool     `json:"public,optional"`
+		Exclude       []int    `json:"exclude,optional"`
+		MinConfidence *float64 `json:"min_confidence,optional"`
+	}
+
+	payload, err := parse.JSON[Payload](c.Body())
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	minConfidence := db.MinMatchConfidence
+	if payload.MinConfidence != nil {
+		minConfidence = *payload.MinConfidence
+	}
+	return h.Actions.ConfirmImport(c, id, payload.Name, payload.Description, payload.Public, payload.Exclude, minConfidence)
+}
+
+func (h *Handlers) DeleteImport(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return BadRequest(c, "invalid import id")
+	}
+
+	return h.Actions.DeleteImport(c, id)
+}
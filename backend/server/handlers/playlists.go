@@ -1,8 +1,13 @@
 package handlers
 
 import (
+	"errors"
+	"github.com/MarcusSanchez/go-parse"
 	"github.com/gofiber/fiber/v2"
+	"groove/pkgs/playlistfile"
+	"groove/pkgs/spotify"
 	. "groove/pkgs/util"
+	"groove/server/actions"
 	"strconv"
 )
 
@@ -15,12 +20,12 @@ func (h *Handlers) GetPlaylistWithTracks(c *fiber.Ctx) error {
 }
 
 func (h *Handlers) GetMorePlaylistTracks(c *fiber.Ctx) error {
-	offset := c.QueryInt("offset")
-	if offset == 0 {
+	offset, err := strconv.Atoi(c.Query("offset"))
+	if err != nil || offset < 0 {
 		return BadRequest(c, "invalid offset")
 	}
 
-	return h.Actions.GetMorePlaylistTracks(c, c.Params("id"), strconv.Itoa(offset))
+	return h.Actions.GetMorePlaylistTracks(c, c.Params("id"), offset)
 }
 
 func (h *Handlers) AddTrackToPlaylist(c *fiber.Ctx) error {
@@ -40,3 +45,166 @@ func (h *Handlers) RemoveTrackFromPlaylist(c *fiber.Ctx) error {
 
 	return h.Actions.RemoveTrackFromPlaylist(c, c.Params("id"), trackID)
 }
+
+func (h *Handlers) CreatePlaylist(c *fiber.Ctx) error {
+
+	type Payload struct {
+		Name          string `json:"name"`
+		Description   string `json:"description,optional"`
+		Public        *bool  `json:"public,optional"`
+		Collaborative bool   `json:"collaborative,optional"`
+	}
+
+	payload, err := parse.JSON[Payload](c.Body())
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	// like spotify, playlists are public unless asked otherwise.
+	public := payload.Public == nil || *payload.Public
+	return h.Actions.CreatePlaylist(c, payload.Name, payload.Description, public, payload.Collaborative)
+}
+
+func (h *Handlers) UpdatePlaylist(c *fiber.Ctx) error {
+
+	type Payload struct {
+		Name          *string `json:"name,optional"`
+		Description   *string `json:"description,optional"`
+		Public        *bool   `json:"public,optional"`
+		Collaborative *bool   `json:"collaborative,optional"`
+	}
+
+	payload, err := parse.JSON[Payload](c.Body())
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	return h.Actions.UpdatePlaylist(c, c.Params("id"), spotify.PlaylistDetails{
+		Name:          payload.Name,
+		Description:   payload.Description,
+		Public:        payload.Public,
+		Collaborative: payload.Collaborative,
+	})
+}
+
+func (h *Handlers) UnfollowPlaylist(c *fiber.Ctx) error {
+	return h.Actions.UnfollowPlaylist(c, c.Params("id"))
+}
+
+func (h *Handlers) AddPlaylistItems(c *fiber.Ctx) error {
+
+	type Payload struct {
+		URIs       []string `json:"uris"`
+		Position   *int     `json:"position,optional"`
+		SnapshotID string   `json:"snapshot_id,optional"`
+	}
+
+	payload, err := parse.JSON[Payload](c.Body())
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	return h.Actions.AddPlaylistItems(c, c.Params("id"), payload.URIs, payload.Position, payload.SnapshotID)
+}
+
+func (h *Handlers) RemovePlaylistItems(c *fiber.Ctx) error {
+
+	type Item struct {
+		URI       string `json:"uri"`
+		Positions []int  `json:"positions"`
+	}
+
+	type Payload struct {
+		URIs       []string `json:"uris,optional"`
+		Items      []Item   `json:"items,optional"`
+		SnapshotID string   `json:"snapshot_id,optional"`
+	}
+
+	payload, err := parse.JSON[Payload](c.Body())
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	items := make([]spotify.PositionedItem, len(payload.Items))
+	for i, item := range payload.Items {
+		items[i] = spotify.PositionedItem{URI: item.URI, Positions: item.Positions}
+	}
+
+	return h.Actions.RemovePlaylistItems(c, c.Params("id"), payload.URIs, items, payload.SnapshotID)
+}
+
+func (h *Handlers) ReorderPlaylistItems(c *fiber.Ctx) error {
+
+	type Payload struct {
+		RangeStart   int    `json:"range_start"`
+		InsertBefore int    `json:"insert_before"`
+		RangeLength  *int   `json:"range_length,optional"`
+		SnapshotID   string `json:"snapshot_id,optional"`
+	}
+
+	payload, err := parse.JSON[Payload](c.Body())
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	rangeLength := 1
+	if payload.RangeLength != nil {
+		rangeLength = *payload.RangeLength
+	}
+
+	return h.Actions.ReorderPlaylistItems(c, c.Params("id"), payload.RangeStart, payload.InsertBefore, rangeLength, payload.SnapshotID)
+}
+
+// aggregate reads how a paginated response is requested from the cursor, limit and format query parameters.
+func aggregate(c *fiber.Ctx) (actions.Aggregate, error) {
+	var agg actions.Aggregate
+
+	cursor, err := actions.DecodeCursor(c.Query("cursor"))
+	if err != nil {
+		return agg, err
+	}
+	agg.Cursor = cursor
+
+	if limit := c.Query("limit"); limit != "" {
+		agg.Limit, err = strconv.Atoi(limit)
+		if err != nil || agg.Limit < 0 {
+			return agg, errors.New("invalid limit")
+		}
+	}
+
+	switch c.Query("format") {
+	case "", "json":
+	case "ndjson":
+		agg.NDJSON = true
+	default:
+		return agg, errors.New("invalid format: must be json or ndjson")
+	}
+	return agg, nil
+}
+
+func (h *Handlers) GetAllPlaylistTracks(c *fiber.Ctx) error {
+	agg, err := aggregate(c)
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	return h.Actions.GetAllPlaylistTracks(c, c.Params("id"), agg)
+}
+
+func (h *Handlers) GetMyPlaylists(c *fiber.Ctx) error {
+	agg, err := aggregate(c)
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	return h.Actions.GetMyPlaylists(c, agg)
+}
+
+func (h *Handlers) ExportPlaylist(c *fiber.Ctx) error {
+	format, err := playlistfile.ParseFormat(c.Query("format"))
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	return h.Actions.ExportPlaylist(c, c.Params("id"), format)
+}
@@ -3,7 +3,6 @@ package handlers
 import (
 	"github.com/gofiber/fiber/v2"
 	. "groove/pkgs/util"
-	"strconv"
 )
 
 func (h *Handlers) Search(c *fiber.Ctx) error {
@@ -16,6 +15,6 @@ func (h *Handlers) Search(c *fiber.Ctx) error {
 		c.Params("query"),
 		queryTypes,
 		c.Query("market", "US"),
-		strconv.Itoa(c.QueryInt("limit", 18)),
+		c.QueryInt("limit", 18),
 	)
 }
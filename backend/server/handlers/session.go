@@ -52,3 +52,252 @@ func (h *Handlers) Logout(c *fiber.Ctx) error {
 func (h *Handlers) Authenticate(c *fiber.Ctx) error {
 	return h.Actions.Authenticate(c)
 }
+
+func (h *Handlers) VerifyEmail(c *fiber.Ctx) error {
+
+	type Payload struct {
+		Token string `json:"token"`
+	}
+
+	payload, err := parse.JSON[Payload](c.Body())
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	return h.Actions.VerifyEmail(c, payload.Token)
+}
+
+func (h *Handlers) ResendVerification(c *fiber.Ctx) error {
+	return h.Actions.ResendVerification(c)
+}
+
+func (h *Handlers) ForgotPassword(c *fiber.Ctx) error {
+
+	type Payload struct {
+		Email string `json:"email"`
+	}
+
+	payload, err := parse.JSON[Payload](c.Body())
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	return h.Actions.ForgotPassword(c, strings.ToLower(payload.Email))
+}
+
+func (h *Handlers) ResetPassword(c *fiber.Ctx) error {
+
+	type Payload struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+
+	payload, err := parse.JSON[Payload](c.Body())
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	return h.Actions.ResetPassword(c,
+		payload.Token,
+		payload.Password,
+	)
+}
+
+func (h *Handlers) LoginTwoFactor(c *fiber.Ctx) error {
+
+	type Payload struct {
+		Challenge string `json:"challenge,optional"`
+		Code      string `json:"code"`
+	}
+
+	payload, err := parse.JSON[Payload](c.Body())
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	return h.Actions.LoginTwoFactor(c,
+		payload.Challenge,
+		strings.TrimSpace(payload.Code),
+	)
+}
+
+func (h *Handlers) EnrollTwoFactor(c *fiber.Ctx) error {
+	return h.Actions.EnrollTwoFactor(c)
+}
+
+func (h *Handlers) ConfirmTwoFactor(c *fiber.Ctx) error {
+
+	type Payload struct {
+		Code string `json:"code"`
+	}
+
+	payload, err := parse.JSON[Payload](c.Body())
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	return h.Actions.ConfirmTwoFactor(c, strings.TrimSpace(payload.Code))
+}
+
+func (h *Handlers) DisableTwoFactor(c *fiber.Ctx) error {
+
+	type Payload struct {
+		Password string `json:"password"`
+	}
+
+	payload, err := parse.JSON[Payload](c.Body())
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	return h.Actions.DisableTwoFactor(c, payload.Password)
+}
+
+func (h *Handlers) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+
+	type Payload struct {
+		Password string `json:"password"`
+	}
+
+	payload, err := parse.JSON[Payload](c.Body())
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	return h.Actions.RegenerateRecoveryCodes(c, payload.Password)
+}
+
+func (h *Handlers) SetPassword(c *fiber.Ctx) error {
+
+	type Payload struct {
+		Password string `json:"password"`
+	}
+
+	payload, err := parse.JSON[Payload](c.Body())
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	return h.Actions.SetPassword(c, payload.Password)
+}
+
+func (h *Handlers) GetSessions(c *fiber.Ctx) error {
+	return h.Actions.GetSessions(c)
+}
+
+func (h *Handlers) RevokeSession(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return BadRequest(c, "invalid session id")
+	}
+
+	return h.Actions.RevokeSession(c, id)
+}
+
+func (h *Handlers) RevokeOtherSessions(c *fiber.Ctx) error {
+	return h.Actions.RevokeOtherSessions(c)
+}
+
+func (h *Handlers) ChangePassword(c *fiber.Ctx) error {
+
+	type Payload struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+
+	payload, err := parse.JSON[Payload](c.Body())
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	return h.Actions.ChangePassword(c, payload.CurrentPassword, payload.NewPassword)
+}
+
+func (h *Handlers) ChangeEmail(c *fiber.Ctx) error {
+
+	type Payload struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+
+	payload, err := parse.JSON[Payload](c.Body())
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	return h.Actions.ChangeEmail(c, strings.ToLower(payload.Email), payload.Password)
+}
+
+func (h *Handlers) ChangeUsername(c *fiber.Ctx) error {
+
+	type Payload struct {
+		Username string `json:"username"`
+	}
+
+	payload, err := parse.JSON[Payload](c.Body())
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	return h.Actions.ChangeUsername(c, payload.Username)
+}
+
+func (h *Handlers) DeleteAccount(c *fiber.Ctx) error {
+
+	type Payload struct {
+		Password string `json:"password"`
+	}
+
+	payload, err := parse.JSON[Payload](c.Body())
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	return h.Actions.DeleteAccount(c, payload.Password)
+}
+
+func (h *Handlers) RequestExport(c *fiber.Ctx) error {
+	return h.Actions.RequestExport(c)
+}
+
+func (h *Handlers) GetExport(c *fiber.Ctx) error {
+	return h.Actions.GetExport(c)
+}
+
+func (h *Handlers) DownloadExport(c *fiber.Ctx) error {
+	token := c.Query("token")
+	if token == "" {
+		return BadRequest(c, "missing token")
+	}
+
+	return h.Actions.DownloadExport(c, token)
+}
+
+func (h *Handlers) CreateAccessToken(c *fiber.Ctx) error {
+
+	type Payload struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expires_in_days,optional"`
+	}
+
+	payload, err := parse.JSON[Payload](c.Body())
+	if err != nil {
+		return BadRequest(c, err.Error())
+	}
+
+	return h.Actions.CreateAccessToken(c, payload.Name, payload.Scopes, payload.ExpiresInDays)
+}
+
+func (h *Handlers) GetAccessTokens(c *fiber.Ctx) error {
+	return h.Actions.GetAccessTokens(c)
+}
+
+func (h *Handlers) RevokeAccessToken(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return BadRequest(c, "invalid token id")
+	}
+
+	return h.Actions.RevokeAccessToken(c, id)
+}
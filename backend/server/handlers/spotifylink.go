@@ -18,6 +18,19 @@ func (h *Handlers) SpotifyCallback(c *fiber.Ctx) error {
 	return h.Actions.SpotifyCallback(c, code, state)
 }
 
+func (h *Handlers) LoginWithSpotify(c *fiber.Ctx) error {
+	return h.Actions.LoginWithSpotify(c)
+}
+
+func (h *Handlers) SpotifyLoginCallback(c *fiber.Ctx) error {
+	code, state := c.Query("code"), c.Query("state")
+	if code == "" || state == "" {
+		return BadRequest(c, "invalid query params")
+	}
+
+	return h.Actions.SpotifyLoginCallback(c, code, state)
+}
+
 func (h *Handlers) UnlinkSpotify(c *fiber.Ctx) error {
 	return h.Actions.UnlinkSpotify(c)
 }
@@ -1,22 +1,22 @@
 package middleware
 
 import (
-	"context"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	recovery "github.com/gofiber/fiber/v2/middleware/recover"
-	"go.uber.org/fx"
+	"groove/pkgs/db"
 	"groove/pkgs/ent"
-	SpotifyLink "groove/pkgs/ent/spotifylink"
 	"groove/pkgs/env"
-	. "groove/pkgs/util"
+	"groove/pkgs/spotify"
 )
 
 type Middlewares struct {
-	Client     *ent.Client
-	Env        *env.Env
-	Shutdowner fx.Shutdowner
+	Client    *ent.Client
+	Env       *env.Env
+	Spotify   *spotify.Client
+	AppToken  *spotify.AppToken
+	Refresher *db.Refresher
 }
 
 // Attach attaches the middleware that run on all endpoints.
@@ -46,20 +46,3 @@ func (*Middlewares) ReactServer(c *fiber.Ctx) error {
 	}
 	return c.SendFile("./public/index.html")
 }
-
-// defaultAccessToken utility function that returns the access token of the default user.
-func (m *Middlewares) defaultAccessToken() (*ent.SpotifyLink, error) {
-	link, err := m.Client.SpotifyLink.
-		Query().
-		Where(SpotifyLink.UserIDEQ(1)).
-		First(context.Background())
-	if err != nil {
-		if ent.IsNotFound(err) {
-			LogError("defaultAccessToken", "default user not set or deleted", err)
-			_ = m.Shutdowner.Shutdown()
-		}
-		return nil, err
-	}
-
-	return link, nil
-}
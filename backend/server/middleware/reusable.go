@@ -1,15 +1,15 @@
 package middleware
 
 import (
-	"encoding/base64"
-	"encoding/json"
+	"crypto/subtle"
 	"errors"
 	"github.com/MarcusSanchez/go-parse"
-	"github.com/go-resty/resty/v2"
 	"github.com/gofiber/fiber/v2"
+	"groove/pkgs/db"
 	"groove/pkgs/ent"
 	Session "groove/pkgs/ent/session"
 	SpotifyLink "groove/pkgs/ent/spotifylink"
+	User "groove/pkgs/ent/user"
 	. "groove/pkgs/util"
 	"net/http"
 	"strconv"
@@ -53,6 +53,10 @@ func (m *Middlewares) RedirectAuthorized(c *fiber.Ctx) error {
 // for general use of endpoints where the user just needs to be logged in.
 // i.e. viewing content, searching songs, etc...
 func (m *Middlewares) AuthorizeAny(c *fiber.Ctx) error {
+	if authorizedByToken(c) {
+		return c.Next()
+	}
+
 	authorization := c.Cookies("Authorization")
 	if authorization == "" {
 		return Unauthorized(c, "missing authorization")
@@ -79,10 +83,65 @@ func (m *Middlewares) AuthorizeAny(c *fiber.Ctx) error {
 		return Unauthorized(c, "session expired")
 	}
 
+	m.touchSession(c, session)
 	c.Locals("session", session)
 	return c.Next()
 }
 
+// AuthorizeVerified checks that the user verified their email.
+// for endpoints that act on behalf of the user outside of Groove.
+// i.e. linking spotify, modifying playlists.
+//
+// NOTE: this middleware is meant to be used after AuthorizeAny or CheckCSRF to retrieve session.
+func (m *Middlewares) AuthorizeVerified(c *fiber.Ctx) error {
+	session := c.Locals("session").(*ent.Session)
+
+	verified, err := m.Client.User.
+		Query().
+		Where(
+			User.IDEQ(session.UserID),
+			User.EmailVerifiedEQ(true),
+		).
+		Exist(c.Context())
+	if err != nil {
+		LogError("AuthorizeVerified[MIDDLEWARE]", "checking user", err)
+		return InternalServerError(c, "error while authorizing")
+	} else if !verified {
+		return Forbidden(c, "email not verified")
+	}
+
+	return c.Next()
+}
+
+// AuthorizeAdmin checks that the user is an admin.
+// for endpoints managing other users, i.e. the admin API.
+//
+// NOTE: this middleware is meant to be used after AuthorizeAny or CheckCSRF to retrieve session.
+func (m *Middlewares) AuthorizeAdmin(c *fiber.Ctx) error {
+	session := c.Locals("session").(*ent.Session)
+
+	// personal access tokens have no scope for the admin API.
+	if authorizedByToken(c) {
+		return Forbidden(c, "admin access requires a session")
+	}
+
+	admin, err := m.Client.User.
+		Query().
+		Where(
+			User.IDEQ(session.UserID),
+			User.RoleEQ(User.RoleAdmin),
+		).
+		Exist(c.Context())
+	if err != nil {
+		LogError("AuthorizeAdmin[MIDDLEWARE]", "checking user", err)
+		return InternalServerError(c, "error while authorizing")
+	} else if !admin {
+		return Forbidden(c, "admin access required")
+	}
+
+	return c.Next()
+}
+
 // RedirectLinked redirects to the home page if the user is already linked to spotify.
 // Useful for instances where the user should not be linked to spotify.
 // i.e. spotify link page.
@@ -92,10 +151,13 @@ func (m *Middlewares) RedirectLinked(c *fiber.Ctx) error {
 	session := c.Locals("session").(*ent.Session)
 	ctx := c.Context()
 
-	// check if a link already exists.
+	// check if a link already exists. (revoked links can be linked again)
 	exists, err := m.Client.SpotifyLink.
 		Query().
-		Where(SpotifyLink.UserIDEQ(session.UserID)).
+		Where(
+			SpotifyLink.UserIDEQ(session.UserID),
+			SpotifyLink.RevokedEQ(false),
+		).
 		Exist(ctx)
 	if err != nil {
 		LogError("RedirectLinked[MIDDLEWARE]", "checking spotify link", err)
@@ -107,12 +169,17 @@ func (m *Middlewares) RedirectLinked(c *fiber.Ctx) error {
 	return c.SendStatus(http.StatusPermanentRedirect)
 }
 
-// CheckCSRF checks if the Csrf token in the body matches the one in the session.
+// CheckCSRF checks if the Csrf token of the request (X-CSRF-Token header or body) matches the one in the session.
 // for use of endpoints where the user needs to be logged in and the request needs to be verified.
 // i.e. actions with side effects (creating, updating, deleting, etc...)
 //
 // NOTE: this middleware fulfills the same purpose as AuthorizeAny, no need to use both.
+// requests authorized by a personal access token (see AllowToken) have no cookies to forge, so are not checked.
 func (m *Middlewares) CheckCSRF(c *fiber.Ctx) error {
+	if authorizedByToken(c) {
+		return c.Next()
+	}
+
 	authorization := c.Cookies("Authorization")
 	if authorization == "" {
 		return Unauthorized(c, "missing authorization")
@@ -139,16 +206,24 @@ func (m *Middlewares) CheckCSRF(c *fiber.Ctx) error {
 		return Unauthorized(c, "session expired")
 	}
 
-	type CSRF struct {
-		Csrf string `json:"csrf_"`
-	}
+	// the token is read from the X-CSRF-Token header, or else from the csrf_ field of a JSON body;
+	// so requests without a JSON body (i.e. DELETE, multipart) can use the header.
+	csrf := c.Get(HeaderCSRF)
+	if csrf == "" {
+		type CSRF struct {
+			Csrf string `json:"csrf_,optional"`
+		}
 
-	payload, err := parse.JSON[CSRF](c.Body())
-	if err != nil {
-		return BadRequest(c, err.Error())
+		if payload, err := parse.JSON[CSRF](c.Body()); err == nil {
+			csrf = payload.Csrf
+		}
+	}
+	if csrf == "" {
+		return Forbidden(c, "missing csrf token")
 	}
 
-	if session.Csrf != payload.Csrf {
+	// compared in constant time, so the response time doesn't tell how much of the token is right.
+	if subtle.ConstantTimeCompare([]byte(session.Csrf), []byte(csrf)) != 1 {
 		// request was forged.
 		LogError(
 			"CheckCSRF[MIDDLEWARE]",
@@ -158,13 +233,14 @@ func (m *Middlewares) CheckCSRF(c *fiber.Ctx) error {
 		return Forbidden(c, "csrf token mismatch")
 	}
 
+	m.touchSession(c, session)
 	c.Locals("session", session)
 	return c.Next()
 }
 
 // SetAccess sets the access token for the spotify Client.
 // if user is linked to spotify, the access token will be theirs;
-// otherwise, the access token will be the default token.
+// otherwise, the access token will be the app token (client-credentials), which can only access the catalog.
 func (m *Middlewares) SetAccess(c *fiber.Ctx) error {
 	session := c.Locals("session").(*ent.Session)
 	ctx := c.Context()
@@ -175,17 +251,28 @@ func (m *Middlewares) SetAccess(c *fiber.Ctx) error {
 		Where(SpotifyLink.UserIDEQ(session.UserID)).
 		First(ctx)
 	if err != nil {
-		if ent.IsNotFound(err) {
-			// if not, use the default access token.
-			link, err = m.defaultAccessToken()
-			if err != nil {
-				LogError("SetAccess[MIDDLEWARE]", "getting default access token", err)
-				return InternalServerError(c, "error while authorizing")
-			}
-		} else {
+		if !ent.IsNotFound(err) {
 			LogError("SetAccess[MIDDLEWARE]", "checking spotify link", err)
 			return InternalServerError(c, "error while authorizing")
 		}
+
+		// if not, use the app token.
+		// it is shared by every unlinked user, so each of them only gets a share of its rate limit.
+		if ok, retryAfter := m.Spotify.AllowShared(strconv.Itoa(session.UserID)); !ok {
+			return TooManyRequests(c, "too many requests, try again later", retryAfter)
+		}
+		access, err := m.AppToken.Get(ctx)
+		if err != nil {
+			LogError("SetAccess[MIDDLEWARE]", "getting app token", err)
+			return InternalServerError(c, "error while authorizing")
+		}
+
+		c.Locals("access", access)
+		return c.Next()
+	}
+
+	if link.Revoked {
+		return Forbidden(c, "spotify link revoked, relink your account")
 	}
 
 	// if the link hasn't expired, we can use it.
@@ -195,65 +282,17 @@ func (m *Middlewares) SetAccess(c *fiber.Ctx) error {
 	}
 
 	// otherwise, we need to refresh the token.
-	credentials := m.Env.SpotifyClient + ":" + m.Env.SpotifySecret
-	encodedCredentials := base64.StdEncoding.EncodeToString([]byte(credentials))
-
-	resp, err := resty.New().R().
-		SetHeaders(Headers{
-			"Content-Type":  "application/x-www-form-urlencoded",
-			"Authorization": "Basic " + encodedCredentials,
-		}).
-		SetBody(URLSearchParams(Params{
-			"grant_type":    "refresh_token",
-			"refresh_token": link.RefreshToken,
-		})).
-		Post(SpotifyAccountsAPI + "/token")
-	if err != nil {
-		LogError("SetAccess[MIDDLEWARE]", "refreshing token", err)
-		return InternalServerError(c, "error while authorizing")
-	}
-
-	if resp.StatusCode() != 200 {
-		LogError(
-			"SetAccess[MIDDLEWARE]",
-			"refreshing token",
-			errors.New(strconv.Itoa(resp.StatusCode())+": "+string(resp.Body())),
-		)
-		return InternalServerError(c, "error while authorizing")
-	}
-
-	type Tokens struct {
-		AccessToken  string `json:"access_token"`
-		RefreshToken string `json:"refresh_token"`
-	}
-
-	payload := new(Tokens)
-	if json.Unmarshal(resp.Body(), payload) != nil {
-		LogError("SetAccess[MIDDLEWARE]", "unmarshalling token", err)
-		return InternalServerError(c, "error while authorizing")
-	}
-
-	if payload.RefreshToken == "" {
-		payload.RefreshToken = link.RefreshToken
-	}
-
-	// update link with new access and refresh tokens.
-	_, err = m.Client.SpotifyLink.UpdateOne(link).
-		SetAccessToken(payload.AccessToken).
-		SetRefreshToken(payload.RefreshToken).
-		// Spotify's Access-Token expire after 1 hour, so we set the expiration to 58 minutes to be safe.
-		SetAccessTokenExpiration(time.Now().Add(Time58Minutes)).
-		Save(ctx)
+	// (concurrent requests of the same user share a single refresh)
+	link, err = m.Refresher.Refresh(ctx, link.ID, time.Now())
 	if err != nil {
-		if ent.IsValidationError(err) {
-			LogError("SetAccess[MIDDLEWARE]", "updating spotify link (validation)", err)
-			return InternalServerError(c, "error while authorizing")
+		if errors.Is(err, db.ErrLinkRevoked) {
+			return Forbidden(c, "spotify link revoked, relink your account")
 		}
-		LogError("SetAccess[MIDDLEWARE]", "updating spotify link", err)
+		LogError("SetAccess[MIDDLEWARE]", "refreshing token", err)
 		return InternalServerError(c, "error while authorizing")
 	}
 
-	c.Locals("access", payload.AccessToken)
+	c.Locals("access", link.AccessToken)
 	return c.Next()
 }
 
@@ -263,14 +302,14 @@ func (m *Middlewares) SetAccess(c *fiber.Ctx) error {
 func (m *Middlewares) AuthorizeLinked(c *fiber.Ctx) error {
 	ctx := c.Context()
 
-	authorization := c.Cookies("Authorization")
-	if authorization == "" {
-		return Unauthorized(c, "missing authorization")
-	}
-
 	// check if cookie session actually exists.
 	session, ok := c.Locals("session").(*ent.Session)
 	if !ok {
+		authorization := c.Cookies("Authorization")
+		if authorization == "" {
+			return Unauthorized(c, "missing authorization")
+		}
+
 		var err error
 		session, err = m.Client.Session.Query().Where(Session.TokenEQ(authorization)).First(ctx)
 		if err != nil {
@@ -287,20 +326,43 @@ func (m *Middlewares) AuthorizeLinked(c *fiber.Ctx) error {
 			ExpireSessionCookies(c, m.Env.SameSite, m.Env.Secure)
 			return Unauthorized(c, "session expired")
 		}
+		m.touchSession(c, session)
 	}
 
 	// check if user is linked, else reject the request
-	exists, err := m.Client.SpotifyLink.
+	link, err := m.Client.SpotifyLink.
 		Query().
 		Where(SpotifyLink.UserIDEQ(session.UserID)).
-		Exist(ctx)
+		First(ctx)
 	if err != nil {
+		if ent.IsNotFound(err) {
+			return Forbidden(c, "account not linked")
+		}
 		LogError("AuthorizeLinked[MIDDLEWARE]", "checking spotify link", err)
 		return InternalServerError(c, "error while authorizing")
-	} else if !exists {
-		return Forbidden(c, "account not linked")
+	} else if link.Revoked {
+		return Forbidden(c, "spotify link revoked, relink your account")
 	}
 
 	c.Locals("session", session)
 	return c.Next()
 }
+
+// sessionTouchInterval is how often the last_seen of a session is updated, to avoid a write on every request.
+const sessionTouchInterval = time.Minute
+
+// touchSession records that the session was just used, and from where.
+// failing to do so doesn't fail the request; it is only informative.
+func (m *Middlewares) touchSession(c *fiber.Ctx, session *ent.Session) {
+	if time.Since(session.LastSeen) < sessionTouchInterval && session.IP == c.IP() {
+		return
+	}
+
+	err := session.Update().
+		SetLastSeen(time.Now()).
+		SetIP(c.IP()).
+		Exec(c.Context())
+	if err != nil {
+		LogError("touchSession[MIDDLEWARE]", "updating session", err)
+	}
+}
@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"groove/pkgs/ent"
+	AccessToken "groove/pkgs/ent/accesstoken"
+	User "groove/pkgs/ent/user"
+	"groove/pkgs/secret"
+	. "groove/pkgs/util"
+	"strings"
+	"time"
+)
+
+// AllowToken lets the endpoint be used with a personal access token given the scope,
+// sent as an `Authorization: Bearer` header instead of the session cookies.
+// without the header, the request goes on to be authorized by the session as usual.
+//
+// NOTE: this middleware is meant to be used before AuthorizeAny, CheckCSRF or AuthorizeLinked, which let
+// requests authorized by a token through; the token stands in for a session of its user.
+func (m *Middlewares) AllowToken(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		header := c.Get(fiber.HeaderAuthorization)
+		if header == "" {
+			return c.Next()
+		}
+
+		bearer, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok || bearer == "" {
+			return Unauthorized(c, "invalid authorization header")
+		}
+
+		ctx := c.Context()
+		token, err := m.Client.AccessToken.
+			Query().
+			Where(
+				AccessToken.TokenHashEQ(secret.HashToken(bearer)),
+				// tokens of disabled accounts are kept, but unusable until the account is enabled again.
+				AccessToken.HasUserWith(User.DisabledAtIsNil()),
+			).
+			First(ctx)
+		if err != nil {
+			if ent.IsNotFound(err) {
+				return Unauthorized(c, "token not found")
+			}
+			LogError("AllowToken[MIDDLEWARE]", "checking token", err)
+			return InternalServerError(c, "error while authorizing")
+		}
+
+		if token.Expiration != nil && token.Expiration.Before(time.Now()) {
+			return Unauthorized(c, "token expired")
+		}
+
+		var allowed bool
+		for _, s := range token.Scopes {
+			allowed = allowed || s == scope
+		}
+		if !allowed {
+			return Forbidden(c, "token is missing the "+scope+" scope")
+		}
+
+		m.touchToken(c, token)
+		c.Locals("token", token)
+		// actions only rely on the user of the session.
+		c.Locals("session", &ent.Session{UserID: token.UserID})
+		return c.Next()
+	}
+}
+
+// authorizedByToken reports whether the request was authorized by a personal access token; see AllowToken.
+func authorizedByToken(c *fiber.Ctx) bool {
+	_, ok := c.Locals("token").(*ent.AccessToken)
+	return ok
+}
+
+// touchToken records that the token was just used, at most once per sessionTouchInterval.
+// failing to do so doesn't fail the request; it is only informative.
+func (m *Middlewares) touchToken(c *fiber.Ctx, token *ent.AccessToken) {
+	if token.LastUsed != nil && time.Since(*token.LastUsed) < sessionTouchInterval {
+		return
+	}
+
+	err := token.Update().
+		SetLastUsed(time.Now()).
+		Exec(c.Context())
+	if err != nil {
+		LogError("touchToken[MIDDLEWARE]", "updating token", err)
+	}
+}
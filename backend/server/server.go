@@ -4,8 +4,12 @@ import (
 	"context"
 	"github.com/gofiber/fiber/v2"
 	"go.uber.org/fx"
+	"groove/pkgs/cache"
+	"groove/pkgs/db"
 	"groove/pkgs/ent"
 	"groove/pkgs/env"
+	"groove/pkgs/mail"
+	"groove/pkgs/spotify"
 	. "groove/pkgs/util"
 	"groove/server/actions"
 	"groove/server/handlers"
@@ -18,23 +22,19 @@ type Server struct {
 	middleware *middleware.Middlewares
 }
 
-func InvokeServer(lc fx.Lifecycle, shutdowner fx.Shutdowner, client *ent.Client, env *env.Env) {
-	server := &Server{
-		app: fiber.New(),
-		handlers: &handlers.Handlers{
-			Actions: &actions.Actions{
-				Client: client,
-				Env:    env,
-			},
-		},
-		middleware: &middleware.Middlewares{
-			Client:     client,
-			Env:        env,
-			Shutdowner: shutdowner,
-		},
-	}
-	server.middleware.Attach(server.app)
-	server.SetupEndpoints()
+func InvokeServer(
+	lc fx.Lifecycle,
+	shutdowner fx.Shutdowner,
+	client *ent.Client,
+	env *env.Env,
+	spotify *spotify.Client,
+	appToken *spotify.AppToken,
+	refresher *db.Refresher,
+	cache *cache.Cache,
+	mail mail.Sender,
+	throttles *db.Throttles,
+) {
+	server := New(client, env, spotify, appToken, refresher, cache, mail, throttles)
 
 	lc.Append(fx.Hook{
 		OnStart: func(context.Context) error {
@@ -51,3 +51,50 @@ func InvokeServer(lc fx.Lifecycle, shutdowner fx.Shutdowner, client *ent.Client,
 		},
 	})
 }
+
+// New creates the server with its middleware and endpoints attached, without listening.
+// tests drive it through App().Test, against a fake Spotify (see server_test.go and pkgs/spotify/spotifytest).
+func New(
+	client *ent.Client,
+	env *env.Env,
+	spotify *spotify.Client,
+	appToken *spotify.AppToken,
+	refresher *db.Refresher,
+	cache *cache.Cache,
+	mail mail.Sender,
+	throttles *db.Throttles,
+) *Server {
+	server := &Server{
+		app: fiber.New(fiber.Config{
+			// behind a reverse proxy, the client IP (used for rate limits) comes from a header set by the proxy;
+			// it is only trusted from the proxy, or clients could claim any IP.
+			ProxyHeader:             env.ProxyHeader,
+			EnableTrustedProxyCheck: len(env.TrustedProxies) > 0,
+			TrustedProxies:          env.TrustedProxies,
+		}),
+		handlers: &handlers.Handlers{
+			Actions: &actions.Actions{
+				Client:    client,
+				Env:       env,
+				Spotify:   spotify,
+				Cache:     cache,
+				Mail:      mail,
+				Throttles: throttles,
+			},
+		},
+		middleware: &middleware.Middlewares{
+			Client:    client,
+			Env:       env,
+			Spotify:   spotify,
+			AppToken:  appToken,
+			Refresher: refresher,
+		},
+	}
+	server.middleware.Attach(server.app)
+	server.SetupEndpoints()
+	return server
+}
+
+func (s *Server) App() *fiber.App {
+	return s.app
+}
@@ -0,0 +1,945 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"groove/pkgs/cache"
+	"groove/pkgs/db"
+	"groove/pkgs/db/dbtest"
+	"groove/pkgs/ent"
+	Session "groove/pkgs/ent/session"
+	SpotifyLink "groove/pkgs/ent/spotifylink"
+	Throttle "groove/pkgs/ent/throttle"
+	User "groove/pkgs/ent/user"
+	"groove/pkgs/env"
+	"groove/pkgs/mail"
+	"groove/pkgs/secret"
+	"groove/pkgs/spotify"
+	"groove/pkgs/spotify/spotifytest"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+/*
+ * These tests run the whole app end-to-end: the Fiber app with its middleware and endpoints, over an in-memory
+ * SQLite database with every migration applied (see pkgs/db/dbtest), against a fake Spotify (see spotifytest).
+ */
+
+// testKeyring encrypts the Spotify tokens stored by the tests.
+const testKeyring = "k1:MDEyMzQ1Njc4OTAxMjM0NTY3ODkwMTIzNDU2Nzg5MDE="
+
+type testApp struct {
+	t      *testing.T
+	server *Server
+	client *ent.Client
+	fake   *spotifytest.Server
+}
+
+func newTestApp(t *testing.T) *testApp {
+	t.Helper()
+
+	keyring, err := secret.ParseKeyring(testKeyring)
+	if err != nil {
+		t.Fatalf("parsing keyring: %v", err)
+	}
+	secret.Use(keyring)
+
+	fake := spotifytest.NewServer()
+	t.Cleanup(fake.Close)
+
+	client := dbtest.NewClient(t)
+	sp := spotify.New(fake.Config())
+	e := &env.Env{
+		SpotifyClient: spotifytest.ClientID,
+		SpotifySecret: spotifytest.ClientSecret,
+		BackendURL:    "http://localhost:3000",
+		FrontendURL:   "http://localhost:5173",
+		SameSite:      "Lax",
+		DBDriver:      "sqlite3",
+	}
+
+	server := New(
+		client,
+		e,
+		sp,
+		spotify.NewAppToken(sp),
+		db.ProvideRefresher(client, sp),
+		cache.New(cache.NewLRU(100)),
+		mail.Log{},
+		db.ProvideThrottles(client),
+	)
+	return &testApp{t: t, server: server, client: client, fake: fake}
+}
+
+// testUser is a browser of a registered user, keeping the cookies of its session.
+// with a bearer, it is a script using a personal access token instead.
+type testUser struct {
+	app     *testApp
+	id      int
+	cookies map[string]string
+	bearer  string
+}
+
+// register registers a user, verified if asked; Spotify accounts are linked separately.
+func (a *testApp) register(username string, verified bool) *testUser {
+	a.t.Helper()
+
+	user := &testUser{app: a, cookies: map[string]string{}}
+	status, body, _ := user.do(http.MethodPost, "/api/register", map[string]any{
+		"username": username,
+		"email":    username + "@groove.test",
+		"password": "Password1",
+	})
+	if status != http.StatusCreated {
+		a.t.Fatalf("registering %s: %d %v", username, status, body)
+	}
+
+	ctx := context.Background()
+	user.id = a.client.User.Query().Where(User.UsernameEQ(username)).OnlyIDX(ctx)
+	if verified {
+		a.client.User.UpdateOneID(user.id).SetEmailVerified(true).ExecX(ctx)
+	}
+	return user
+}
+
+// guest returns a browser without a session.
+func (a *testApp) guest() *testUser {
+	return &testUser{app: a, cookies: map[string]string{}}
+}
+
+// login logs in with the password of register from a new browser, returning it along with the response.
+func (a *testApp) login(username string) (*testUser, int, map[string]any) {
+	a.t.Helper()
+
+	user := a.guest()
+	if id, err := a.client.User.Query().Where(User.UsernameEQ(username)).OnlyID(context.Background()); err == nil {
+		user.id = id
+	}
+	status, body, _ := user.do(http.MethodPost, "/api/login", map[string]any{
+		"username": username,
+		"password": "Password1",
+	})
+	return user, status, body
+}
+
+// link links the user to the fake's Spotify user, as if they went through the authorization flow.
+func (u *testUser) link() {
+	tokens := u.app.fake.Link(spotifytest.UserID)
+	u.app.client.SpotifyLink.
+		Create().
+		SetUserID(u.id).
+		SetAccessToken(tokens.AccessToken).
+		SetAccessTokenExpiration(time.Now().Add(time.Hour)).
+		SetRefreshToken(tokens.RefreshToken).
+		ExecX(context.Background())
+}
+
+// accessToken creates a personal access token of the user, returning a script using it.
+func (u *testUser) accessToken(scopes ...string) *testUser {
+	u.app.t.Helper()
+
+	status, body, _ := u.do(http.MethodPost, "/api/tokens/", map[string]any{"name": "script", "scopes": scopes})
+	if status != http.StatusCreated {
+		u.app.t.Fatalf("creating token: %d %v", status, body)
+	}
+	token, _ := body["token"].(map[string]any)
+	return &testUser{app: u.app, id: u.id, cookies: map[string]string{}, bearer: token["token"].(string)}
+}
+
+// do sends a request with the session of the user (and its CSRF token), decoding a JSON response.
+func (u *testUser) do(method, path string, payload any) (int, map[string]any, http.Header) {
+	u.app.t.Helper()
+
+	return u.doCSRF(method, path, payload, u.cookies["Csrf"])
+}
+
+// doCSRF is do with the given X-CSRF-Token header, or none if it is empty.
+func (u *testUser) doCSRF(method, path string, payload any, csrf string) (int, map[string]any, http.Header) {
+	u.app.t.Helper()
+
+	var body io.Reader
+	if payload != nil {
+		raw, err := json.Marshal(payload)
+		if err != nil {
+			u.app.t.Fatalf("encoding payload: %v", err)
+		}
+		body = bytes.NewReader(raw)
+	}
+
+	req := httptest.NewRequest(method, path, body)
+	req.Header.Set("Content-Type", "application/json")
+	if csrf != "" {
+		req.Header.Set("X-CSRF-Token", csrf)
+	}
+	if u.bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+u.bearer)
+	}
+	for name, value := range u.cookies {
+		req.AddCookie(&http.Cookie{Name: name, Value: value})
+	}
+
+	resp, err := u.app.server.App().Test(req, -1)
+	if err != nil {
+		u.app.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	for _, cookie := range resp.Cookies() {
+		if cookie.Value == "" || cookie.MaxAge < 0 {
+			delete(u.cookies, cookie.Name)
+		} else {
+			u.cookies[cookie.Name] = cookie.Value
+		}
+	}
+
+	raw, _ := io.ReadAll(resp.Body)
+	decoded := map[string]any{}
+	_ = json.Unmarshal(raw, &decoded)
+	return resp.StatusCode, decoded, resp.Header
+}
+
+func TestCatalogProxy(t *testing.T) {
+	app := newTestApp(t)
+	user := app.register("catalog1", false)
+
+	tests := []struct {
+		path string
+		key  string
+		want string
+	}{
+		{"/api/spotify/tracks/7NeonAvenue0000000001", "name", "Neon Avenue"},
+		{"/api/spotify/artists/4Nv0aLights00000000001", "name", "Nova Lights"},
+		{"/api/spotify/albums/2MidnightTransit000001", "name", "Midnight Transit"},
+	}
+	for _, test := range tests {
+		status, body, _ := user.do(http.MethodGet, test.path, nil)
+		if status != http.StatusOK {
+			t.Fatalf("GET %s: %d %v", test.path, status, body)
+		}
+		if body[test.key] != test.want {
+			t.Errorf("GET %s: %s = %v, want %q", test.path, test.key, body[test.key], test.want)
+		}
+	}
+
+	status, body, _ := user.do(http.MethodGet, "/api/spotify/search/neon?type=track", nil)
+	if status != http.StatusOK {
+		t.Fatalf("search: %d %v", status, body)
+	}
+	tracks, _ := body["tracks"].(map[string]any)
+	if items, _ := tracks["items"].([]any); len(items) == 0 {
+		t.Errorf("search: no tracks found in %v", body)
+	}
+
+	status, _, _ = user.do(http.MethodGet, "/api/spotify/tracks/7Missing0000000000000", nil)
+	if status != http.StatusNotFound {
+		t.Errorf("GET missing track: %d, want 404", status)
+	}
+
+	// catalog responses are cached.
+	before := app.fake.Calls(http.MethodGet, "/v1/tracks/7NeonAvenue0000000001")
+	user.do(http.MethodGet, "/api/spotify/tracks/7NeonAvenue0000000001", nil)
+	if after := app.fake.Calls(http.MethodGet, "/v1/tracks/7NeonAvenue0000000001"); after != before {
+		t.Errorf("cached track requested from spotify again (%d calls, want %d)", after, before)
+	}
+}
+
+func TestSpotifyErrors(t *testing.T) {
+	app := newTestApp(t)
+	user := app.register("errors1", false)
+
+	// a track per case, so that none is served from the cache.
+	tests := []struct {
+		name       string
+		track      string
+		fault      spotifytest.Fault
+		want       int
+		retryAfter string
+	}{
+		{"bad request", "7NeonAvenue0000000001", spotifytest.Fault{Status: http.StatusBadRequest}, http.StatusBadRequest, ""},
+		{"not found", "7Afterglow00000000002", spotifytest.Fault{Status: http.StatusNotFound}, http.StatusNotFound, ""},
+		{"rate limited", "7StaticHearts00000003", spotifytest.Fault{Status: http.StatusTooManyRequests, RetryAfter: 30 * time.Second}, http.StatusTooManyRequests, "30"},
+		{"unavailable", "7HarborLights00000004", spotifytest.Fault{Status: http.StatusServiceUnavailable, RetryAfter: 10 * time.Second}, http.StatusServiceUnavailable, "10"},
+		{"server error", "7Undertow000000000005", spotifytest.Fault{Status: http.StatusInternalServerError}, http.StatusServiceUnavailable, "1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app.fake.ClearFaults()
+			test.fault.Path = "/v1/tracks/"
+			app.fake.Fail(test.fault)
+
+			status, body, header := user.do(http.MethodGet, "/api/spotify/tracks/"+test.track, nil)
+			if status != test.want {
+				t.Fatalf("status = %d %v, want %d", status, body, test.want)
+			}
+			if got := header.Get("Retry-After"); got != test.retryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, test.retryAfter)
+			}
+		})
+	}
+	app.fake.ClearFaults()
+
+	// 5xx responses to GETs are retried; a fault that clears is never seen by the client.
+	app.fake.Fail(spotifytest.Fault{Path: "/v1/albums/", Status: http.StatusBadGateway, Times: 1})
+	status, body, _ := user.do(http.MethodGet, "/api/spotify/albums/2MidnightTransit000001", nil)
+	if status != http.StatusOK {
+		t.Errorf("GET album after a transient 502: %d %v, want 200", status, body)
+	}
+}
+
+func TestTokenGrants(t *testing.T) {
+	app := newTestApp(t)
+	user := app.register("grants1", true)
+	ctx := context.Background()
+
+	// client_credentials: the catalog of unlinked users goes through a single app token.
+	user.do(http.MethodGet, "/api/spotify/tracks/7NeonAvenue0000000001", nil)
+	user.do(http.MethodGet, "/api/spotify/tracks/7Afterglow00000000002", nil)
+	if calls := app.fake.Calls(http.MethodPost, "/api/token"); calls != 1 {
+		t.Errorf("app token requested %d times, want 1", calls)
+	}
+
+	// authorization_code: linking exchanges the code of the callback.
+	status, body, _ := user.do(http.MethodPost, "/api/spotify/link", map[string]any{})
+	if status != http.StatusOK {
+		t.Fatalf("link: %d %v", status, body)
+	}
+	state := app.client.OAuthState.Query().OnlyX(ctx).State
+
+	callback := "/api/spotify/callback?code=" + app.fake.Authorize(spotifytest.UserID) + "&state=" + state
+	status, body, _ = user.do(http.MethodGet, callback, nil)
+	if status != http.StatusFound {
+		t.Fatalf("callback: %d %v, want 302", status, body)
+	}
+	link := app.client.SpotifyLink.Query().Where(SpotifyLink.UserIDEQ(user.id)).OnlyX(ctx)
+
+	status, body, _ = user.do(http.MethodGet, "/api/spotify/me", nil)
+	if status != http.StatusOK || body["id"] != spotifytest.UserID {
+		t.Fatalf("me: %d %v", status, body)
+	}
+
+	// refresh_token: an expired access token is refreshed on the next request.
+	app.fake.ExpireAccessToken(link.AccessToken)
+	app.client.SpotifyLink.UpdateOne(link).SetAccessTokenExpiration(time.Now().Add(-time.Minute)).ExecX(ctx)
+
+	status, body, _ = user.do(http.MethodGet, "/api/spotify/me", nil)
+	if status != http.StatusOK || body["id"] != spotifytest.UserID {
+		t.Fatalf("me after expiration: %d %v", status, body)
+	}
+	refreshed := app.client.SpotifyLink.GetX(ctx, link.ID)
+	if refreshed.AccessToken == link.AccessToken || !refreshed.AccessTokenExpiration.After(time.Now()) {
+		t.Error("access token was not refreshed")
+	}
+
+	// an invalid code is a bad request, rather than an error of Groove.
+	app.client.SpotifyLink.DeleteOneID(link.ID).ExecX(ctx)
+	user.do(http.MethodPost, "/api/spotify/link", map[string]any{})
+	state = app.client.OAuthState.Query().OnlyX(ctx).State
+	status, body, _ = user.do(http.MethodGet, "/api/spotify/callback?code=invalid&state="+state, nil)
+	if status != http.StatusBadRequest {
+		t.Errorf("callback with an invalid code: %d %v, want 400", status, body)
+	}
+}
+
+func TestPlaylistRoundTrip(t *testing.T) {
+	app := newTestApp(t)
+	user := app.register("playlists1", true)
+	user.link()
+
+	status, body, _ := user.do(http.MethodPost, "/api/spotify/playlists/", map[string]any{
+		"name":        "Round Trip",
+		"description": "made by the tests",
+		"public":      false,
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("create: %d %v", status, body)
+	}
+	id, _ := body["id"].(string)
+	playlist := "/api/spotify/playlists/" + id
+
+	status, body, _ = user.do(http.MethodPost, playlist+"/tracks", map[string]any{
+		"uris": []string{
+			"spotify:track:7NeonAvenue0000000001",
+			"spotify:track:7Afterglow00000000002",
+			"spotify:track:7StaticHearts00000003",
+		},
+	})
+	if status != http.StatusCreated && status != http.StatusOK {
+		t.Fatalf("add: %d %v", status, body)
+	}
+
+	status, body, _ = user.do(http.MethodPatch, playlist, map[string]any{"name": "Round Trip (renamed)"})
+	if status != http.StatusOK && status != http.StatusNoContent {
+		t.Fatalf("update: %d %v", status, body)
+	}
+
+	status, body, _ = user.do(http.MethodGet, playlist+"/tracks", nil)
+	if status != http.StatusOK {
+		t.Fatalf("tracks: %d %v", status, body)
+	}
+	if total, _ := body["total"].(float64); total != 3 {
+		t.Fatalf("tracks: total = %v, want 3", body["total"])
+	}
+
+	status, body, _ = user.do(http.MethodDelete, playlist+"/tracks", map[string]any{
+		"uris": []string{"spotify:track:7Afterglow00000000002"},
+	})
+	if status != http.StatusOK {
+		t.Fatalf("remove: %d %v", status, body)
+	}
+
+	status, body, _ = user.do(http.MethodGet, playlist, nil)
+	if status != http.StatusOK {
+		t.Fatalf("get: %d %v", status, body)
+	}
+	if body["name"] != "Round Trip (renamed)" {
+		t.Errorf("get: name = %v, want the new name", body["name"])
+	}
+	tracks, _ := body["tracks"].(map[string]any)
+	if total, _ := tracks["total"].(float64); total != 2 {
+		t.Errorf("get: %v tracks, want 2", tracks["total"])
+	}
+
+	// 5xx responses to POSTs are not retried, as the tracks may have been added.
+	app.fake.Fail(spotifytest.Fault{Method: http.MethodPost, Path: "/v1/playlists/" + id + "/tracks", Status: http.StatusBadGateway, Times: 1})
+	before := app.fake.Calls(http.MethodPost, "/v1/playlists/"+id+"/tracks")
+	status, _, _ = user.do(http.MethodPost, playlist+"/tracks", map[string]any{"uris": []string{"spotify:track:7Undertow000000000005"}})
+	if status != http.StatusServiceUnavailable {
+		t.Errorf("add during an outage: %d, want 503", status)
+	}
+	if calls := app.fake.Calls(http.MethodPost, "/v1/playlists/"+id+"/tracks") - before; calls != 1 {
+		t.Errorf("add during an outage: %d requests, want 1", calls)
+	}
+
+	status, body, _ = user.do(http.MethodDelete, playlist, nil)
+	if status != http.StatusOK && status != http.StatusNoContent {
+		t.Fatalf("unfollow: %d %v", status, body)
+	}
+}
+
+func TestForgotPasswordThrottle(t *testing.T) {
+	app := newTestApp(t)
+	app.register("forgot1", false)
+	anonymous := app.guest()
+
+	// emails with and without an account are throttled alike: a few links, then a lockout.
+	for _, email := range []string{"forgot1@groove.test", "nobody@groove.test"} {
+		for i := 0; i < 4; i++ {
+			status, body, _ := anonymous.do(http.MethodPost, "/api/password/forgot", map[string]any{"email": email})
+			if status != http.StatusOK {
+				t.Fatalf("%s: request %d: %d %v, want 200", email, i+1, status, body)
+			}
+		}
+		status, _, header := anonymous.do(http.MethodPost, "/api/password/forgot", map[string]any{"email": email})
+		if status != http.StatusTooManyRequests || header.Get("Retry-After") == "" {
+			t.Errorf("%s: %d, want 429 with Retry-After", email, status)
+		}
+	}
+}
+
+// wrongLogin logs in to the account with an incorrect password from a new browser.
+func (a *testApp) wrongLogin(username string) (int, map[string]any, http.Header) {
+	a.t.Helper()
+
+	return a.guest().do(http.MethodPost, "/api/login", map[string]any{
+		"username": username,
+		"password": "Incorrect1",
+	})
+}
+
+// loginAccountKey mirrors the throttle key of the actions package for an account.
+func loginAccountKey(username string) string {
+	return "login:account:" + username
+}
+
+// expireLockout ends the lockout of the key early, as if its time had passed; its attempts are kept.
+func (a *testApp) expireLockout(key string) {
+	a.client.Throttle.
+		Update().
+		Where(Throttle.KeyEQ(key)).
+		SetLockedUntil(time.Now().Add(-time.Second)).
+		ExecX(context.Background())
+}
+
+func TestLoginLockout(t *testing.T) {
+	app := newTestApp(t)
+	app.register("lockout1", true)
+
+	// a few incorrect passwords are allowed, then the account is locked out for 30s.
+	for i := 0; i < 5; i++ {
+		if status, body, _ := app.wrongLogin("lockout1"); status != http.StatusBadRequest {
+			t.Fatalf("incorrect password %d: %d %v, want 400", i+1, status, body)
+		}
+	}
+	status, body, header := app.wrongLogin("lockout1")
+	if status != http.StatusTooManyRequests || header.Get("Retry-After") != "30" {
+		t.Fatalf("incorrect password past the limit: %d %v (Retry-After %q), want 429 for 30s", status, body, header.Get("Retry-After"))
+	}
+
+	// while locked out, even the correct password is refused.
+	if _, status, body = app.login("lockout1"); status != http.StatusTooManyRequests {
+		t.Fatalf("correct password while locked out: %d %v, want 429", status, body)
+	}
+
+	// every further failure doubles the lockout.
+	for _, want := range []string{"60", "120"} {
+		app.expireLockout(loginAccountKey("lockout1"))
+		status, body, header = app.wrongLogin("lockout1")
+		if status != http.StatusTooManyRequests || header.Get("Retry-After") != want {
+			t.Fatalf("incorrect password after a lockout: %d %v (Retry-After %q), want 429 for %ss", status, body, header.Get("Retry-After"), want)
+		}
+	}
+
+	// a successful login forgets the failures.
+	app.expireLockout(loginAccountKey("lockout1"))
+	if _, status, body = app.login("lockout1"); status != http.StatusCreated {
+		t.Fatalf("correct password after the lockout: %d %v, want 201", status, body)
+	}
+	for i := 0; i < 5; i++ {
+		if status, body, _ = app.wrongLogin("lockout1"); status != http.StatusBadRequest {
+			t.Fatalf("incorrect password %d after a login: %d %v, want 400", i+1, status, body)
+		}
+	}
+	if status, _, header = app.wrongLogin("lockout1"); status != http.StatusTooManyRequests || header.Get("Retry-After") != "30" {
+		t.Errorf("incorrect password past the limit after a login: %d (Retry-After %q), want 429 for 30s", status, header.Get("Retry-After"))
+	}
+}
+
+func TestLoginLockoutIsGeneric(t *testing.T) {
+	app := newTestApp(t)
+	app.register("lockout2", true)
+
+	// an account and an unknown username are locked out alike, so a lockout doesn't tell they exist.
+	for _, username := range []string{"lockout2", "nobody"} {
+		for i := 0; i < 6; i++ {
+			app.wrongLogin(username)
+		}
+	}
+	_, status, locked := app.login("lockout2")
+	_, unknownStatus, unknown := app.login("nobody")
+	if status != http.StatusTooManyRequests || unknownStatus != status {
+		t.Fatalf("locked out logins: %d and %d, want 429", status, unknownStatus)
+	}
+	if locked["message"] != unknown["message"] || locked["error"] != unknown["error"] {
+		t.Errorf("locked out account %v differs from unknown username %v", locked, unknown)
+	}
+}
+
+func TestRegisterThrottle(t *testing.T) {
+	app := newTestApp(t)
+	app.register("register1", false)
+
+	// failed registrations don't count towards the limit of the IP.
+	status, body, _ := app.guest().do(http.MethodPost, "/api/register", map[string]any{
+		"username": "register1",
+		"email":    "other@groove.test",
+		"password": "Password1",
+	})
+	if status != http.StatusBadRequest {
+		t.Fatalf("registering a taken username: %d %v, want 400", status, body)
+	}
+
+	// the account past the limit is created, then the IP is locked out for an hour.
+	for _, username := range []string{"register2", "register3", "register4", "register5", "register6"} {
+		app.register(username, false)
+	}
+	status, body, header := app.guest().do(http.MethodPost, "/api/register", map[string]any{
+		"username": "register7",
+		"email":    "register7@groove.test",
+		"password": "Password1",
+	})
+	if status != http.StatusTooManyRequests || header.Get("Retry-After") != "3600" {
+		t.Fatalf("registering past the limit: %d %v (Retry-After %q), want 429 for 1h", status, body, header.Get("Retry-After"))
+	}
+	if app.client.User.Query().Where(User.UsernameEQ("register7")).ExistX(context.Background()) {
+		t.Error("account created while locked out")
+	}
+}
+
+func TestAccessTokens(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+	user := app.register("tokens1", true)
+	user.link()
+	script := user.accessToken(db.ScopeCatalogRead, db.ScopePlaylistsWrite)
+
+	status, body, _ := script.do(http.MethodGet, "/api/spotify/tracks/7NeonAvenue0000000001", nil)
+	if status != http.StatusOK {
+		t.Fatalf("token within its scopes: %d %v, want 200", status, body)
+	}
+	status, body, _ = script.do(http.MethodGet, "/api/spotify/playlists/", nil)
+	if status != http.StatusForbidden {
+		t.Errorf("token outside its scopes: %d %v, want 403", status, body)
+	}
+
+	// tokens stand in for the session on the endpoints that allow them, so they need no CSRF token.
+	status, body, _ = script.do(http.MethodPost, "/api/spotify/playlists/", map[string]any{"name": "Scripted"})
+	if status != http.StatusCreated {
+		t.Errorf("token writing without a CSRF token: %d %v, want 201", status, body)
+	}
+	status, body, _ = script.do(http.MethodPost, "/api/tokens/", map[string]any{"name": "another", "scopes": []string{db.ScopeCatalogRead}})
+	if status != http.StatusUnauthorized {
+		t.Errorf("token on an endpoint that doesn't allow tokens: %d %v, want 401", status, body)
+	}
+
+	// tokens of disabled accounts are unusable until the account is enabled again.
+	app.client.User.UpdateOneID(user.id).SetDisabledAt(time.Now()).ExecX(ctx)
+	if status, body, _ = script.do(http.MethodGet, "/api/spotify/tracks/7NeonAvenue0000000001", nil); status != http.StatusUnauthorized {
+		t.Errorf("token of a disabled account: %d %v, want 401", status, body)
+	}
+	app.client.User.UpdateOneID(user.id).ClearDisabledAt().ExecX(ctx)
+	if status, body, _ = script.do(http.MethodGet, "/api/spotify/tracks/7NeonAvenue0000000001", nil); status != http.StatusOK {
+		t.Fatalf("token of an enabled account: %d %v, want 200", status, body)
+	}
+
+	app.client.AccessToken.Update().SetExpiration(time.Now().Add(-time.Minute)).ExecX(ctx)
+	if status, body, _ = script.do(http.MethodGet, "/api/spotify/tracks/7NeonAvenue0000000001", nil); status != http.StatusUnauthorized {
+		t.Errorf("expired token: %d %v, want 401", status, body)
+	}
+}
+
+func TestAccessTokenCannotAdmin(t *testing.T) {
+	app := newTestApp(t)
+	admin := app.register("tokens2", true)
+	app.client.User.UpdateOneID(admin.id).SetRole(User.RoleAdmin).ExecX(context.Background())
+	script := admin.accessToken(db.ScopeCatalogRead, db.ScopeProfileRead, db.ScopePlaylistsRead, db.ScopePlaylistsWrite)
+
+	if status, body, _ := admin.do(http.MethodGet, "/api/admin/users", nil); status != http.StatusOK {
+		t.Fatalf("admin with a session: %d %v, want 200", status, body)
+	}
+	if status, body, _ := script.do(http.MethodGet, "/api/admin/users", nil); status != http.StatusUnauthorized {
+		t.Errorf("admin with a token: %d %v, want 401", status, body)
+	}
+	if status, body, _ := script.do(http.MethodPost, "/api/admin/users/1/disable", map[string]any{}); status != http.StatusUnauthorized {
+		t.Errorf("admin action with a token: %d %v, want 401", status, body)
+	}
+}
+
+func TestCSRF(t *testing.T) {
+	app := newTestApp(t)
+	user := app.register("csrf1", true)
+	csrf := user.cookies["Csrf"]
+
+	tests := []struct {
+		name    string
+		header  string
+		payload map[string]any
+		want    int
+	}{
+		{"header", csrf, map[string]any{}, http.StatusOK},
+		{"body", "", map[string]any{"csrf_": csrf}, http.StatusOK},
+		{"header over body", csrf, map[string]any{"csrf_": "forged"}, http.StatusOK},
+		{"missing", "", map[string]any{}, http.StatusForbidden},
+		{"mismatched header", "forged", map[string]any{}, http.StatusForbidden},
+		{"mismatched body", "", map[string]any{"csrf_": "forged"}, http.StatusForbidden},
+	}
+	for _, test := range tests {
+		status, body, _ := user.doCSRF(http.MethodPost, "/api/sessions/revoke-others", test.payload, test.header)
+		if status != test.want {
+			t.Errorf("%s: %d %v, want %d", test.name, status, body, test.want)
+		}
+	}
+}
+
+// rotated checks that the Csrf token of the user changed from previous, and that only the new one is accepted.
+func (u *testUser) rotated(t *testing.T, change, previous string) {
+	t.Helper()
+
+	current := u.cookies["Csrf"]
+	if current == "" || current == previous {
+		t.Fatalf("csrf token not rotated on %s", change)
+	}
+	if status, body, _ := u.doCSRF(http.MethodPost, "/api/sessions/revoke-others", nil, previous); status != http.StatusForbidden {
+		t.Errorf("csrf token from before %s: %d %v, want 403", change, status, body)
+	}
+	if status, body, _ := u.doCSRF(http.MethodPost, "/api/sessions/revoke-others", nil, current); status != http.StatusOK {
+		t.Errorf("csrf token from after %s: %d %v, want 200", change, status, body)
+	}
+}
+
+func TestCSRFRotation(t *testing.T) {
+	app := newTestApp(t)
+	user := app.register("csrf2", true)
+
+	// every session gets its own token.
+	previous := user.cookies["Csrf"]
+	user, status, body := app.login("csrf2")
+	if status != http.StatusCreated {
+		t.Fatalf("login: %d %v", status, body)
+	}
+	if user.cookies["Csrf"] == "" || user.cookies["Csrf"] == previous {
+		t.Error("csrf token not rotated on login")
+	}
+
+	previous = user.cookies["Csrf"]
+	status, body, _ = user.do(http.MethodPost, "/api/account/password", map[string]any{
+		"current_password": "Password1",
+		"new_password":     "Password2",
+	})
+	if status != http.StatusOK {
+		t.Fatalf("change password: %d %v", status, body)
+	}
+	user.rotated(t, "a password change", previous)
+
+	previous = user.cookies["Csrf"]
+	user.enableTwoFactor(t)
+	user.rotated(t, "enabling two-factor authentication", previous)
+
+	previous = user.cookies["Csrf"]
+	status, body, _ = user.do(http.MethodPost, "/api/2fa/disable", map[string]any{"password": "Password2"})
+	if status != http.StatusOK {
+		t.Fatalf("disable two-factor authentication: %d %v", status, body)
+	}
+	user.rotated(t, "disabling two-factor authentication", previous)
+}
+
+func TestRevokeSession(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+	user := app.register("sessions1", true)
+	other := app.register("sessions2", true)
+	laptop, _, _ := app.login("sessions1")
+
+	// sessions of other users are not found, rather than revoked.
+	otherSession := app.client.Session.Query().Where(Session.UserIDEQ(other.id)).OnlyX(ctx)
+	status, body, _ := user.do(http.MethodDelete, fmt.Sprintf("/api/sessions/%d", otherSession.ID), nil)
+	if status != http.StatusNotFound {
+		t.Errorf("revoking a session of another user: %d %v, want 404", status, body)
+	}
+	if status, body, _ = other.do(http.MethodGet, "/api/sessions/", nil); status != http.StatusOK {
+		t.Errorf("session of another user after the attempt: %d %v, want 200", status, body)
+	}
+
+	laptopSession := app.client.Session.Query().Where(Session.TokenEQ(laptop.cookies["Authorization"])).OnlyX(ctx)
+	status, body, _ = user.do(http.MethodDelete, fmt.Sprintf("/api/sessions/%d", laptopSession.ID), nil)
+	if status != http.StatusNoContent {
+		t.Fatalf("revoking an own session: %d %v, want 204", status, body)
+	}
+	if status, body, _ = laptop.do(http.MethodGet, "/api/sessions/", nil); status != http.StatusUnauthorized {
+		t.Errorf("revoked session: %d %v, want 401", status, body)
+	}
+}
+
+func TestAdminRequiresRole(t *testing.T) {
+	app := newTestApp(t)
+	user := app.register("admin1", true)
+	admin := app.register("admin2", true)
+	app.client.User.UpdateOneID(admin.id).SetRole(User.RoleAdmin).ExecX(context.Background())
+
+	if status, body, _ := user.do(http.MethodGet, "/api/admin/users", nil); status != http.StatusForbidden {
+		t.Errorf("listing users as a user: %d %v, want 403", status, body)
+	}
+	path := fmt.Sprintf("/api/admin/users/%d/disable", admin.id)
+	if status, body, _ := user.do(http.MethodPost, path, map[string]any{}); status != http.StatusForbidden {
+		t.Errorf("disabling an admin as a user: %d %v, want 403", status, body)
+	}
+
+	if status, body, _ := admin.do(http.MethodGet, "/api/admin/users", nil); status != http.StatusOK {
+		t.Errorf("listing users as an admin: %d %v, want 200", status, body)
+	}
+}
+
+func TestAccountChangesRequirePassword(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+	user := app.register("account1", true)
+
+	status, body, _ := user.do(http.MethodPost, "/api/account/email", map[string]any{
+		"email":    "changed@groove.test",
+		"password": "Incorrect1",
+	})
+	if status != http.StatusBadRequest || body["message"] != "incorrect password" {
+		t.Errorf("changing email with an incorrect password: %d %v, want 400", status, body)
+	}
+	status, body, _ = user.do(http.MethodPost, "/api/account/email", map[string]any{
+		"email":    "changed@groove.test",
+		"password": "Password1",
+	})
+	if status != http.StatusOK {
+		t.Errorf("changing email with the password: %d %v, want 200", status, body)
+	}
+
+	status, body, _ = user.do(http.MethodDelete, "/api/account/", map[string]any{"password": "Incorrect1"})
+	if status != http.StatusBadRequest || body["message"] != "incorrect password" {
+		t.Errorf("deleting the account with an incorrect password: %d %v, want 400", status, body)
+	}
+	if !app.client.User.Query().Where(User.IDEQ(user.id)).ExistX(ctx) {
+		t.Fatal("account deleted with an incorrect password")
+	}
+	status, body, _ = user.do(http.MethodDelete, "/api/account/", map[string]any{"password": "Password1"})
+	if status != http.StatusOK {
+		t.Fatalf("deleting the account with the password: %d %v, want 200", status, body)
+	}
+	if app.client.User.Query().Where(User.IDEQ(user.id)).ExistX(ctx) {
+		t.Error("account not deleted")
+	}
+}
+
+// totpCode computes the code of an authenticator app for the secret at the given time (RFC 6238, SHA1, 6 digits),
+// independently of the secret package.
+func totpCode(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatalf("decoding totp secret: %v", err)
+	}
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(at.Unix()/30))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	return fmt.Sprintf("%06d", (binary.BigEndian.Uint32(sum[offset:offset+4])&0x7fffffff)%1_000_000)
+}
+
+// enableTwoFactor enrolls the user in two-factor authentication, returning its secret and recovery codes.
+func (u *testUser) enableTwoFactor(t *testing.T) (string, []string) {
+	t.Helper()
+
+	status, body, _ := u.do(http.MethodPost, "/api/2fa/enroll", nil)
+	if status != http.StatusOK {
+		t.Fatalf("enroll: %d %v", status, body)
+	}
+	totpSecret, _ := body["secret"].(string)
+
+	status, body, _ = u.do(http.MethodPost, "/api/2fa/confirm", map[string]any{"code": totpCode(t, totpSecret, time.Now())})
+	if status != http.StatusOK {
+		t.Fatalf("confirm: %d %v", status, body)
+	}
+	var codes []string
+	for _, code := range body["recovery_codes"].([]any) {
+		codes = append(codes, code.(string))
+	}
+	return totpSecret, codes
+}
+
+// challenge logs in as a user with two-factor authentication, returning the browser and its login challenge.
+func (a *testApp) challenge(username string) (*testUser, string) {
+	a.t.Helper()
+
+	user, status, body := a.login(username)
+	if status != http.StatusOK || body["two_factor"] != true {
+		a.t.Fatalf("login with two-factor authentication: %d %v", status, body)
+	}
+	return user, body["challenge"].(string)
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	app := newTestApp(t)
+	user := app.register("twofactor1", true)
+	totpSecret, recovery := user.enableTwoFactor(t)
+	now := time.Now()
+
+	// the code that confirmed the enrollment was used up.
+	browser, challenge := app.challenge("twofactor1")
+	status, body, _ := browser.do(http.MethodPost, "/api/login/2fa", map[string]any{"challenge": challenge, "code": totpCode(t, totpSecret, now)})
+	if status != http.StatusBadRequest {
+		t.Fatalf("login with the confirmation code: %d %v, want 400", status, body)
+	}
+
+	// the next code logs in, once.
+	next := totpCode(t, totpSecret, now.Add(30*time.Second))
+	status, body, _ = browser.do(http.MethodPost, "/api/login/2fa", map[string]any{"challenge": challenge, "code": next})
+	if status != http.StatusCreated || browser.cookies["Authorization"] == "" {
+		t.Fatalf("login with a new code: %d %v, want 201 with a session", status, body)
+	}
+	browser, challenge = app.challenge("twofactor1")
+	status, body, _ = browser.do(http.MethodPost, "/api/login/2fa", map[string]any{"challenge": challenge, "code": next})
+	if status != http.StatusBadRequest {
+		t.Fatalf("login with a replayed code: %d %v, want 400", status, body)
+	}
+
+	// a recovery code logs in, once.
+	status, body, _ = browser.do(http.MethodPost, "/api/login/2fa", map[string]any{"challenge": challenge, "code": recovery[0]})
+	if status != http.StatusCreated {
+		t.Fatalf("login with a recovery code: %d %v, want 201", status, body)
+	}
+	browser, challenge = app.challenge("twofactor1")
+	status, body, _ = browser.do(http.MethodPost, "/api/login/2fa", map[string]any{"challenge": challenge, "code": recovery[0]})
+	if status != http.StatusBadRequest {
+		t.Fatalf("login with a used recovery code: %d %v, want 400", status, body)
+	}
+}
+
+func TestTwoFactorChallengeAttempts(t *testing.T) {
+	app := newTestApp(t)
+	user := app.register("twofactor2", true)
+	_, recovery := user.enableTwoFactor(t)
+
+	browser, challenge := app.challenge("twofactor2")
+	for i := 0; i < 5; i++ {
+		status, body, _ := browser.do(http.MethodPost, "/api/login/2fa", map[string]any{"challenge": challenge, "code": "000000"})
+		if status != http.StatusBadRequest || body["message"] != "incorrect code" {
+			t.Fatalf("incorrect code %d: %d %v, want 400", i+1, status, body)
+		}
+	}
+
+	// out of attempts, the challenge is gone: even a correct code needs logging in again.
+	status, body, _ := browser.do(http.MethodPost, "/api/login/2fa", map[string]any{"challenge": challenge, "code": recovery[0]})
+	if status != http.StatusBadRequest || body["message"] != "too many incorrect codes, log in again" {
+		t.Fatalf("code after the last attempt: %d %v, want 400", status, body)
+	}
+	status, body, _ = browser.do(http.MethodPost, "/api/login/2fa", map[string]any{"challenge": challenge, "code": recovery[0]})
+	if status != http.StatusBadRequest || browser.cookies["Authorization"] != "" {
+		t.Fatalf("code for a deleted challenge: %d %v, want 400", status, body)
+	}
+}
+
+func TestTwoFactorSpotifyLogin(t *testing.T) {
+	app := newTestApp(t)
+	ctx := context.Background()
+	user := app.register("twofactor3", true)
+	totpSecret, _ := user.enableTwoFactor(t)
+	app.client.User.UpdateOneID(user.id).SetSpotifyID(spotifytest.UserID).ExecX(ctx)
+
+	browser := app.guest()
+	if status, body, _ := browser.do(http.MethodPost, "/api/spotify/login", nil); status != http.StatusOK {
+		t.Fatalf("login with spotify: %d %v", status, body)
+	}
+	callback := "/api/spotify/login/callback?code=" + app.fake.Authorize(spotifytest.UserID) + "&state=" + browser.cookies["SpotifyState"]
+	status, body, header := browser.do(http.MethodGet, callback, nil)
+	if status != http.StatusFound {
+		t.Fatalf("callback: %d %v, want 302", status, body)
+	}
+
+	// the challenge is only sent as a cookie, and nothing is saved before a code is given.
+	if location := header.Get("Location"); location != "http://localhost:5173/login?two_factor=true" {
+		t.Errorf("callback redirected to %s", location)
+	}
+	if browser.cookies["LoginChallenge"] == "" || browser.cookies["Authorization"] != "" {
+		t.Fatalf("callback cookies: %v, want a challenge and no session", browser.cookies)
+	}
+	if app.client.SpotifyLink.Query().Where(SpotifyLink.UserIDEQ(user.id)).ExistX(ctx) {
+		t.Fatal("spotify linked before the second factor was checked")
+	}
+
+	status, body, _ = browser.do(http.MethodPost, "/api/login/2fa", map[string]any{"code": "000000"})
+	if status != http.StatusBadRequest {
+		t.Fatalf("login with an incorrect code: %d %v, want 400", status, body)
+	}
+	if app.client.SpotifyLink.Query().Where(SpotifyLink.UserIDEQ(user.id)).ExistX(ctx) {
+		t.Fatal("spotify linked after an incorrect code")
+	}
+
+	code := totpCode(t, totpSecret, time.Now().Add(30*time.Second))
+	status, body, _ = browser.do(http.MethodPost, "/api/login/2fa", map[string]any{"code": code})
+	if status != http.StatusCreated || browser.cookies["Authorization"] == "" {
+		t.Fatalf("login with a code: %d %v, want 201 with a session", status, body)
+	}
+	if browser.cookies["LoginChallenge"] != "" {
+		t.Error("challenge cookie kept after the login")
+	}
+	status, body, _ = browser.do(http.MethodGet, "/api/spotify/me", nil)
+	if status != http.StatusOK || body["id"] != spotifytest.UserID {
+		t.Fatalf("me after the login: %d %v", status, body)
+	}
+}